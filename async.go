@@ -0,0 +1,359 @@
+package golog
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Overflow policies for AsyncConfig.OverflowPolicy.
+const (
+	// OverflowBlock makes the logging call wait until the buffer has room.
+	// No entry is lost.
+	OverflowBlock = "block"
+	// OverflowDropNewest discards the entry being logged when the buffer
+	// is full.
+	OverflowDropNewest = "dropNewest"
+	// OverflowDropOldest discards the oldest buffered entry to make room.
+	OverflowDropOldest = "dropOldest"
+	// OverflowSample keeps one in every AsyncConfig.SampleRate entries
+	// once the buffer is three quarters full, and drops the newest entries
+	// when it is full.
+	OverflowSample = "sample"
+)
+
+// maxAsyncChunk bounds the bytes handed to the output in a single write,
+// well below the smallest lumberjack file size.
+const maxAsyncChunk = 64 * 1024
+
+// Defaults for AsyncConfig.
+const (
+	DefaultAsyncBufferSize = 4096
+	DefaultAsyncSampleRate = 10
+)
+
+// AsyncConfig enables writing log entries from a background goroutine.
+// Entries are encoded on the calling goroutine and queued in a bounded
+// ring buffer; Sync waits until every queued entry has been written.
+type AsyncConfig struct {
+	// Number of entries buffered per output. Defaults to
+	// DefaultAsyncBufferSize.
+	BufferSize int `json:"bufferSize"`
+
+	// What to do when the buffer is full: OverflowBlock (default),
+	// OverflowDropNewest, OverflowDropOldest or OverflowSample. Unknown
+	// policies block.
+	OverflowPolicy string `json:"overflowPolicy"`
+
+	// One in SampleRate entries is kept by OverflowSample under pressure.
+	// Defaults to DefaultAsyncSampleRate.
+	SampleRate int `json:"sampleRate"`
+}
+
+// Validate reports an unknown overflow policy or negative sizes.
+func (c AsyncConfig) Validate() error {
+	var errs []error
+	switch c.OverflowPolicy {
+	case "", OverflowBlock, OverflowDropNewest, OverflowDropOldest, OverflowSample:
+	default:
+		errs = append(errs, fmt.Errorf("golog: unknown overflow policy %q", c.OverflowPolicy))
+	}
+	if c.BufferSize < 0 {
+		errs = append(errs, fmt.Errorf("golog: negative async buffer size %d", c.BufferSize))
+	}
+	if c.SampleRate < 0 {
+		errs = append(errs, fmt.Errorf("golog: negative async sample rate %d", c.SampleRate))
+	}
+	return errors.Join(errs...)
+}
+
+// AsyncStats reports the state of the asynchronous write pipeline of a
+// logger. It is all zeros for synchronous loggers.
+type AsyncStats struct {
+	// Entries discarded by the overflow policy, per stream.
+	DroppedSystem uint64
+	DroppedTDR    uint64
+
+	// Entries queued and not yet written, across all outputs.
+	Buffered int
+}
+
+// asyncWriter is a zapcore.WriteSyncer that queues writes in a ring buffer
+// drained by a background goroutine.
+type asyncWriter struct {
+	out        zapcore.WriteSyncer
+	policy     string
+	sampleRate int
+
+	// perEntry disables chunking for outputs that take one entry per
+	// write, such as datagram sockets
+	perEntry bool
+
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	drained  *sync.Cond
+
+	ring  [][]byte
+	head  int
+	count int
+
+	// queued and written count entries, so Sync can wait for the entries
+	// queued before it was called
+	queued  uint64
+	written uint64
+	dropped uint64
+	sampled uint64
+	err     error
+	closed  bool
+	done    chan struct{}
+}
+
+func newAsyncWriter(out zapcore.WriteSyncer, conf AsyncConfig) *asyncWriter {
+	size := conf.BufferSize
+	if size <= 0 {
+		size = DefaultAsyncBufferSize
+	}
+
+	w := &asyncWriter{
+		out:  out,
+		ring: make([][]byte, size),
+		done: make(chan struct{}),
+	}
+	w.policy, w.sampleRate = overflowPolicy(conf)
+	if e, ok := out.(interface{ writesEntries() bool }); ok {
+		w.perEntry = e.writesEntries()
+	}
+	w.notEmpty = sync.NewCond(&w.mu)
+	w.notFull = sync.NewCond(&w.mu)
+	w.drained = sync.NewCond(&w.mu)
+	go w.run()
+	return w
+}
+
+// overflowPolicy returns the policy and sample rate of conf with defaults.
+func overflowPolicy(conf AsyncConfig) (policy string, sampleRate int) {
+	policy = conf.OverflowPolicy
+	switch policy {
+	case OverflowDropNewest, OverflowDropOldest, OverflowSample:
+	default:
+		policy = OverflowBlock
+	}
+	sampleRate = conf.SampleRate
+	if sampleRate <= 0 {
+		sampleRate = DefaultAsyncSampleRate
+	}
+	return policy, sampleRate
+}
+
+// setPolicy changes the overflow policy and sample rate. Writers blocked
+// on a full buffer are woken up to apply the new policy.
+func (w *asyncWriter) setPolicy(conf AsyncConfig) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.policy, w.sampleRate = overflowPolicy(conf)
+	w.notFull.Broadcast()
+}
+
+// Write queues a copy of p, since zap reuses its buffers.
+func (w *asyncWriter) Write(p []byte) (int, error) {
+	entry := append([]byte(nil), p...)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, errors.New("golog: write to closed async writer")
+	}
+
+	if w.policy == OverflowSample && w.count >= len(w.ring)*3/4 {
+		w.sampled++
+		if w.sampled%uint64(w.sampleRate) != 0 {
+			w.dropped++
+			return len(p), nil
+		}
+	}
+
+	for w.count == len(w.ring) {
+		switch w.policy {
+		case OverflowDropOldest:
+			w.ring[w.head] = nil
+			w.head = (w.head + 1) % len(w.ring)
+			w.count--
+			w.dropped++
+			// the evicted entry counts as handled for Sync
+			w.written++
+		case OverflowBlock:
+			w.notFull.Wait()
+			if w.closed {
+				return 0, errors.New("golog: write to closed async writer")
+			}
+		default:
+			w.dropped++
+			return len(p), nil
+		}
+	}
+
+	w.ring[(w.head+w.count)%len(w.ring)] = entry
+	w.count++
+	w.queued++
+	w.notEmpty.Signal()
+	return len(p), nil
+}
+
+// run writes queued entries until the writer is closed and drained.
+func (w *asyncWriter) run() {
+	defer close(w.done)
+
+	var batch [][]byte
+	var chunk []byte
+	for {
+		w.mu.Lock()
+		for w.count == 0 && !w.closed {
+			w.notEmpty.Wait()
+		}
+		if w.count == 0 && w.closed {
+			w.mu.Unlock()
+			return
+		}
+		batch = batch[:0]
+		for w.count > 0 {
+			batch = append(batch, w.ring[w.head])
+			w.ring[w.head] = nil
+			w.head = (w.head + 1) % len(w.ring)
+			w.count--
+		}
+		w.notFull.Broadcast()
+		w.mu.Unlock()
+
+		// entries are written in chunks to save system calls
+		var err error
+		for _, entry := range batch {
+			if w.perEntry {
+				err = errors.Join(err, w.write(entry))
+				continue
+			}
+			if len(chunk) > 0 && len(chunk)+len(entry) > maxAsyncChunk {
+				err = errors.Join(err, w.write(chunk))
+				chunk = chunk[:0]
+			}
+			chunk = append(chunk, entry...)
+		}
+		if len(chunk) > 0 {
+			err = errors.Join(err, w.write(chunk))
+			chunk = chunk[:0]
+		}
+
+		w.mu.Lock()
+		w.written += uint64(len(batch))
+		if w.err == nil {
+			w.err = err
+		}
+		w.drained.Broadcast()
+		w.mu.Unlock()
+	}
+}
+
+func (w *asyncWriter) write(chunk []byte) error {
+	_, err := w.out.Write(chunk)
+	return err
+}
+
+// Sync waits until every entry queued before the call has been written,
+// then syncs the underlying output. It returns the first write error seen
+// since the previous Sync.
+func (w *asyncWriter) Sync() error {
+	w.mu.Lock()
+	target := w.queued
+	for w.written < target {
+		w.drained.Wait()
+	}
+	err := w.err
+	w.err = nil
+	w.mu.Unlock()
+
+	return errors.Join(err, w.out.Sync())
+}
+
+// Close drains the buffer and stops the background goroutine.
+func (w *asyncWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.notEmpty.Signal()
+	w.notFull.Broadcast()
+	w.mu.Unlock()
+
+	<-w.done
+	return w.Sync()
+}
+
+func (w *asyncWriter) stats() (dropped uint64, buffered int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.dropped, w.count
+}
+
+// asyncOutputs holds the async writers of a logger and its children.
+type asyncOutputs struct {
+	mu     sync.Mutex
+	system []*asyncWriter
+	tdr    []*asyncWriter
+}
+
+// wrap returns w unchanged for synchronous loggers, or an async writer
+// recorded in stream.
+func (o *asyncOutputs) wrap(w zapcore.WriteSyncer, conf *AsyncConfig, stream *[]*asyncWriter) zapcore.WriteSyncer {
+	if conf == nil {
+		return w
+	}
+	aw := newAsyncWriter(w, *conf)
+	o.mu.Lock()
+	*stream = append(*stream, aw)
+	o.mu.Unlock()
+	return aw
+}
+
+// writers returns the async writers of both streams.
+func (o *asyncOutputs) writers() []*asyncWriter {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append(o.system[:len(o.system):len(o.system)], o.tdr...)
+}
+
+func (o *asyncOutputs) setPolicy(conf AsyncConfig) {
+	for _, w := range o.writers() {
+		w.setPolicy(conf)
+	}
+}
+
+func (o *asyncOutputs) stats() AsyncStats {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var stats AsyncStats
+	for _, w := range o.system {
+		dropped, buffered := w.stats()
+		stats.DroppedSystem += dropped
+		stats.Buffered += buffered
+	}
+	for _, w := range o.tdr {
+		dropped, buffered := w.stats()
+		stats.DroppedTDR += dropped
+		stats.Buffered += buffered
+	}
+	return stats
+}
+
+func (o *asyncOutputs) close() error {
+	var errs []error
+	for _, w := range o.writers() {
+		errs = append(errs, w.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,248 @@
+package golog
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// gatedWriter records newline-terminated entries and blocks every write
+// until release is closed.
+type gatedWriter struct {
+	mu      sync.Mutex
+	entries []string
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func newGatedWriter() *gatedWriter {
+	return &gatedWriter{started: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (w *gatedWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() { close(w.started) })
+	<-w.release
+	w.mu.Lock()
+	w.entries = append(w.entries, strings.Split(strings.TrimSuffix(string(p), "\n"), "\n")...)
+	w.mu.Unlock()
+	return len(p), nil
+}
+
+func (w *gatedWriter) Sync() error { return nil }
+
+func (w *gatedWriter) written() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.entries...)
+}
+
+// fillAsyncWriter writes entry "0", waits until the background goroutine is
+// stuck writing it, then writes "1" to "n".
+func fillAsyncWriter(t *testing.T, conf AsyncConfig, n int) (*asyncWriter, *gatedWriter) {
+	t.Helper()
+
+	out := newGatedWriter()
+	w := newAsyncWriter(out, conf)
+	t.Cleanup(func() { _ = w.Close() })
+
+	_, err := w.Write([]byte("0\n"))
+	require.NoError(t, err)
+	<-out.started
+	for i := 1; i <= n; i++ {
+		_, err := w.Write([]byte(strconv.Itoa(i) + "\n"))
+		require.NoError(t, err)
+	}
+	return w, out
+}
+
+func TestAsyncWriterDropNewest(t *testing.T) {
+	w, out := fillAsyncWriter(t, AsyncConfig{BufferSize: 2, OverflowPolicy: OverflowDropNewest}, 5)
+
+	dropped, buffered := w.stats()
+	assert.Equal(t, uint64(3), dropped)
+	assert.Equal(t, 2, buffered)
+
+	close(out.release)
+	require.NoError(t, w.Sync())
+	assert.Equal(t, []string{"0", "1", "2"}, out.written())
+}
+
+func TestAsyncWriterDropOldest(t *testing.T) {
+	w, out := fillAsyncWriter(t, AsyncConfig{BufferSize: 2, OverflowPolicy: OverflowDropOldest}, 5)
+
+	dropped, _ := w.stats()
+	assert.Equal(t, uint64(3), dropped)
+
+	close(out.release)
+	require.NoError(t, w.Sync())
+	assert.Equal(t, []string{"0", "4", "5"}, out.written())
+}
+
+func TestAsyncWriterSample(t *testing.T) {
+	w, out := fillAsyncWriter(t, AsyncConfig{BufferSize: 4, OverflowPolicy: OverflowSample, SampleRate: 2}, 7)
+
+	dropped, _ := w.stats()
+	assert.Equal(t, uint64(3), dropped)
+
+	close(out.release)
+	require.NoError(t, w.Sync())
+	assert.Equal(t, []string{"0", "1", "2", "3", "5"}, out.written(), "one in two entries is kept above the high-water mark")
+}
+
+func TestAsyncWriterBlock(t *testing.T) {
+	w, out := fillAsyncWriter(t, AsyncConfig{BufferSize: 1}, 1)
+
+	wrote := make(chan struct{})
+	go func() {
+		_, _ = w.Write([]byte("2\n"))
+		close(wrote)
+	}()
+
+	select {
+	case <-wrote:
+		t.Fatal("write did not block on a full buffer")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(out.release)
+	<-wrote
+	require.NoError(t, w.Sync())
+	assert.Equal(t, []string{"0", "1", "2"}, out.written())
+
+	dropped, _ := w.stats()
+	assert.Zero(t, dropped)
+}
+
+func TestAsyncWriterClose(t *testing.T) {
+	out := newGatedWriter()
+	close(out.release)
+	w := newAsyncWriter(out, AsyncConfig{})
+
+	for i := 0; i < 100; i++ {
+		_, err := w.Write([]byte(strconv.Itoa(i) + "\n"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	assert.Len(t, out.written(), 100)
+
+	_, err := w.Write([]byte("late"))
+	assert.Error(t, err)
+	assert.NoError(t, w.Close())
+}
+
+func TestAsyncConfigValidate(t *testing.T) {
+	assert.NoError(t, AsyncConfig{}.Validate())
+	assert.NoError(t, AsyncConfig{OverflowPolicy: OverflowDropOldest, BufferSize: 16}.Validate())
+	assert.Error(t, AsyncConfig{OverflowPolicy: "spill"}.Validate())
+	assert.Error(t, AsyncConfig{BufferSize: -1}.Validate())
+}
+
+func TestAsyncLogger(t *testing.T) {
+	tmpDir := t.TempDir()
+	logger := NewLogger(Config{
+		App:          "testapp",
+		AppVer:       "1.0.0",
+		Env:          "development",
+		FileLocation: tmpDir,
+		FileMaxSize:  10,
+		Async:        &AsyncConfig{BufferSize: 16},
+	})
+	l := logger.(*Log)
+	defer l.Close()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				logger.WithContext(context.Background()).Info("async", zap.Int("i", i))
+				logger.TDR(LogModel{CorrelationID: "c"})
+			}
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, logger.Sync())
+
+	assert.Len(t, readLogLines(t, filepath.Join(tmpDir, "system.log")), 200)
+	assert.Len(t, readLogLines(t, filepath.Join(tmpDir, "tdr.log")), 200)
+	assert.Equal(t, AsyncStats{}, l.AsyncStats())
+}
+
+func newBenchmarkLogger(b *testing.B, async *AsyncConfig) LoggerInterface {
+	logger := NewLogger(Config{
+		App:          "testapp",
+		AppVer:       "1.0.0",
+		Env:          "development",
+		FileLocation: b.TempDir(),
+		FileMaxSize:  100,
+		Async:        async,
+	})
+	b.Cleanup(func() { _ = logger.(*Log).Close() })
+	return logger.WithContext(WithTraceID(context.Background(), "trace-123"))
+}
+
+func BenchmarkLoggingSync(b *testing.B) {
+	logger := newBenchmarkLogger(b, nil)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Info("Benchmark message", zap.Int("iteration", i))
+	}
+	_ = logger.Sync()
+}
+
+func BenchmarkLoggingAsync(b *testing.B) {
+	logger := newBenchmarkLogger(b, &AsyncConfig{})
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Info("Benchmark message", zap.Int("iteration", i))
+	}
+	_ = logger.Sync()
+}
+
+func BenchmarkLoggingSyncParallel(b *testing.B) {
+	logger := newBenchmarkLogger(b, nil)
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			logger.Info("Benchmark message")
+		}
+	})
+	_ = logger.Sync()
+}
+
+func BenchmarkLoggingAsyncParallel(b *testing.B) {
+	logger := newBenchmarkLogger(b, &AsyncConfig{})
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			logger.Info("Benchmark message")
+		}
+	})
+	_ = logger.Sync()
+}
+
+func BenchmarkLoggingAsyncDropNewest(b *testing.B) {
+	logger := newBenchmarkLogger(b, &AsyncConfig{OverflowPolicy: OverflowDropNewest})
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			logger.Info("Benchmark message")
+		}
+	})
+	_ = logger.Sync()
+}
@@ -0,0 +1,280 @@
+package golog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/goccy/go-json"
+)
+
+// Default body limits used when Config.BodyLimits is nil or a limit is zero.
+const (
+	DefaultMaxBodySize   = 64 * 1024
+	DefaultHashBodyAbove = 1024 * 1024
+)
+
+// DefaultSkipContentTypes lists the binary content types whose bodies are
+// never logged.
+var DefaultSkipContentTypes = []string{
+	"image/*",
+	"audio/*",
+	"video/*",
+	"font/*",
+	"application/octet-stream",
+	"application/pdf",
+	"application/zip",
+	"application/gzip",
+	"application/protobuf",
+	"application/x-protobuf",
+	"application/vnd.google.protobuf",
+	"application/grpc*",
+}
+
+// BodyLimits bounds the request and response bodies written to TDR records.
+type BodyLimits struct {
+	// Bytes of request body logged. Longer bodies are truncated and marked
+	// with "truncated": true and their original size. Defaults to
+	// DefaultMaxBodySize; a negative value disables the limit.
+	// Bodies that are neither Body, []byte nor string, such as structs and
+	// maps, are measured by the JSON encoding of their masked form.
+	MaxRequestSize int `json:"maxRequestSize"`
+
+	// Bytes of response body logged, as MaxRequestSize.
+	MaxResponseSize int `json:"maxResponseSize"`
+
+	// Bodies larger than this many bytes are not logged at all, only their
+	// size and SHA-256. Defaults to DefaultHashBodyAbove; a negative value
+	// disables hashing.
+	HashAbove int `json:"hashAbove"`
+
+	// Content types whose bodies are replaced by their type and size,
+	// matched with path.Match syntax, e.g. "image/*". Defaults to
+	// DefaultSkipContentTypes; use an empty non-nil slice to log every type.
+	SkipContentTypes []string `json:"skipContentTypes"`
+}
+
+// Body is a TDR body annotated by a caller that knows more than its bytes,
+// such as a middleware that stops buffering after a limit. Pass it as
+// LogModel.Request or LogModel.Response.
+type Body struct {
+	// Data holds the body, or its first bytes when Size is larger.
+	Data []byte
+
+	// Size of the whole body in bytes. Zero means len(Data).
+	Size int64
+
+	// Content type of the body, used to parse it and to skip binary bodies.
+	ContentType string
+
+	// Hex SHA-256 of the whole body, if known. It is computed from Data
+	// when the body is complete.
+	SHA256 string
+}
+
+// bodyLimits is the resolved form of BodyLimits.
+type bodyLimits struct {
+	maxRequest  int
+	maxResponse int
+	hashAbove   int
+	skip        []string
+}
+
+func newBodyLimits(l *BodyLimits) bodyLimits {
+	var conf BodyLimits
+	if l != nil {
+		conf = *l
+	}
+	limits := bodyLimits{
+		maxRequest:  limitOrDefault(conf.MaxRequestSize, DefaultMaxBodySize),
+		maxResponse: limitOrDefault(conf.MaxResponseSize, DefaultMaxBodySize),
+		hashAbove:   limitOrDefault(conf.HashAbove, DefaultHashBodyAbove),
+		skip:        conf.SkipContentTypes,
+	}
+	if limits.skip == nil {
+		limits.skip = DefaultSkipContentTypes
+	}
+	return limits
+}
+
+func limitOrDefault(limit, def int) int {
+	if limit == 0 {
+		return def
+	}
+	return limit
+}
+
+// skips reports whether bodies of the given media type are not logged.
+func (b bodyLimits) skips(mediaType string) bool {
+	for _, pattern := range b.skip {
+		if ok, _ := path.Match(pattern, mediaType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// body masks a TDR body and applies the size limit maxSize.
+func (g *generation) body(body interface{}, contentType string, maxSize int) interface{} {
+	var b Body
+	annotated := true
+	switch v := body.(type) {
+	case Body:
+		b = v
+	case *Body:
+		if v == nil {
+			return nil
+		}
+		b = *v
+	case []byte:
+		b, annotated = Body{Data: v}, false
+	case string:
+		b, annotated = Body{Data: []byte(v)}, false
+	default:
+		return g.typedBody(body, contentType, maxSize)
+	}
+	if b.ContentType == "" {
+		b.ContentType = contentType
+	}
+
+	size := b.Size
+	if size < int64(len(b.Data)) {
+		size = int64(len(b.Data))
+	}
+	if size == 0 {
+		if annotated {
+			return nil
+		}
+		return body
+	}
+	complete := size == int64(len(b.Data))
+
+	mediaType := b.ContentType
+	if mediaType == "" {
+		mediaType = http.DetectContentType(b.Data)
+	}
+	mediaType, _, _ = mime.ParseMediaType(mediaType)
+	if g.limits.skips(mediaType) {
+		return map[string]interface{}{"skipped": true, "contentType": mediaType, "size": size}
+	}
+
+	if g.limits.hashAbove >= 0 && size > int64(g.limits.hashAbove) {
+		summary := map[string]interface{}{"truncated": true, "size": size}
+		if b.SHA256 == "" && complete {
+			sum := sha256.Sum256(b.Data)
+			b.SHA256 = hex.EncodeToString(sum[:])
+		}
+		if b.SHA256 != "" {
+			summary["sha256"] = b.SHA256
+		}
+		return summary
+	}
+
+	if complete && (maxSize < 0 || size <= int64(maxSize)) {
+		if _, ok := body.(string); ok {
+			return toJSON(g.masker.maskBody(body, b.ContentType))
+		}
+		return toJSON(g.masker.maskBody(b.Data, b.ContentType))
+	}
+
+	data := b.Data
+	if maxSize >= 0 && len(data) > maxSize {
+		data = data[:maxSize]
+	}
+	return map[string]interface{}{
+		"truncated": true,
+		"size":      size,
+		"body":      g.masker.maskPartial(string(trimIncompleteRune(data)), b.ContentType),
+	}
+}
+
+// typedBody masks a body of another type than Body, []byte and string, such
+// as a struct or a map, and applies the size limits to the JSON encoding of
+// the masked body.
+func (g *generation) typedBody(body interface{}, contentType string, maxSize int) interface{} {
+	masked := toJSON(g.masker.maskBody(body, contentType))
+	if masked == nil {
+		return nil
+	}
+	data, err := json.Marshal(masked)
+	if err != nil {
+		return masked
+	}
+	size := int64(len(data))
+
+	if g.limits.hashAbove >= 0 && size > int64(g.limits.hashAbove) {
+		sum := sha256.Sum256(data)
+		return map[string]interface{}{"truncated": true, "size": size, "sha256": hex.EncodeToString(sum[:])}
+	}
+	if maxSize < 0 || size <= int64(maxSize) {
+		return masked
+	}
+	return map[string]interface{}{
+		"truncated": true,
+		"size":      size,
+		"body":      string(trimIncompleteRune(data[:maxSize])),
+	}
+}
+
+// trimIncompleteRune drops a UTF-8 sequence cut off at the end of data.
+func trimIncompleteRune(data []byte) []byte {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				return data[:i]
+			}
+			break
+		}
+	}
+	return data
+}
+
+// jsonMember matches a "key": value pair in JSON that may be cut off, with
+// the value being a string (possibly unterminated) or a scalar.
+var jsonMember = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"(\s*:\s*)("(?:[^"\\]|\\.)*"?|[-+.0-9eE]+|true|false|null)`)
+
+// maskPartial masks the first bytes of a body that was cut off. Forms and
+// XML are masked as usual; JSON cannot be parsed, so its members are
+// masked by key one by one.
+func (m *masker) maskPartial(text, contentType string) interface{} {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	trimmed := strings.TrimSpace(text)
+
+	switch {
+	case mediaType == "application/x-www-form-urlencoded" || (mediaType == "" && looksLikeForm(trimmed)):
+		if masked, ok := m.maskForm(trimmed); ok {
+			return masked
+		}
+	case mediaType == "text/xml" || mediaType == "application/xml" || strings.HasSuffix(mediaType, "+xml") ||
+		(mediaType == "" && strings.HasPrefix(trimmed, "<")):
+		return m.maskPartialXML(text)
+	}
+
+	return m.scanText(m.maskJSONMembers(text))
+}
+
+func (m *masker) maskJSONMembers(text string) string {
+	return jsonMember.ReplaceAllStringFunc(text, func(member string) string {
+		groups := jsonMember.FindStringSubmatch(member)
+		rule, ok := m.sensitiveRule(groups[1])
+		if !ok {
+			return member
+		}
+		value := strings.TrimSuffix(strings.TrimPrefix(groups[3], `"`), `"`)
+		if unquoted, err := strconv.Unquote(`"` + value + `"`); err == nil {
+			value = unquoted
+		}
+		strategy := m.bodyStrategy(rule)
+		masked := maskedValue
+		if strategy.kind != StrategyDrop {
+			masked = stringOf(strategy.apply(value, m.hashSalt))
+		}
+		return `"` + groups[1] + `"` + groups[2] + strconv.Quote(masked)
+	})
+}
@@ -0,0 +1,209 @@
+package golog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBodyTestLogger(limits *BodyLimits) *generation {
+	return &generation{masker: policyMasker(nil), limits: newBodyLimits(limits)}
+}
+
+func TestBodyWithinLimit(t *testing.T) {
+	l := newBodyTestLogger(nil)
+
+	assert.Equal(t, map[string]interface{}{"password": "*****"}, l.body([]byte(`{"password":"x"}`), "", DefaultMaxBodySize))
+	assert.Equal(t, "plain text", l.body("plain text", "", DefaultMaxBodySize))
+	assert.Equal(t, "", l.body("", "", DefaultMaxBodySize))
+	assert.Nil(t, l.body(Body{}, "", DefaultMaxBodySize))
+	assert.Nil(t, l.body(nil, "", DefaultMaxBodySize))
+}
+
+func TestBodyTruncated(t *testing.T) {
+	l := newBodyTestLogger(nil)
+
+	body := `{"username":"john","password":"hunter2","items":[1,2,3]}`
+	assert.Equal(t, map[string]interface{}{
+		"truncated": true,
+		"size":      int64(len(body)),
+		"body":      `{"username":"john","password":"*****","it`,
+	}, l.body([]byte(body), "application/json", 43))
+
+	// the password is cut off in the middle of its value
+	assert.Equal(t, map[string]interface{}{
+		"truncated": true,
+		"size":      int64(len(body)),
+		"body":      `{"username":"john","password":"*****"`,
+	}, l.body(body, "", 33))
+
+	form := "username=john&password=hunter2&next=%2Fhome"
+	assert.Equal(t, map[string]interface{}{
+		"truncated": true,
+		"size":      int64(len(form)),
+		"body":      map[string]interface{}{"username": "john", "password": "*****", "ne": ""},
+	}, l.body(form, "application/x-www-form-urlencoded", 33))
+
+	xml := "<login><user>john</user><password>hunter2</password></login>"
+	assert.Equal(t, map[string]interface{}{
+		"truncated": true,
+		"size":      int64(len(xml)),
+		"body":      "<login><user>john</user><password>*****",
+	}, l.body(xml, "text/xml", 38))
+
+	// a multi-byte character is never split
+	assert.Equal(t, "caf", l.body("café au lait", "", 4).(map[string]interface{})["body"])
+}
+
+func TestBodyPerDirectionLimits(t *testing.T) {
+	tmpDir := t.TempDir()
+	logger := NewLogger(Config{
+		App:          "testapp",
+		AppVer:       "1.0.0",
+		Env:          "development",
+		FileLocation: tmpDir,
+		FileMaxSize:  10,
+		BodyLimits:   &BodyLimits{MaxRequestSize: 4, MaxResponseSize: -1},
+	})
+
+	logger.TDR(LogModel{Request: "request body", Response: "response body"})
+	require.NoError(t, logger.Sync())
+
+	lines := readLogLines(t, filepath.Join(tmpDir, "tdr.log"))
+	require.Len(t, lines, 1)
+	assert.Equal(t, map[string]interface{}{"truncated": true, "size": float64(12), "body": "requ"}, lines[0]["request"])
+	assert.Equal(t, "response body", lines[0]["response"])
+}
+
+func TestBodyHashedAboveLimit(t *testing.T) {
+	l := newBodyTestLogger(&BodyLimits{HashAbove: 16})
+
+	body := strings.Repeat("a", 32)
+	sum := sha256.Sum256([]byte(body))
+	assert.Equal(t, map[string]interface{}{
+		"truncated": true,
+		"size":      int64(32),
+		"sha256":    hex.EncodeToString(sum[:]),
+	}, l.body(body, "", DefaultMaxBodySize))
+
+	// a partial body keeps the hash computed by its producer
+	assert.Equal(t, map[string]interface{}{
+		"truncated": true,
+		"size":      int64(1 << 20),
+		"sha256":    "abc",
+	}, l.body(Body{Data: []byte("aaaa"), Size: 1 << 20, SHA256: "abc"}, "", DefaultMaxBodySize))
+
+	l = newBodyTestLogger(&BodyLimits{HashAbove: -1, MaxRequestSize: -1})
+	assert.Equal(t, body, l.body(body, "", -1))
+}
+
+func TestBodyTypedLimits(t *testing.T) {
+	type note struct {
+		Password string `json:"password"`
+		Text     string `json:"text"`
+	}
+	l := newBodyTestLogger(&BodyLimits{HashAbove: 256})
+
+	small := note{Password: "hunter2", Text: "hi"}
+	assert.Equal(t, map[string]interface{}{"password": "*****", "text": "hi"}, l.body(small, "", DefaultMaxBodySize))
+
+	large := note{Password: "hunter2", Text: strings.Repeat("a", 100)}
+	encoded := `{"password":"*****","text":"` + strings.Repeat("a", 100) + `"}`
+	assert.Equal(t, map[string]interface{}{
+		"truncated": true,
+		"size":      int64(len(encoded)),
+		"body":      encoded[:40],
+	}, l.body(large, "", 40))
+	assert.Equal(t, map[string]interface{}{
+		"truncated": true,
+		"size":      int64(len(encoded)),
+		"body":      encoded[:40],
+	}, l.body(map[string]interface{}{"password": "hunter2", "text": large.Text}, "", 40))
+
+	huge := &note{Password: "hunter2", Text: strings.Repeat("a", 300)}
+	encoded = `{"password":"*****","text":"` + huge.Text + `"}`
+	sum := sha256.Sum256([]byte(encoded))
+	assert.Equal(t, map[string]interface{}{
+		"truncated": true,
+		"size":      int64(len(encoded)),
+		"sha256":    hex.EncodeToString(sum[:]),
+	}, l.body(huge, "", DefaultMaxBodySize))
+
+	l = newBodyTestLogger(&BodyLimits{HashAbove: -1})
+	assert.Equal(t, map[string]interface{}{"password": "*****", "text": huge.Text}, l.body(huge, "", -1))
+}
+
+func TestBodyPartial(t *testing.T) {
+	l := newBodyTestLogger(nil)
+
+	assert.Equal(t, map[string]interface{}{
+		"truncated": true,
+		"size":      int64(100),
+		"body":      `{"token":"*****"`,
+	}, l.body(Body{Data: []byte(`{"token":"abc"`), Size: 100}, "", DefaultMaxBodySize))
+}
+
+func TestBodySkipsBinary(t *testing.T) {
+	l := newBodyTestLogger(nil)
+
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	assert.Equal(t, map[string]interface{}{"skipped": true, "contentType": "image/png", "size": int64(len(png))}, l.body(png, "", DefaultMaxBodySize))
+	assert.Equal(t, map[string]interface{}{"skipped": true, "contentType": "application/x-protobuf", "size": int64(3)}, l.body(Body{Data: []byte{1, 2, 3}, ContentType: "application/x-protobuf"}, "", DefaultMaxBodySize))
+	assert.Equal(t, map[string]interface{}{"skipped": true, "contentType": "application/octet-stream", "size": int64(3)}, l.body([]byte{0, 1, 2}, "", DefaultMaxBodySize))
+
+	l = newBodyTestLogger(&BodyLimits{SkipContentTypes: []string{}})
+	assert.Equal(t, "\x00\x01\x02", l.body("\x00\x01\x02", "", DefaultMaxBodySize))
+}
+
+func TestTDRLargeStructBody(t *testing.T) {
+	tmpDir := t.TempDir()
+	logger := NewLogger(Config{
+		App:          "testapp",
+		AppVer:       "1.0.0",
+		Env:          "development",
+		FileLocation: tmpDir,
+		FileMaxSize:  10,
+		BodyLimits:   &BodyLimits{MaxRequestSize: 64},
+	})
+
+	type upload struct {
+		Token string   `json:"token"`
+		Lines []string `json:"lines"`
+	}
+	logger.TDR(LogModel{Request: upload{Token: "secret", Lines: make([]string, 1000)}})
+	require.NoError(t, logger.Sync())
+
+	lines := readLogLines(t, filepath.Join(tmpDir, "tdr.log"))
+	require.Len(t, lines, 1)
+	request := lines[0]["request"].(map[string]interface{})
+	assert.Equal(t, true, request["truncated"])
+	assert.Greater(t, request["size"], float64(64))
+	assert.Len(t, request["body"], 64)
+	assert.NotContains(t, request["body"], "secret")
+}
+
+func TestTDRBodyContentType(t *testing.T) {
+	tmpDir := t.TempDir()
+	logger := NewLogger(Config{
+		App:          "testapp",
+		AppVer:       "1.0.0",
+		Env:          "development",
+		FileLocation: tmpDir,
+		FileMaxSize:  10,
+	})
+
+	header := http.Header{}
+	header.Set("Content-Type", "image/jpeg")
+	logger.TDR(LogModel{Header: header, Request: []byte("jpeg bytes")})
+	require.NoError(t, logger.Sync())
+
+	lines := readLogLines(t, filepath.Join(tmpDir, "tdr.log"))
+	require.Len(t, lines, 1)
+	assert.Equal(t, map[string]interface{}{"skipped": true, "contentType": "image/jpeg", "size": float64(10)}, lines[0]["request"])
+}
@@ -0,0 +1,130 @@
+package golog
+
+import (
+	"strings"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// ComponentKey is the field holding the name given to a logger with Named.
+const ComponentKey = "component"
+
+// componentLevels resolves the level of a system log entry from the name of
+// its component, falling back to the global level. It is shared by a logger
+// and all of its children.
+type componentLevels struct {
+	global zap.AtomicLevel
+	levels atomic.Pointer[levelMap]
+}
+
+// levelMap is an immutable snapshot of the component levels, replaced as a
+// whole when they change.
+type levelMap struct {
+	byName map[string]zapcore.Level
+	min    zapcore.Level
+}
+
+func newComponentLevels(global zap.AtomicLevel, levels map[string]zapcore.Level) *componentLevels {
+	c := &componentLevels{global: global}
+	c.set(levels)
+	return c
+}
+
+// set replaces every component level with a copy of levels.
+func (c *componentLevels) set(levels map[string]zapcore.Level) {
+	m := &levelMap{byName: make(map[string]zapcore.Level, len(levels)), min: zapcore.InvalidLevel}
+	for name, level := range levels {
+		m.byName[name] = level
+		if m.min == zapcore.InvalidLevel || level < m.min {
+			m.min = level
+		}
+	}
+	c.levels.Store(m)
+}
+
+// get returns a copy of the component levels.
+func (c *componentLevels) get() map[string]zapcore.Level {
+	m := c.levels.Load()
+	levels := make(map[string]zapcore.Level, len(m.byName))
+	for name, level := range m.byName {
+		levels[name] = level
+	}
+	return levels
+}
+
+// Enabled reports whether any component logs at level. The cores below a
+// componentCore filter with it, leaving the per-component decision to
+// componentCore.Check.
+func (c *componentLevels) Enabled(level zapcore.Level) bool {
+	min := c.global.Level()
+	if m := c.levels.Load(); m.min != zapcore.InvalidLevel && m.min < min {
+		min = m.min
+	}
+	return level >= min
+}
+
+// levelFor returns the level of the longest configured name that is name
+// itself or one of its dot-separated prefixes, e.g. "payment" applies to
+// "payment" and "payment.stripe" but not to "payments".
+func (c *componentLevels) levelFor(name string) zapcore.Level {
+	m := c.levels.Load()
+	if name != "" && len(m.byName) > 0 {
+		for {
+			if level, ok := m.byName[name]; ok {
+				return level
+			}
+			i := strings.LastIndexByte(name, '.')
+			if i < 0 {
+				break
+			}
+			name = name[:i]
+		}
+	}
+	return c.global.Level()
+}
+
+// componentCore drops the entries below the level of their component.
+type componentCore struct {
+	zapcore.Core
+	levels *componentLevels
+}
+
+func (c *componentCore) Enabled(level zapcore.Level) bool {
+	return c.levels.Enabled(level)
+}
+
+func (c *componentCore) With(fields []zapcore.Field) zapcore.Core {
+	return &componentCore{Core: c.Core.With(fields), levels: c.levels}
+}
+
+func (c *componentCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if entry.Level < c.levels.levelFor(entry.LoggerName) {
+		return ce
+	}
+	return c.Core.Check(entry, ce)
+}
+
+// Named returns a child logger for a component of the application. Its
+// system and TDR entries carry the name in the component field, and its
+// system entries are filtered by the level configured for the name in
+// Config.ComponentLevels. Names of nested calls are joined with dots, e.g.
+// Named("payment").Named("stripe") is "payment.stripe".
+func (l *Log) Named(name string) LoggerInterface {
+	child := *l
+	child.logger = l.logger.Named(name)
+	child.loggerTDR = l.loggerTDR.Named(name)
+	return &child
+}
+
+// ComponentLevels returns the levels set per component name.
+func (l *Log) ComponentLevels() map[string]zapcore.Level {
+	return l.state.levels.get()
+}
+
+// SetComponentLevels replaces the levels set per component name. Components
+// without a level use the global one.
+func (l *Log) SetComponentLevels(levels map[string]zapcore.Level) {
+	l.state.levels.set(levels)
+}
@@ -0,0 +1,120 @@
+package golog
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestComponentLevelFor(t *testing.T) {
+	levels := newComponentLevels(zap.NewAtomicLevelAt(zapcore.InfoLevel), map[string]zapcore.Level{
+		"payment":        zapcore.DebugLevel,
+		"payment.stripe": zapcore.ErrorLevel,
+		"db":             zapcore.WarnLevel,
+	})
+
+	assert.Equal(t, zapcore.InfoLevel, levels.levelFor(""))
+	assert.Equal(t, zapcore.DebugLevel, levels.levelFor("payment"))
+	assert.Equal(t, zapcore.DebugLevel, levels.levelFor("payment.paypal"))
+	assert.Equal(t, zapcore.ErrorLevel, levels.levelFor("payment.stripe.webhook"))
+	assert.Equal(t, zapcore.InfoLevel, levels.levelFor("payments"))
+	assert.Equal(t, zapcore.WarnLevel, levels.levelFor("db"))
+
+	assert.True(t, levels.Enabled(zapcore.DebugLevel), "payment logs at debug")
+	levels.set(nil)
+	assert.False(t, levels.Enabled(zapcore.DebugLevel))
+	assert.Equal(t, zapcore.InfoLevel, levels.levelFor("payment"))
+}
+
+func TestNamed(t *testing.T) {
+	tmpDir := t.TempDir()
+	logger := NewLogger(Config{
+		App:             "testapp",
+		AppVer:          "1.0.0",
+		Env:             "production",
+		FileLocation:    tmpDir,
+		FileMaxSize:     10,
+		ComponentLevels: map[string]zapcore.Level{"payment": zapcore.DebugLevel, "db": zapcore.WarnLevel},
+	})
+
+	payment := logger.Named("payment")
+	payment.WithContext(context.Background()).Debug("payment debug")
+	payment.Named("stripe").Debug("stripe debug")
+	logger.Named("db").Info("db info")
+	logger.Named("db").Warn("db warn")
+	logger.Debug("root debug")
+	logger.Info("root info")
+	payment.TDR(LogModel{CorrelationID: "c"})
+	require.NoError(t, logger.Sync())
+
+	lines := readLogLines(t, filepath.Join(tmpDir, "system.log"))
+	var got []string
+	for _, line := range lines {
+		component, _ := line[ComponentKey].(string)
+		got = append(got, component+": "+line["message"].(string))
+	}
+	assert.Equal(t, []string{
+		"payment: payment debug",
+		"payment.stripe: stripe debug",
+		"db: db warn",
+		": root info",
+	}, got)
+
+	lines = readLogLines(t, filepath.Join(tmpDir, "tdr.log"))
+	require.Len(t, lines, 1)
+	assert.Equal(t, "payment", lines[0][ComponentKey])
+}
+
+func TestSetComponentLevels(t *testing.T) {
+	l, tmpDir := newLevelTestLogger(t)
+	db := l.Named("db")
+
+	db.Debug("hidden")
+	l.SetComponentLevels(map[string]zapcore.Level{"db": zapcore.DebugLevel})
+	db.Debug("shown")
+	l.Debug("hidden")
+	l.SetComponentLevels(nil)
+	db.Debug("hidden")
+	require.NoError(t, l.Sync())
+
+	lines := readLogLines(t, filepath.Join(tmpDir, "system.log"))
+	require.Len(t, lines, 1)
+	assert.Equal(t, "shown", lines[0]["message"])
+	assert.Empty(t, l.ComponentLevels())
+}
+
+func TestLevelHandlerComponents(t *testing.T) {
+	l, _ := newLevelTestLogger(t)
+	handler := LevelHandler(l)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/log/level", strings.NewReader(`{"level":"warn","components":{"payment":"debug"}}`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"level":"warn","tdrLevel":"info","components":{"payment":"debug"}}`, rec.Body.String())
+	assert.Equal(t, map[string]zapcore.Level{"payment": zapcore.DebugLevel}, l.ComponentLevels())
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/log/level", strings.NewReader(`{"components":{"db":"loud"}}`)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, map[string]zapcore.Level{"payment": zapcore.DebugLevel}, l.ComponentLevels())
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/log/level", strings.NewReader(`{"components":{}}`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"level":"warn","tdrLevel":"info"}`, rec.Body.String())
+}
+
+func TestConfigComponentLevelsJSON(t *testing.T) {
+	var conf Config
+	require.NoError(t, json.Unmarshal([]byte(`{"componentLevels":{"payment":"debug","db":"warn"}}`), &conf))
+	assert.Equal(t, map[string]zapcore.Level{"payment": zapcore.DebugLevel, "db": zapcore.WarnLevel}, conf.ComponentLevels)
+}
@@ -0,0 +1,208 @@
+package golog
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/goccy/go-json"
+	"go.yaml.in/yaml/v3"
+)
+
+// LoadConfigFromFile reads a Config from a JSON or YAML file, chosen by its
+// extension (.json, .yaml or .yml). Keys are the JSON names of the Config
+// fields, and levels are strings such as "debug":
+//
+//	app: payment-service
+//	env: production
+//	fileLocation: /var/log/payment
+//	logLevel: debug
+//	componentLevels:
+//	  db: warn
+//
+// The returned Config has its defaults set and is validated.
+func LoadConfigFromFile(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("golog: read config: %w", err)
+	}
+	return parseConfig(path, data)
+}
+
+// parseConfig decodes the content of the config file at path.
+func parseConfig(path string, data []byte) (Config, error) {
+	var conf Config
+	var err error
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+	case ".yaml", ".yml":
+		// YAML is converted to JSON so both formats share the json tags
+		var doc interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return conf, fmt.Errorf("golog: parse config %s: %w", path, err)
+		}
+		if data, err = json.Marshal(doc); err != nil {
+			return conf, fmt.Errorf("golog: parse config %s: %w", path, err)
+		}
+	default:
+		return conf, fmt.Errorf("golog: unsupported config file extension %q", ext)
+	}
+
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return conf, fmt.Errorf("golog: parse config %s: %w", path, err)
+	}
+	return conf, conf.Validate()
+}
+
+// LoadConfigFromEnv reads a Config from environment variables named after
+// the JSON names of its fields in upper snake case, after prefix and an
+// underscore. With prefix "GOLOG":
+//
+//	GOLOG_APP=payment-service
+//	GOLOG_FILE_TDR_LOCATION=/var/log/payment
+//	GOLOG_LOG_LEVEL=debug
+//	GOLOG_COMPONENT_LEVELS=payment=debug,db=warn
+//	GOLOG_ASYNC_OVERFLOW_POLICY=dropOldest
+//	GOLOG_MASKING_KEYS=password,pin
+//
+// Lists are comma-separated and maps are comma-separated key=value pairs.
+// Nested settings such as Async are only set when one of their variables
+// is. The returned Config has its defaults set and is validated.
+func LoadConfigFromEnv(prefix string) (Config, error) {
+	if prefix != "" && !strings.HasSuffix(prefix, "_") {
+		prefix += "_"
+	}
+
+	var conf Config
+	if _, err := loadEnv(reflect.ValueOf(&conf).Elem(), prefix); err != nil {
+		return conf, err
+	}
+	return conf, conf.Validate()
+}
+
+// loadEnv sets the fields of the struct v from the variables under prefix
+// and reports whether any was set.
+func loadEnv(v reflect.Value, prefix string) (bool, error) {
+	var set bool
+	var errs []error
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		key := prefix + envName(name)
+		fv := v.Field(i)
+
+		if fv.Kind() == reflect.Pointer && fv.Type().Elem().Kind() == reflect.Struct {
+			nested := reflect.New(fv.Type().Elem())
+			ok, err := loadEnv(nested.Elem(), key+"_")
+			if err != nil {
+				errs = append(errs, err)
+			}
+			if ok {
+				fv.Set(nested)
+				set = true
+			}
+			continue
+		}
+
+		text, ok := os.LookupEnv(key)
+		if !ok {
+			continue
+		}
+		if err := setEnvValue(fv, text); err != nil {
+			errs = append(errs, fmt.Errorf("golog: %s: %w", key, err))
+			continue
+		}
+		set = true
+	}
+	return set, errors.Join(errs...)
+}
+
+// setEnvValue parses text into v.
+func setEnvValue(v reflect.Value, text string) error {
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(text))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(text)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(text, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Slice:
+		items := splitList(text)
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setEnvValue(slice.Index(i), item); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	case reflect.Map:
+		m := reflect.MakeMap(v.Type())
+		for _, pair := range splitList(text) {
+			key, value, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("%q is not a key=value pair", pair)
+			}
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := setEnvValue(elem, strings.TrimSpace(value)); err != nil {
+				return err
+			}
+			m.SetMapIndex(reflect.ValueOf(strings.TrimSpace(key)), elem)
+		}
+		v.Set(m)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// splitList splits a comma-separated list, trimming spaces and dropping
+// empty items.
+func splitList(text string) []string {
+	var items []string
+	for _, item := range strings.Split(text, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// envName turns a JSON field name into upper snake case, keeping acronyms
+// together: "fileTDRLocation" becomes "FILE_TDR_LOCATION".
+func envName(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) &&
+			(unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
@@ -0,0 +1,163 @@
+package golog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestLoadConfigFromFileYAML(t *testing.T) {
+	logDir := t.TempDir()
+	path := writeConfigFile(t, "golog.yaml", `
+app: payment-service
+appVer: 1.2.3
+env: production
+fileLocation: `+logDir+`
+fileMaxSize: 50
+stdout: true
+logLevel: debug
+componentLevels:
+  payment: debug
+  db: warn
+masking:
+  keys: [password, pin]
+async:
+  overflowPolicy: dropOldest
+`)
+
+	conf, err := LoadConfigFromFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "payment-service", conf.App)
+	assert.Equal(t, "1.2.3", conf.AppVer)
+	assert.Equal(t, 50, conf.FileMaxSize)
+	assert.True(t, conf.Stdout)
+	assert.Equal(t, zapcore.DebugLevel, conf.LogLevel)
+	assert.Equal(t, map[string]zapcore.Level{"payment": zapcore.DebugLevel, "db": zapcore.WarnLevel}, conf.ComponentLevels)
+	assert.Equal(t, []string{"password", "pin"}, conf.Masking.Keys)
+	assert.Equal(t, OverflowDropOldest, conf.Async.OverflowPolicy)
+	assert.Equal(t, logDir, conf.FileTDRLocation, "defaults are set")
+}
+
+func TestLoadConfigFromFileJSON(t *testing.T) {
+	logDir := t.TempDir()
+	path := writeConfigFile(t, "golog.json", `{"app":"svc","fileLocation":"`+logDir+`","logLevel":"warn"}`)
+
+	conf, err := LoadConfigFromFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "svc", conf.App)
+	assert.Equal(t, zapcore.WarnLevel, conf.LogLevel)
+	assert.Equal(t, "development", conf.Env, "defaults are set")
+}
+
+func TestNewLoggerDefaultEnv(t *testing.T) {
+	logDir := t.TempDir()
+	logger := NewLogger(Config{App: "svc", FileLocation: logDir})
+	logger.Info("hello")
+	require.NoError(t, logger.Sync())
+
+	lines := readLogLines(t, filepath.Join(logDir, "system.log"))
+	require.Len(t, lines, 1)
+	assert.Equal(t, "development", lines[0]["env"])
+}
+
+func TestLoadConfigFromFileErrors(t *testing.T) {
+	_, err := LoadConfigFromFile(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+
+	_, err = LoadConfigFromFile(writeConfigFile(t, "golog.toml", `app = "svc"`))
+	assert.ErrorContains(t, err, "unsupported config file extension")
+
+	_, err = LoadConfigFromFile(writeConfigFile(t, "golog.yaml", "logLevel: verbose\n"))
+	assert.ErrorContains(t, err, "verbose")
+
+	_, err = LoadConfigFromFile(writeConfigFile(t, "golog.yml", "env: staging\n"))
+	assert.ErrorContains(t, err, `unknown env "staging"`)
+}
+
+func TestLoadConfigFromEnv(t *testing.T) {
+	logDir := t.TempDir()
+	t.Setenv("GOLOG_APP", "payment-service")
+	t.Setenv("GOLOG_ENV", "production")
+	t.Setenv("GOLOG_FILE_LOCATION", logDir)
+	t.Setenv("GOLOG_FILE_TDR_LOCATION", logDir)
+	t.Setenv("GOLOG_FILE_MAX_SIZE", "20")
+	t.Setenv("GOLOG_STDOUT", "true")
+	t.Setenv("GOLOG_LOG_LEVEL", "debug")
+	t.Setenv("GOLOG_COMPONENT_LEVELS", "payment=debug, db=warn")
+	t.Setenv("GOLOG_MASKING_KEYS", "password, pin")
+	t.Setenv("GOLOG_MASKING_STRATEGIES", "pin=last:2")
+
+	conf, err := LoadConfigFromEnv("GOLOG")
+	require.NoError(t, err)
+	assert.Equal(t, "payment-service", conf.App)
+	assert.Equal(t, "production", conf.Env)
+	assert.Equal(t, logDir, conf.FileTDRLocation)
+	assert.Equal(t, 20, conf.FileMaxSize)
+	assert.True(t, conf.Stdout)
+	assert.Equal(t, zapcore.DebugLevel, conf.LogLevel)
+	assert.Equal(t, map[string]zapcore.Level{"payment": zapcore.DebugLevel, "db": zapcore.WarnLevel}, conf.ComponentLevels)
+	require.NotNil(t, conf.Masking)
+	assert.Equal(t, []string{"password", "pin"}, conf.Masking.Keys)
+	assert.Equal(t, map[string]string{"pin": "last:2"}, conf.Masking.Strategies)
+	assert.Nil(t, conf.Async, "nested settings without variables stay nil")
+	assert.Nil(t, conf.BodyLimits)
+}
+
+func TestLoadConfigFromEnvErrors(t *testing.T) {
+	t.Setenv("APP_ENV", "development")
+	t.Setenv("APP_FILE_LOCATION", t.TempDir())
+	t.Setenv("APP_FILE_MAX_SIZE", "big")
+	t.Setenv("APP_ASYNC_BUFFER_SIZE", "-1")
+
+	_, err := LoadConfigFromEnv("APP_")
+	assert.ErrorContains(t, err, "APP_FILE_MAX_SIZE")
+
+	t.Setenv("APP_FILE_MAX_SIZE", "10")
+	_, err = LoadConfigFromEnv("APP")
+	assert.ErrorContains(t, err, "negative async buffer size")
+}
+
+func TestConfigValidate(t *testing.T) {
+	logDir := t.TempDir()
+	valid := Config{Env: "production", FileLocation: logDir, LogLevel: zapcore.DebugLevel}
+	require.NoError(t, valid.Validate())
+
+	unset := Config{FileLocation: logDir}
+	require.NoError(t, unset.Validate())
+	assert.Equal(t, "development", unset.Env)
+
+	file := writeConfigFile(t, "system.log", "")
+	for name, conf := range map[string]Config{
+		"unknown env":       {Env: "prod", FileLocation: logDir},
+		"missing location":  {Env: "production"},
+		"file as location":  {Env: "production", FileLocation: file},
+		"negative size":     {Env: "production", FileLocation: logDir, FileMaxSize: -1},
+		"negative backups":  {Env: "production", FileLocation: logDir, FileMaxBackup: -1},
+		"negative age":      {Env: "production", FileLocation: logDir, FileMaxAge: -1},
+		"invalid level":     {Env: "production", FileLocation: logDir, LogLevel: zapcore.InvalidLevel},
+		"invalid component": {Env: "production", FileLocation: logDir, ComponentLevels: map[string]zapcore.Level{"": zapcore.InfoLevel}},
+		"invalid masking":   {Env: "production", FileLocation: logDir, Masking: &MaskingPolicy{KeyRegexps: []string{"("}}},
+		"invalid async":     {Env: "production", FileLocation: logDir, Async: &AsyncConfig{OverflowPolicy: "spill"}},
+		"invalid sampling":  {Env: "production", FileLocation: logDir, Sampling: &SamplingConfig{Thereafter: -1}},
+	} {
+		assert.Error(t, conf.Validate(), name)
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	assert.Equal(t, "APP_VER", envName("appVer"))
+	assert.Equal(t, "FILE_TDR_LOCATION", envName("fileTDRLocation"))
+	assert.Equal(t, "SKIP_CONTENT_TYPES", envName("skipContentTypes"))
+	assert.Equal(t, "SHA256", envName("sha256"))
+}
@@ -1,6 +1,10 @@
 package golog
 
-import "context"
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
 
 type contextKey string
 
@@ -15,6 +19,9 @@ const (
 	PathKey contextKey = "path"
 )
 
+// TraceIDHeader is the HTTP header used to propagate the trace ID between services
+const TraceIDHeader = "X-Trace-Id"
+
 func (k contextKey) String() string {
 	return string(k)
 }
@@ -62,3 +69,10 @@ func GetPath(ctx context.Context) (string, bool) {
 	v, ok := ctx.Value(PathKey).(string)
 	return v, ok
 }
+
+// NewTraceID generates a random trace ID in W3C format (32 hex digits)
+func NewTraceID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
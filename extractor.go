@@ -0,0 +1,137 @@
+package golog
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+// ContextExtractor derives a log field from a context. It returns false when
+// the context does not carry the value, in which case no field is emitted.
+type ContextExtractor func(ctx context.Context) (zap.Field, bool)
+
+type namedExtractor struct {
+	name    string
+	extract ContextExtractor
+}
+
+var (
+	extractorsMu sync.Mutex
+	extractors   atomic.Pointer[[]namedExtractor]
+)
+
+func init() {
+	builtin := []namedExtractor{
+		{TraceIDKey.String(), traceIDExtractor},
+		{SpanIDField, spanIDExtractor},
+		{TraceFlagsField, traceFlagsExtractor},
+		{SrcIPKey.String(), StringContextExtractor(SrcIPKey, SrcIPKey.String())},
+		{PortKey.String(), StringContextExtractor(PortKey, PortKey.String())},
+		{PathKey.String(), StringContextExtractor(PathKey, PathKey.String())},
+	}
+	extractors.Store(&builtin)
+}
+
+// RegisterContextExtractor registers fn under name so that every logger emits
+// its field on each log entry and TDR record. Registering an existing name
+// replaces the previous extractor, including the built-in ones
+// ("traceId", "spanId", "traceFlags", "srcIP", "port" and "path").
+func RegisterContextExtractor(name string, fn ContextExtractor) {
+	extractorsMu.Lock()
+	defer extractorsMu.Unlock()
+
+	current := *extractors.Load()
+	next := make([]namedExtractor, 0, len(current)+1)
+	replaced := false
+	for _, e := range current {
+		if e.name == name {
+			e.extract = fn
+			replaced = true
+		}
+		next = append(next, e)
+	}
+	if !replaced {
+		next = append(next, namedExtractor{name: name, extract: fn})
+	}
+	extractors.Store(&next)
+}
+
+// UnregisterContextExtractor removes the extractor registered under name.
+func UnregisterContextExtractor(name string) {
+	extractorsMu.Lock()
+	defer extractorsMu.Unlock()
+
+	current := *extractors.Load()
+	next := make([]namedExtractor, 0, len(current))
+	for _, e := range current {
+		if e.name != name {
+			next = append(next, e)
+		}
+	}
+	extractors.Store(&next)
+}
+
+// StringContextExtractor returns an extractor that emits the string stored in
+// the context under key as a field named field.
+func StringContextExtractor(key interface{}, field string) ContextExtractor {
+	return func(ctx context.Context) (zap.Field, bool) {
+		v, ok := ctx.Value(key).(string)
+		if !ok {
+			return zap.Field{}, false
+		}
+		return zap.String(field, v), true
+	}
+}
+
+// sortedExtractors converts a Config extractor map into a slice with a stable
+// field order.
+func sortedExtractors(m map[string]ContextExtractor) []namedExtractor {
+	if len(m) == 0 {
+		return nil
+	}
+	result := make([]namedExtractor, 0, len(m))
+	for name, fn := range m {
+		result = append(result, namedExtractor{name: name, extract: fn})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].name < result[j].name
+	})
+	return result
+}
+
+// populateFieldFromContext runs the registered extractors followed by the
+// logger-specific ones in local. A local extractor shadows a registered one
+// with the same name.
+func populateFieldFromContext(ctx context.Context, local ...namedExtractor) []zap.Field {
+	global := *extractors.Load()
+	fieldFromCtx := make([]zap.Field, 0, len(global)+len(local))
+
+	for _, e := range global {
+		if isShadowed(e.name, local) {
+			continue
+		}
+		if f, ok := e.extract(ctx); ok {
+			fieldFromCtx = append(fieldFromCtx, f)
+		}
+	}
+
+	for _, e := range local {
+		if f, ok := e.extract(ctx); ok {
+			fieldFromCtx = append(fieldFromCtx, f)
+		}
+	}
+
+	return fieldFromCtx
+}
+
+func isShadowed(name string, local []namedExtractor) bool {
+	for _, e := range local {
+		if e.name == name {
+			return true
+		}
+	}
+	return false
+}
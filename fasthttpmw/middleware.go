@@ -0,0 +1,195 @@
+// Package fasthttpmw provides a fasthttp handler wrapper that propagates the
+// golog trace context and writes a TDR record for every request.
+package fasthttpmw
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/tommynurwantoro/golog"
+	"github.com/tommynurwantoro/golog/internal/capture"
+	"github.com/tommynurwantoro/golog/internal/middleware"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultMaxBodySize is the number of request and response body bytes
+// captured for the TDR record when Config.MaxBodySize is zero.
+const DefaultMaxBodySize = middleware.DefaultMaxBodySize
+
+// Config configures the wrapper returned by New. The zero value logs
+// through the singleton logger with the default headers and body size.
+type Config struct {
+	// Logger used to write TDR records.
+	// If nil, the singleton logger loaded with golog.Load is used.
+	Logger golog.LoggerInterface
+
+	// Header carrying the trace ID. Defaults to golog.TraceIDHeader.
+	// The resolved trace ID is echoed back in the response header.
+	TraceHeader string
+
+	// Header carrying the correlation ID. Defaults to "X-Correlation-Id".
+	CorrelationHeader string
+
+	// Header carrying the client IP when running behind a proxy,
+	// e.g. "X-Forwarded-For". If empty, the remote address is used.
+	SrcIPHeader string
+
+	// Maximum number of body bytes captured per direction. Longer bodies
+	// are logged as truncated, subject to the logger's golog.BodyLimits.
+	// Defaults to DefaultMaxBodySize. A negative value disables body capture.
+	MaxBodySize int
+
+	// Generates a trace ID when the request carries none.
+	// Defaults to golog.NewTraceID.
+	GenerateTraceID func() string
+}
+
+func (c *Config) validate() {
+	middleware.HTTPDefaults(&c.TraceHeader, &c.CorrelationHeader, &c.MaxBodySize, &c.GenerateTraceID)
+}
+
+// New returns a wrapper that stores the trace ID, source IP, port and path as
+// user values on the request context, so golog.WithContext(ctx) inside the
+// handler is correlated, and logs the request through TDR once the handler
+// returns. Panics in the handler are logged and then re-raised.
+func New(conf Config) func(fasthttp.RequestHandler) fasthttp.RequestHandler {
+	conf.validate()
+
+	return func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(ctx *fasthttp.RequestCtx) {
+			start := time.Now()
+
+			traceID := string(ctx.Request.Header.Peek(conf.TraceHeader))
+			if traceID == "" {
+				if sc := spanContext(ctx); sc.HasTraceID() {
+					traceID = sc.TraceID().String()
+				} else {
+					traceID = conf.GenerateTraceID()
+				}
+			}
+			srcIP := sourceIP(ctx, conf.SrcIPHeader)
+			ip, port := localAddr(ctx)
+			path := string(ctx.Path())
+
+			ctx.SetUserValue(golog.TraceIDKey, traceID)
+			ctx.SetUserValue(golog.SrcIPKey, srcIP)
+			ctx.SetUserValue(golog.PortKey, port)
+			ctx.SetUserValue(golog.PathKey, path)
+			ctx.Response.Header.Set(conf.TraceHeader, traceID)
+
+			defer func() {
+				rec := recover()
+
+				model := golog.LogModel{
+					TraceID:       traceID,
+					CorrelationID: string(ctx.Request.Header.Peek(conf.CorrelationHeader)),
+					SrcIP:         srcIP,
+					IP:            ip,
+					Port:          port,
+					Path:          string(ctx.RequestURI()),
+					Method:        string(ctx.Method()),
+					Header:        &ctx.Request.Header,
+					Request:       captureBody(ctx.Request.Body(), conf.MaxBodySize, string(ctx.Request.Header.ContentType())),
+					ResponseTime:  time.Since(start),
+				}
+
+				otherData := make(map[string]interface{})
+				if ctx.IsBodyStream() {
+					otherData["bodyStream"] = true
+				} else {
+					model.Response = captureBody(ctx.Response.Body(), conf.MaxBodySize, string(ctx.Response.Header.ContentType()))
+				}
+				if ctx.Hijacked() {
+					otherData["hijacked"] = true
+				}
+				if len(otherData) > 0 {
+					model.OtherData = otherData
+				}
+
+				status := ctx.Response.StatusCode()
+				if rec != nil {
+					status = fasthttp.StatusInternalServerError
+					model.Error = fmt.Sprint(rec)
+				}
+				model.HttpStatus = uint64(status)
+				model.StatusCode = strconv.Itoa(status)
+
+				middleware.LogTDR(conf.Logger, ctx, model)
+
+				if rec != nil {
+					panic(rec)
+				}
+			}()
+
+			next(ctx)
+		}
+	}
+}
+
+// spanContext returns the span context of the active OpenTelemetry span, as
+// httpmw does. fasthttp has no request context an instrumentation could
+// carry the span in, so the span propagated in the request headers, e.g. a
+// W3C traceparent, is used otherwise, read with the global propagator.
+func spanContext(ctx *fasthttp.RequestCtx) trace.SpanContext {
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		return sc
+	}
+	propagated := otel.GetTextMapPropagator().Extract(context.Background(), headerCarrier{&ctx.Request.Header})
+	return trace.SpanContextFromContext(propagated)
+}
+
+// headerCarrier adapts request headers to propagation.TextMapCarrier.
+type headerCarrier struct {
+	header *fasthttp.RequestHeader
+}
+
+func (c headerCarrier) Get(key string) string {
+	return string(c.header.Peek(key))
+}
+
+func (c headerCarrier) Set(key, value string) {
+	c.header.Set(key, value)
+}
+
+func (c headerCarrier) Keys() []string {
+	var keys []string
+	for k := range c.header.All() {
+		keys = append(keys, string(k))
+	}
+	return keys
+}
+
+// captureBody returns a copy of at most limit bytes of body, since fasthttp
+// reuses the underlying buffers once the handler returns. Bodies longer
+// than limit carry their full size and hash.
+func captureBody(body []byte, limit int, contentType string) interface{} {
+	buf := capture.Buffer{Limit: limit}
+	_, _ = buf.Write(body)
+	return middleware.Body(&buf, contentType)
+}
+
+func sourceIP(ctx *fasthttp.RequestCtx, header string) string {
+	if header != "" {
+		if v := ctx.Request.Header.Peek(header); len(v) > 0 {
+			return middleware.ForwardedIP(string(v))
+		}
+	}
+	return ctx.RemoteIP().String()
+}
+
+func localAddr(ctx *fasthttp.RequestCtx) (string, string) {
+	addr := ctx.LocalAddr()
+	if addr == nil {
+		return "", ""
+	}
+	host, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String(), ""
+	}
+	return host, port
+}
@@ -0,0 +1,149 @@
+package fasthttpmw
+
+import (
+	"bufio"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tommynurwantoro/golog"
+	"github.com/tommynurwantoro/golog/internal/tdrtest"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+func newRequestCtx(method, uri, body string) *fasthttp.RequestCtx {
+	var req fasthttp.Request
+	req.Header.SetMethod(method)
+	req.SetRequestURI(uri)
+	req.SetBodyString(body)
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Init(&req, &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 1234}, nil)
+	return ctx
+}
+
+func TestMiddleware(t *testing.T) {
+	logger, path := tdrtest.NewLogger(t)
+
+	var handlerTraceID string
+	handler := New(Config{Logger: logger})(func(ctx *fasthttp.RequestCtx) {
+		handlerTraceID, _ = golog.GetTraceID(ctx)
+		ctx.SetStatusCode(fasthttp.StatusCreated)
+		ctx.SetBodyString(`{"id":1,"token":"abc"}`)
+	})
+
+	ctx := newRequestCtx(fasthttp.MethodPost, "/users?password=secret&page=2", `{"username":"john","password":"secret"}`)
+	ctx.Request.Header.Set("Authorization", "Bearer x")
+	ctx.Request.Header.Set("X-Correlation-Id", "corr-1")
+	ctx.Request.Header.SetContentType("application/json")
+	handler(ctx)
+
+	assert.Len(t, handlerTraceID, 32)
+	assert.Equal(t, handlerTraceID, string(ctx.Response.Header.Peek(golog.TraceIDHeader)))
+
+	lines := tdrtest.Read(t, logger, path)
+	require.Len(t, lines, 1)
+	line := lines[0]
+	assert.Equal(t, handlerTraceID, line["traceId"])
+	assert.Equal(t, "192.0.2.1", line["srcIP"])
+	assert.Equal(t, "/users?password=*****&page=2", line["path"])
+	assert.Equal(t, "corr-1", line["correlationId"])
+	assert.Equal(t, "POST", line["method"])
+	assert.Equal(t, "201", line["statusCode"])
+	assert.Equal(t, float64(201), line["httpStatus"])
+	assert.Equal(t, map[string]interface{}{"username": "john", "password": "*****"}, line["request"])
+	assert.Equal(t, map[string]interface{}{"id": float64(1), "token": "*****"}, line["response"])
+
+	header, ok := line["header"].(map[string]interface{})
+	require.True(t, ok, "header should be logged as a map like net/http")
+	assert.Equal(t, []interface{}{"application/json"}, header["Content-Type"])
+	assert.NotContains(t, header, "Authorization")
+}
+
+func TestMiddlewarePropagatesTraceID(t *testing.T) {
+	logger, path := tdrtest.NewLogger(t)
+
+	handler := New(Config{Logger: logger})(func(ctx *fasthttp.RequestCtx) {
+		traceID, _ := golog.GetTraceID(ctx)
+		assert.Equal(t, "trace-123", traceID)
+	})
+
+	ctx := newRequestCtx(fasthttp.MethodGet, "/ping", "")
+	ctx.Request.Header.Set(golog.TraceIDHeader, "trace-123")
+	handler(ctx)
+
+	lines := tdrtest.Read(t, logger, path)
+	require.Len(t, lines, 1)
+	assert.Equal(t, "trace-123", lines[0]["traceId"])
+	assert.Equal(t, "200", lines[0]["statusCode"])
+}
+
+func TestMiddlewareTraceIDFromSpan(t *testing.T) {
+	previous := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(previous) })
+	logger, path := tdrtest.NewLogger(t)
+
+	handler := New(Config{Logger: logger})(func(ctx *fasthttp.RequestCtx) {})
+
+	ctx := newRequestCtx(fasthttp.MethodGet, "/ping", "")
+	ctx.Request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler(ctx)
+
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", string(ctx.Response.Header.Peek(golog.TraceIDHeader)))
+	lines := tdrtest.Read(t, logger, path)
+	require.Len(t, lines, 1)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", lines[0]["traceId"])
+}
+
+func TestMiddlewareBodyLimit(t *testing.T) {
+	logger, path := tdrtest.NewLogger(t)
+
+	handler := New(Config{Logger: logger, MaxBodySize: 4})(func(ctx *fasthttp.RequestCtx) {
+		ctx.SetBodyString("response body")
+	})
+
+	handler(newRequestCtx(fasthttp.MethodPost, "/upload", "request body"))
+
+	lines := tdrtest.Read(t, logger, path)
+	require.Len(t, lines, 1)
+	assert.Equal(t, map[string]interface{}{"truncated": true, "size": float64(12), "body": "requ"}, lines[0]["request"])
+	assert.Equal(t, map[string]interface{}{"truncated": true, "size": float64(13), "body": "resp"}, lines[0]["response"])
+}
+
+func TestMiddlewarePanic(t *testing.T) {
+	logger, path := tdrtest.NewLogger(t)
+
+	handler := New(Config{Logger: logger})(func(ctx *fasthttp.RequestCtx) {
+		panic("boom")
+	})
+
+	assert.PanicsWithValue(t, "boom", func() {
+		handler(newRequestCtx(fasthttp.MethodGet, "/panic", ""))
+	})
+
+	lines := tdrtest.Read(t, logger, path)
+	require.Len(t, lines, 1)
+	assert.Equal(t, "500", lines[0]["statusCode"])
+	assert.Equal(t, "boom", lines[0]["error"])
+}
+
+func TestMiddlewareBodyStream(t *testing.T) {
+	logger, path := tdrtest.NewLogger(t)
+
+	handler := New(Config{Logger: logger})(func(ctx *fasthttp.RequestCtx) {
+		ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+			_, _ = w.WriteString("chunk")
+		})
+	})
+
+	handler(newRequestCtx(fasthttp.MethodGet, "/stream", ""))
+
+	lines := tdrtest.Read(t, logger, path)
+	require.Len(t, lines, 1)
+	assert.Nil(t, lines[0]["response"])
+	assert.Equal(t, map[string]interface{}{"bodyStream": true}, lines[0]["otherData"])
+}
@@ -0,0 +1,388 @@
+package golog
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/valyala/fasthttp"
+)
+
+// maxMultipartFieldSize bounds the bytes read from a single non-file
+// multipart field.
+const maxMultipartFieldSize = 64 * 1024
+
+// headerContentType returns the Content-Type of a TDR header, if known.
+func headerContentType(header interface{}) string {
+	switch h := header.(type) {
+	case http.Header:
+		return h.Get("Content-Type")
+	case *fasthttp.RequestHeader:
+		return string(h.ContentType())
+	default:
+		return ""
+	}
+}
+
+// maskText masks a textual body according to its content type. When the
+// content type is missing or generic the format is sniffed from the body. Bodies that
+// cannot be parsed are only passed through the value scanners.
+func (m *masker) maskText(text string, contentType string) interface{} {
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		if masked, ok := m.maskForm(text); ok {
+			return masked
+		}
+	case strings.HasPrefix(mediaType, "multipart/"):
+		if masked, ok := m.maskMultipart(text, params["boundary"]); ok {
+			return masked
+		}
+	case mediaType == "text/xml" || mediaType == "application/xml" || strings.HasSuffix(mediaType, "+xml"):
+		if masked, ok := m.maskXML(text); ok {
+			return masked
+		}
+	default:
+		// only objects and arrays, so that plain text such as "42" is
+		// not turned into a JSON value
+		var body interface{}
+		if err := json.Unmarshal([]byte(text), &body); err == nil {
+			switch body.(type) {
+			case map[string]interface{}, []interface{}:
+				return m.maskValue(body, nil, nil)
+			}
+		}
+		// bodies are often sent with a generic or wrong content type, so
+		// anything not declared as JSON is sniffed
+		if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+			return m.sniffText(text)
+		}
+	}
+
+	return m.scanText(text)
+}
+
+// sniffText guesses the format of a body without a content type.
+func (m *masker) sniffText(text string) interface{} {
+	trimmed := strings.TrimSpace(text)
+
+	switch {
+	case strings.HasPrefix(trimmed, "<"):
+		if masked, ok := m.maskXML(text); ok {
+			return masked
+		}
+	case strings.HasPrefix(trimmed, "--"):
+		boundary, _, _ := strings.Cut(strings.TrimPrefix(trimmed, "--"), "\n")
+		if masked, ok := m.maskMultipart(text, strings.TrimSpace(boundary)); ok {
+			return masked
+		}
+	case looksLikeForm(trimmed):
+		if masked, ok := m.maskForm(trimmed); ok {
+			return masked
+		}
+	}
+
+	return m.scanText(text)
+}
+
+func looksLikeForm(text string) bool {
+	if text == "" || strings.ContainsAny(text, " \t\r\n") {
+		return false
+	}
+	key, _, ok := strings.Cut(text, "=")
+	return ok && key != ""
+}
+
+// maskForm masks a URL-encoded form. The result is a map so it goes
+// through the same key, path, strategy and scanner rules as JSON bodies.
+func (m *masker) maskForm(text string) (interface{}, bool) {
+	values, err := url.ParseQuery(text)
+	if err != nil {
+		return nil, false
+	}
+	return m.maskFieldMap(valuesMap(values), nil, nil), true
+}
+
+// maskMultipart masks the fields of a multipart body. File parts are
+// replaced by their file name, content type and size.
+func (m *masker) maskMultipart(text, boundary string) (interface{}, bool) {
+	if boundary == "" {
+		return nil, false
+	}
+
+	values := make(url.Values)
+	files := make(map[string][]interface{})
+
+	reader := multipart.NewReader(strings.NewReader(text), boundary)
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, false
+		}
+
+		name := part.FormName()
+		if part.FileName() != "" {
+			size, _ := io.Copy(io.Discard, part)
+			files[name] = append(files[name], map[string]interface{}{
+				"filename":    part.FileName(),
+				"contentType": part.Header.Get("Content-Type"),
+				"size":        size,
+			})
+			continue
+		}
+
+		value, _ := io.ReadAll(io.LimitReader(part, maxMultipartFieldSize))
+		values.Add(name, string(value))
+	}
+
+	result := valuesMap(values)
+	for name, list := range files {
+		if len(list) == 1 {
+			result[name] = list[0]
+		} else {
+			result[name] = list
+		}
+	}
+	return m.maskFieldMap(result, nil, nil), true
+}
+
+// valuesMap converts url.Values into a body map. Single values become
+// strings and repeated values become arrays.
+func valuesMap(values url.Values) map[string]interface{} {
+	result := make(map[string]interface{}, len(values))
+	for key, list := range values {
+		if len(list) == 1 {
+			result[key] = list[0]
+			continue
+		}
+		items := make([]interface{}, len(list))
+		for i, v := range list {
+			items[i] = v
+		}
+		result[key] = items
+	}
+	return result
+}
+
+// maskXML masks the text of elements and the attributes whose local name is
+// sensitive. The document is rewritten from the original bytes, so
+// formatting and namespace prefixes are preserved.
+func (m *masker) maskXML(text string) (interface{}, bool) {
+	return m.rewriteXML(text, false)
+}
+
+// maskPartialXML masks a document that was cut off, dropping the token left
+// incomplete at the end.
+func (m *masker) maskPartialXML(text string) string {
+	masked, _ := m.rewriteXML(text, true)
+	return masked.(string)
+}
+
+func (m *masker) rewriteXML(text string, partial bool) (interface{}, bool) {
+	src := []byte(text)
+	decoder := xml.NewDecoder(bytes.NewReader(src))
+	decoder.Strict = false
+
+	var out bytes.Buffer
+	out.Grow(len(src))
+
+	// strategies of the open elements; nil for non-sensitive ones
+	var stack []*maskStrategy
+	dropDepth := 0
+
+	for {
+		start := decoder.InputOffset()
+		token, err := decoder.RawToken()
+		if errors.Is(err, io.EOF) || (err != nil && partial) {
+			break
+		}
+		if err != nil {
+			return nil, false
+		}
+		raw := src[start:decoder.InputOffset()]
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			selfClosing := bytes.HasSuffix(raw, []byte("/>"))
+			if dropDepth > 0 {
+				if !selfClosing {
+					dropDepth++
+				}
+				continue
+			}
+
+			// the children of a sensitive element inherit its strategy, as
+			// the nested values of a sensitive JSON key do
+			var inherited *maskStrategy
+			if len(stack) > 0 {
+				inherited = stack[len(stack)-1]
+			}
+			strategy := inherited
+			if rule, ok := m.sensitiveRule(t.Name.Local); ok {
+				s := m.bodyStrategy(rule)
+				if s.kind == StrategyDrop {
+					if !selfClosing {
+						dropDepth = 1
+					}
+					continue
+				}
+				strategy = &s
+			}
+			if !selfClosing {
+				stack = append(stack, strategy)
+			}
+
+			if (inherited == nil || len(t.Attr) == 0) && !m.hasSensitiveAttr(t.Attr) {
+				out.Write(raw)
+				continue
+			}
+			m.writeStartElement(&out, t, inherited, selfClosing)
+		case xml.EndElement:
+			if len(raw) == 0 {
+				// synthetic end of a self-closing element
+				continue
+			}
+			if dropDepth > 0 {
+				dropDepth--
+				continue
+			}
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			out.Write(raw)
+		case xml.CharData:
+			if dropDepth > 0 {
+				continue
+			}
+			var strategy *maskStrategy
+			if len(stack) > 0 {
+				strategy = stack[len(stack)-1]
+			}
+			switch {
+			case strategy != nil && len(bytes.TrimSpace(t)) > 0:
+				_ = xml.EscapeText(&out, []byte(stringOf(strategy.apply(string(t), m.hashSalt))))
+			case len(m.scanners) > 0:
+				_ = xml.EscapeText(&out, []byte(m.scanValue(string(t))))
+			default:
+				out.Write(raw)
+			}
+		default:
+			if dropDepth == 0 {
+				out.Write(raw)
+			}
+		}
+	}
+
+	return out.String(), true
+}
+
+func (m *masker) hasSensitiveAttr(attrs []xml.Attr) bool {
+	for _, attr := range attrs {
+		if _, ok := m.sensitiveRule(attr.Name.Local); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// writeStartElement renders a start tag with its sensitive attributes
+// masked or dropped. Inside a sensitive element, whose strategy inherited
+// is, every attribute but namespace declarations is masked with it unless
+// its own name is sensitive.
+func (m *masker) writeStartElement(out *bytes.Buffer, t xml.StartElement, inherited *maskStrategy, selfClosing bool) {
+	out.WriteByte('<')
+	out.WriteString(qualifiedName(t.Name))
+	for _, attr := range t.Attr {
+		value := attr.Value
+		attrStrategy := inherited
+		if attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+			attrStrategy = nil
+		}
+		if rule, ok := m.sensitiveRule(attr.Name.Local); ok {
+			s := m.bodyStrategy(rule)
+			attrStrategy = &s
+		}
+		if attrStrategy != nil {
+			if attrStrategy.kind == StrategyDrop {
+				continue
+			}
+			value = stringOf(attrStrategy.apply(value, m.hashSalt))
+		}
+		out.WriteByte(' ')
+		out.WriteString(qualifiedName(attr.Name))
+		out.WriteString(`="`)
+		_ = xml.EscapeText(out, []byte(value))
+		out.WriteByte('"')
+	}
+	if selfClosing {
+		out.WriteByte('/')
+	}
+	out.WriteByte('>')
+}
+
+// qualifiedName renders a raw token name with its namespace prefix.
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// maskPath masks sensitive query parameters of a path or URL, keeping the
+// order and encoding of the other parameters.
+func (m *masker) maskPath(p string) string {
+	base, rawQuery, ok := strings.Cut(p, "?")
+	if !ok || rawQuery == "" {
+		return p
+	}
+	rawQuery, fragment, hasFragment := strings.Cut(rawQuery, "#")
+
+	pairs := strings.Split(rawQuery, "&")
+	masked := pairs[:0]
+	for _, pair := range pairs {
+		rawKey, rawValue, _ := strings.Cut(pair, "=")
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil {
+			key = rawKey
+		}
+		value, err := url.QueryUnescape(rawValue)
+		if err != nil {
+			value = rawValue
+		}
+
+		if rule, ok := m.sensitiveRule(key); ok {
+			strategy := m.bodyStrategy(rule)
+			if strategy.kind == StrategyDrop {
+				continue
+			}
+			pair = rawKey + "=" + queryEscape(stringOf(strategy.apply(value, m.hashSalt)))
+		} else if len(m.scanners) > 0 {
+			if scanned := m.scanValue(value); scanned != value {
+				pair = rawKey + "=" + queryEscape(scanned)
+			}
+		}
+		masked = append(masked, pair)
+	}
+
+	result := base + "?" + strings.Join(masked, "&")
+	if hasFragment {
+		result += "#" + fragment
+	}
+	return result
+}
+
+// queryEscape escapes a query value but keeps mask characters readable.
+func queryEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "%2A", "*")
+}
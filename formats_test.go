@@ -0,0 +1,186 @@
+package golog
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaskFormBody(t *testing.T) {
+	m := policyMasker(nil)
+
+	masked := m.maskBody("username=john&password=secret&tag=a&tag=b", "application/x-www-form-urlencoded")
+	assert.Equal(t, map[string]interface{}{
+		"username": "john",
+		"password": "*****",
+		"tag":      []interface{}{"a", "b"},
+	}, masked)
+
+	// without a content type the form is sniffed
+	assert.Equal(t, map[string]interface{}{"token": "*****", "page": "2"}, m.maskBody([]byte("token=abc&page=2"), ""))
+
+	// plain text is left alone
+	assert.Equal(t, "hello world", m.maskBody("hello world", ""))
+}
+
+func TestMaskJSONArrayBody(t *testing.T) {
+	m := policyMasker(nil)
+
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"password": "*****", "user": "a"},
+		"plain",
+	}, m.maskBody(`[{"password":"x","user":"a"},"plain"]`, "application/json"))
+	assert.Equal(t, []interface{}{map[string]interface{}{"token": "*****"}}, m.maskBody([]byte(`[{"token":"t"}]`), ""))
+
+	// JSON scalars are still plain text
+	assert.Equal(t, "42", m.maskBody("42", ""))
+}
+
+func TestMaskXMLBody(t *testing.T) {
+	m, err := newMasker(MaskingPolicy{
+		Keys:       []string{"password", "token", "pin", "secret"},
+		Strategies: map[string]string{"pin": StrategyDrop, "token": KeepLast(2)},
+	})
+	require.NoError(t, err)
+
+	body := `<?xml version="1.0"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap:Body>
+    <login user="john" secret="s3">
+      <password>hunter2</password>
+      <ns:token xmlns:ns="urn:x">abcdef</ns:token>
+      <pin><digit>1</digit></pin>
+      <remember/>
+      <password/>
+    </login>
+  </soap:Body>
+</soap:Envelope>`
+
+	want := `<?xml version="1.0"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap:Body>
+    <login user="john" secret="*****">
+      <password>*****</password>
+      <ns:token xmlns:ns="urn:x">****ef</ns:token>
+      ` + `
+      <remember/>
+      <password/>
+    </login>
+  </soap:Body>
+</soap:Envelope>`
+
+	assert.Equal(t, want, m.maskBody(body, "text/xml; charset=utf-8"))
+	assert.Equal(t, want, m.maskBody(body, ""), "XML is sniffed without a content type")
+}
+
+func TestMaskXMLBodyNestedElements(t *testing.T) {
+	m, err := newMasker(MaskingPolicy{
+		Keys:       []string{"password", "card"},
+		Strategies: map[string]string{"card": KeepLast(2)},
+	})
+	require.NoError(t, err)
+
+	body := `<pay><password><value>s3cret</value></password>` +
+		`<card type="visa"><number brand="visa">4111111111111111</number><holder>John</holder></card>` +
+		`<note>ok</note></pay>`
+	want := `<pay><password><value>*****</value></password>` +
+		`<card type="visa"><number brand="**sa">**************11</number><holder>**hn</holder></card>` +
+		`<note>ok</note></pay>`
+	assert.Equal(t, want, m.maskBody(body, "application/xml"))
+}
+
+func TestMaskXMLBodyInvalid(t *testing.T) {
+	m, err := newMasker(MaskingPolicy{Keys: []string{"password"}, Scanners: []string{ScanEmail}})
+	require.NoError(t, err)
+
+	// unclosed elements are tolerated
+	assert.Equal(t, "<a><password>*****</a>", m.maskBody("<a><password>x</a>", "application/xml"))
+	// XML that cannot be tokenized falls back to the value scanners
+	assert.Equal(t, "<password>x</password><!-- *****", m.maskBody("<password>x</password><!-- john@example.com", "application/xml"))
+}
+
+func TestMaskMultipartBody(t *testing.T) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	require.NoError(t, w.WriteField("username", "john"))
+	require.NoError(t, w.WriteField("password", "secret"))
+	file, err := w.CreateFormFile("avatar", "me.png")
+	require.NoError(t, err)
+	_, err = file.Write([]byte("binary image data"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	m := policyMasker(nil)
+	want := map[string]interface{}{
+		"username": "john",
+		"password": "*****",
+		"avatar": map[string]interface{}{
+			"filename":    "me.png",
+			"contentType": "application/octet-stream",
+			"size":        int64(17),
+		},
+	}
+
+	assert.Equal(t, want, m.maskBody(buf.Bytes(), w.FormDataContentType()))
+	assert.Equal(t, want, m.maskBody(buf.Bytes(), ""), "multipart is sniffed without a content type")
+}
+
+func TestMaskPath(t *testing.T) {
+	m, err := newMasker(MaskingPolicy{
+		Keys:       []string{"password", "token", "api_key"},
+		Strategies: map[string]string{"api_key": StrategyDrop},
+		Scanners:   []string{ScanEmail},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/users", "/users"},
+		{"/users?", "/users?"},
+		{"/users?page=2&token=abc", "/users?page=2&token=*****"},
+		{"/users?api_key=k&sort=name%20asc", "/users?sort=name%20asc"},
+		{"/login?pass%77ord=a%20b#top", "/login?pass%77ord=*****#top"},
+		{"/users?email=john%40example.com", "/users?email=*****"},
+		{"https://example.com/cb?token=abc&state=x", "https://example.com/cb?token=*****&state=x"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, m.maskPath(tt.path), tt.path)
+	}
+}
+
+func TestTDRPath(t *testing.T) {
+	tmpDir := t.TempDir()
+	logger := NewLogger(Config{
+		App:          "testapp",
+		AppVer:       "1.0.0",
+		Env:          "development",
+		FileLocation: tmpDir,
+		FileMaxSize:  10,
+	})
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	ctx := WithPath(context.Background(), "/login")
+	logger.WithContext(ctx).TDR(LogModel{
+		Path:    "/login?password=secret&next=%2Fhome",
+		Header:  header,
+		Request: "username=john&password=secret",
+	})
+	logger.WithContext(ctx).TDR(LogModel{})
+	require.NoError(t, logger.Sync())
+
+	lines := readLogLines(t, filepath.Join(tmpDir, "tdr.log"))
+	require.Len(t, lines, 2)
+	assert.Equal(t, "/login?password=*****&next=%2Fhome", lines[0]["path"])
+	assert.Equal(t, map[string]interface{}{"username": "john", "password": "*****"}, lines[0]["request"])
+	assert.Equal(t, "/login", lines[1]["path"], "the context path is used when the record has none")
+}
@@ -0,0 +1,438 @@
+// Package grpcmw provides gRPC server and client interceptors that propagate
+// the golog trace ID through metadata and write a TDR record for every RPC.
+package grpcmw
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tommynurwantoro/golog"
+	"github.com/tommynurwantoro/golog/internal/middleware"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// DefaultTraceMetadataKey is the metadata key carrying the trace ID.
+const DefaultTraceMetadataKey = "x-trace-id"
+
+// Config configures the interceptors. The zero value logs through the
+// singleton logger with DefaultTraceMetadataKey.
+type Config struct {
+	// Logger used to write TDR records.
+	// If nil, the singleton logger loaded with golog.Load is used.
+	Logger golog.LoggerInterface
+
+	// Metadata key carrying the trace ID. Defaults to DefaultTraceMetadataKey.
+	TraceMetadataKey string
+
+	// Generates a trace ID when the RPC carries none.
+	// Defaults to golog.NewTraceID.
+	GenerateTraceID func() string
+}
+
+func (c *Config) validate() {
+	middleware.Defaults(&c.TraceMetadataKey, DefaultTraceMetadataKey, &c.GenerateTraceID)
+}
+
+// UnaryServerInterceptor binds the incoming trace ID, peer address and method
+// to the handler context and logs the RPC through TDR. Panics in the handler
+// are logged as codes.Internal and then re-raised.
+func UnaryServerInterceptor(conf Config) grpc.UnaryServerInterceptor {
+	conf.validate()
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		start := time.Now()
+		ctx, model := conf.serverContext(ctx, info.FullMethod)
+
+		defer func() {
+			rec := recover()
+			if rec != nil {
+				err = status.Errorf(codes.Internal, "panic: %v", rec)
+			}
+
+			model.Request = protoBody(req)
+			model.Response = protoBody(resp)
+			finish(&model, err, start)
+			middleware.LogTDR(conf.Logger, ctx, model)
+
+			if rec != nil {
+				panic(rec)
+			}
+		}()
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of
+// UnaryServerInterceptor. The TDR record carries the number of messages
+// received and sent; its request and response are the message of a
+// direction that has one, or the first and the last message of a direction
+// that has several, as {"first": ..., "last": ...}, masked as unary
+// messages are.
+func StreamServerInterceptor(conf Config) grpc.StreamServerInterceptor {
+	conf.validate()
+
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		start := time.Now()
+		ctx, model := conf.serverContext(ss.Context(), info.FullMethod)
+		wrapped := &serverStream{ServerStream: ss, ctx: ctx}
+
+		defer func() {
+			rec := recover()
+			if rec != nil {
+				err = status.Errorf(codes.Internal, "panic: %v", rec)
+			}
+
+			model.Request = wrapped.messages.received.body()
+			model.Response = wrapped.messages.sent.body()
+			model.OtherData = wrapped.messages.otherData(streamType(info.IsClientStream, info.IsServerStream))
+			finish(&model, err, start)
+			middleware.LogTDR(conf.Logger, ctx, model)
+
+			if rec != nil {
+				panic(rec)
+			}
+		}()
+
+		return handler(srv, wrapped)
+	}
+}
+
+// UnaryClientInterceptor sends the trace ID from the context (generating one
+// if needed) in the outgoing metadata and logs the call through TDR.
+func UnaryClientInterceptor(conf Config) grpc.UnaryClientInterceptor {
+	conf.validate()
+
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		ctx, model := conf.clientContext(ctx, method, cc.Target())
+
+		err := invoker(ctx, method, req, reply, cc, opts...)
+
+		model.Request = protoBody(req)
+		if err == nil {
+			model.Response = protoBody(reply)
+		}
+		finish(&model, err, start)
+		middleware.LogTDR(conf.Logger, ctx, model)
+		return err
+	}
+}
+
+// StreamClientInterceptor is the streaming counterpart of
+// UnaryClientInterceptor, logging messages as StreamServerInterceptor
+// does. The TDR record is written once the stream ends:
+// when RecvMsg returns an error (io.EOF for a successful stream), when it
+// returns the single response of a non server-streaming RPC, or when ctx is
+// done.
+func StreamClientInterceptor(conf Config) grpc.StreamClientInterceptor {
+	conf.validate()
+
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+		ctx, model := conf.clientContext(ctx, method, cc.Target())
+		kind := streamType(desc.ClientStreams, desc.ServerStreams)
+
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			model.OtherData = outbound((&streamLog{}).otherData(kind))
+			finish(&model, err, start)
+			middleware.LogTDR(conf.Logger, ctx, model)
+			return nil, err
+		}
+
+		stream := &clientStream{ClientStream: cs, serverStreams: desc.ServerStreams, done: make(chan struct{})}
+		stream.onFinish = func(err error) {
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+			model.Request = stream.messages.sent.body()
+			model.Response = stream.messages.received.body()
+			model.OtherData = outbound(stream.messages.otherData(kind))
+			finish(&model, err, start)
+			middleware.LogTDR(conf.Logger, ctx, model)
+		}
+		// a stream the caller abandons before its end is logged once its
+		// context is cancelled, which gRPC requires to release it
+		go func() {
+			select {
+			case <-ctx.Done():
+				stream.finish(status.FromContextError(ctx.Err()).Err())
+			case <-stream.done:
+			}
+		}()
+		return stream, nil
+	}
+}
+
+// serverContext resolves the trace ID from the incoming metadata and binds
+// it, together with the peer address and method, to ctx.
+func (c *Config) serverContext(ctx context.Context, method string) (context.Context, golog.LogModel) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	traceID := first(md.Get(c.TraceMetadataKey))
+	if traceID == "" {
+		if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+			traceID = sc.TraceID().String()
+		} else {
+			traceID = c.GenerateTraceID()
+		}
+	}
+
+	var srcIP string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		srcIP = hostOf(p.Addr.String())
+	}
+
+	ctx = golog.WithTraceID(ctx, traceID)
+	ctx = golog.WithSrcIP(ctx, srcIP)
+	ctx = golog.WithPath(ctx, method)
+	_ = grpc.SetHeader(ctx, metadata.Pairs(c.TraceMetadataKey, traceID))
+
+	return ctx, golog.LogModel{
+		TraceID:       traceID,
+		CorrelationID: first(md.Get("x-correlation-id")),
+		SrcIP:         srcIP,
+		Path:          method,
+		Method:        method,
+		Header:        header(md),
+	}
+}
+
+// clientContext appends the trace ID to the outgoing metadata of ctx.
+func (c *Config) clientContext(ctx context.Context, method, target string) (context.Context, golog.LogModel) {
+	traceID, ok := golog.GetTraceID(ctx)
+	if !ok {
+		if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+			traceID = sc.TraceID().String()
+		} else {
+			traceID = c.GenerateTraceID()
+			ctx = golog.WithTraceID(ctx, traceID)
+		}
+	}
+
+	ctx = metadata.AppendToOutgoingContext(ctx, c.TraceMetadataKey, traceID)
+	md, _ := metadata.FromOutgoingContext(ctx)
+
+	return ctx, golog.LogModel{
+		TraceID:   traceID,
+		IP:        target,
+		Path:      method,
+		Method:    method,
+		Header:    header(md),
+		OtherData: map[string]interface{}{"direction": "outbound"},
+	}
+}
+
+// finish fills the status fields of model from the RPC error.
+func finish(model *golog.LogModel, err error, start time.Time) {
+	st := status.Convert(err)
+	model.StatusCode = st.Code().String()
+	if err != nil {
+		model.Error = st.Message()
+	}
+	model.ResponseTime = time.Since(start)
+}
+
+// protoBody renders proto messages as JSON so the TDR masking pass can
+// redact sensitive fields. The body is marked as JSON, since the
+// Content-Type of the call says application/grpc. Other values are
+// returned unchanged.
+func protoBody(msg interface{}) interface{} {
+	m, ok := msg.(proto.Message)
+	if !ok {
+		return msg
+	}
+	if m == nil || !m.ProtoReflect().IsValid() {
+		return nil
+	}
+	b, err := protojson.Marshal(m)
+	if err != nil {
+		return fmt.Sprint(m)
+	}
+	return golog.Body{Data: b, ContentType: "application/json"}
+}
+
+// header converts metadata into an http.Header so that sensitive keys such
+// as "authorization" are stripped like any other HTTP header.
+func header(md metadata.MD) http.Header {
+	h := make(http.Header, len(md))
+	for k, v := range md {
+		h[http.CanonicalHeaderKey(k)] = append([]string(nil), v...)
+	}
+	return h
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func hostOf(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+func streamType(client, server bool) string {
+	switch {
+	case client && server:
+		return "bidi"
+	case client:
+		return "client"
+	default:
+		return "server"
+	}
+}
+
+// streamLog records the messages of a stream in both directions.
+type streamLog struct {
+	received streamMessages
+	sent     streamMessages
+}
+
+func (l *streamLog) otherData(kind string) map[string]interface{} {
+	return map[string]interface{}{
+		"streamType":       kind,
+		"messagesReceived": l.received.len(),
+		"messagesSent":     l.sent.len(),
+	}
+}
+
+// streamMessages counts the messages of one direction of a stream and keeps
+// the first and the last one, rendered by protoBody when they are sent or
+// received since the caller may reuse them.
+type streamMessages struct {
+	mu    sync.Mutex
+	count int64
+	first interface{}
+	last  interface{}
+}
+
+func (s *streamMessages) add(msg interface{}) {
+	body := protoBody(msg)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.count == 0 {
+		s.first = body
+	} else {
+		s.last = body
+	}
+	s.count++
+}
+
+func (s *streamMessages) len() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.count
+}
+
+// body returns the single message, or the first and the last ones.
+func (s *streamMessages) body() interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.count <= 1 {
+		return s.first
+	}
+	first, ok1 := s.first.(golog.Body)
+	last, ok2 := s.last.(golog.Body)
+	if !ok1 || !ok2 {
+		return map[string]interface{}{"first": s.first, "last": s.last}
+	}
+	data := make([]byte, 0, len(first.Data)+len(last.Data)+20)
+	data = append(data, `{"first":`...)
+	data = append(data, first.Data...)
+	data = append(data, `,"last":`...)
+	data = append(data, last.Data...)
+	data = append(data, '}')
+	return golog.Body{Data: data, ContentType: "application/json"}
+}
+
+func outbound(data map[string]interface{}) map[string]interface{} {
+	data["direction"] = "outbound"
+	return data
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	messages streamLog
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *serverStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.messages.sent.add(m)
+	}
+	return err
+}
+
+func (s *serverStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.messages.received.add(m)
+	}
+	return err
+}
+
+type clientStream struct {
+	grpc.ClientStream
+	// serverStreams is false if the server sends a single response, which
+	// ends the RPC when received
+	serverStreams bool
+	messages      streamLog
+	finished      atomic.Bool
+	done          chan struct{}
+	onFinish      func(err error)
+}
+
+// finish logs the RPC the first time it is called.
+func (s *clientStream) finish(err error) {
+	if s.finished.CompareAndSwap(false, true) {
+		close(s.done)
+		s.onFinish(err)
+	}
+}
+
+func (s *clientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.messages.sent.add(m)
+	}
+	return err
+}
+
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err == nil {
+		s.messages.received.add(m)
+		if !s.serverStreams {
+			s.finish(nil)
+		}
+		return nil
+	}
+	s.finish(err)
+	return err
+}
@@ -0,0 +1,288 @@
+package grpcmw
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tommynurwantoro/golog"
+	"github.com/tommynurwantoro/golog/internal/tdrtest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// collectDesc is a client-streaming service that answers with the number of
+// values it received.
+var collectDesc = grpc.ServiceDesc{
+	ServiceName: "test.Collector",
+	HandlerType: (*interface{})(nil),
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "Collect",
+		ClientStreams: true,
+		Handler: func(_ interface{}, stream grpc.ServerStream) error {
+			var n float64
+			for {
+				err := stream.RecvMsg(&structpb.Value{})
+				if errors.Is(err, io.EOF) {
+					return stream.SendMsg(structpb.NewNumberValue(n))
+				}
+				if err != nil {
+					return err
+				}
+				n++
+			}
+		},
+	}},
+}
+
+// startServer serves the health and collector services over bufconn and
+// returns a client connection that uses the client interceptors.
+func startServer(t *testing.T, serverConf, clientConf Config) (*grpc.ClientConn, *health.Server) {
+	t.Helper()
+
+	lis := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor(serverConf)),
+		grpc.StreamInterceptor(StreamServerInterceptor(serverConf)),
+	)
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(server, healthServer)
+	server.RegisterService(&collectDesc, nil)
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor(clientConf)),
+		grpc.WithStreamInterceptor(StreamClientInterceptor(clientConf)),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return conn, healthServer
+}
+
+func TestUnaryInterceptors(t *testing.T) {
+	serverLogger, serverPath := tdrtest.NewLogger(t)
+	clientLogger, clientPath := tdrtest.NewLogger(t)
+	conn, _ := startServer(t, Config{Logger: serverLogger}, Config{Logger: clientLogger})
+
+	ctx := golog.WithTraceID(context.Background(), "trace-123")
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer secret")
+
+	var header metadata.MD
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{}, grpc.Header(&header))
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+	assert.Equal(t, []string{"trace-123"}, header.Get(DefaultTraceMetadataKey))
+
+	serverLines := tdrtest.Read(t, serverLogger, serverPath)
+	require.Len(t, serverLines, 1)
+	line := serverLines[0]
+	assert.Equal(t, "trace-123", line["traceId"])
+	assert.Equal(t, "/grpc.health.v1.Health/Check", line["method"])
+	assert.Equal(t, "OK", line["statusCode"])
+	assert.Equal(t, map[string]interface{}{"status": "SERVING"}, line["response"])
+	assert.NotContains(t, line["header"], "Authorization")
+	assert.Contains(t, line["header"], "X-Trace-Id")
+
+	clientLines := tdrtest.Read(t, clientLogger, clientPath)
+	require.Len(t, clientLines, 1)
+	assert.Equal(t, "trace-123", clientLines[0]["traceId"])
+	assert.Equal(t, "OK", clientLines[0]["statusCode"])
+	assert.Equal(t, map[string]interface{}{"direction": "outbound"}, clientLines[0]["otherData"])
+}
+
+func TestUnaryInterceptorsError(t *testing.T) {
+	serverLogger, serverPath := tdrtest.NewLogger(t)
+	clientLogger, clientPath := tdrtest.NewLogger(t)
+	conn, _ := startServer(t, Config{Logger: serverLogger}, Config{Logger: clientLogger})
+
+	_, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{Service: "unknown"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	serverLines := tdrtest.Read(t, serverLogger, serverPath)
+	require.Len(t, serverLines, 1)
+	assert.Equal(t, "NotFound", serverLines[0]["statusCode"])
+	assert.Equal(t, "unknown service", serverLines[0]["error"])
+	assert.Equal(t, map[string]interface{}{"service": "unknown"}, serverLines[0]["request"])
+
+	clientLines := tdrtest.Read(t, clientLogger, clientPath)
+	require.Len(t, clientLines, 1)
+	assert.Len(t, clientLines[0]["traceId"], 32, "client should generate a trace ID")
+	assert.Equal(t, clientLines[0]["traceId"], serverLines[0]["traceId"])
+	assert.Equal(t, "NotFound", clientLines[0]["statusCode"])
+}
+
+func TestStreamInterceptors(t *testing.T) {
+	serverLogger, serverPath := tdrtest.NewLogger(t)
+	clientLogger, clientPath := tdrtest.NewLogger(t)
+	conn, _ := startServer(t, Config{Logger: serverLogger}, Config{Logger: clientLogger})
+
+	ctx, cancel := context.WithCancel(golog.WithTraceID(context.Background(), "trace-stream"))
+	stream, err := healthpb.NewHealthClient(conn).Watch(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+
+	cancel()
+	_, err = stream.Recv()
+	require.Equal(t, codes.Canceled, status.Code(err))
+
+	clientLines := tdrtest.Read(t, clientLogger, clientPath)
+	require.Len(t, clientLines, 1)
+	assert.Equal(t, "trace-stream", clientLines[0]["traceId"])
+	assert.Equal(t, "Canceled", clientLines[0]["statusCode"])
+	assert.Equal(t, map[string]interface{}{
+		"direction":        "outbound",
+		"streamType":       "server",
+		"messagesReceived": float64(1),
+		"messagesSent":     float64(1),
+	}, clientLines[0]["otherData"])
+
+	require.Eventually(t, func() bool {
+		_ = serverLogger.Sync()
+		info, err := os.Stat(serverPath)
+		return err == nil && info.Size() > 0
+	}, time.Second, 10*time.Millisecond)
+
+	serverLines := tdrtest.Read(t, serverLogger, serverPath)
+	require.Len(t, serverLines, 1)
+	assert.Equal(t, "trace-stream", serverLines[0]["traceId"])
+	assert.Equal(t, "/grpc.health.v1.Health/Watch", serverLines[0]["method"])
+	assert.Equal(t, map[string]interface{}{}, serverLines[0]["request"])
+	assert.Equal(t, map[string]interface{}{"status": "SERVING"}, serverLines[0]["response"])
+}
+
+func TestStreamClientInterceptorClientStreaming(t *testing.T) {
+	clientLogger, clientPath := tdrtest.NewLogger(t)
+	conn, _ := startServer(t, Config{}, Config{Logger: clientLogger})
+
+	ctx := golog.WithTraceID(context.Background(), "trace-collect")
+	stream, err := conn.NewStream(ctx, &collectDesc.Streams[0], "/test.Collector/Collect")
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		msg, err := structpb.NewValue(map[string]interface{}{"n": i, "password": "secret"})
+		require.NoError(t, err)
+		require.NoError(t, stream.SendMsg(msg))
+	}
+	require.NoError(t, stream.CloseSend())
+	var count structpb.Value
+	require.NoError(t, stream.RecvMsg(&count))
+	assert.Equal(t, float64(3), count.GetNumberValue())
+
+	clientLines := tdrtest.Read(t, clientLogger, clientPath)
+	require.Len(t, clientLines, 1)
+	assert.Equal(t, "trace-collect", clientLines[0]["traceId"])
+	assert.Equal(t, "OK", clientLines[0]["statusCode"])
+	assert.Equal(t, map[string]interface{}{
+		"direction":        "outbound",
+		"streamType":       "client",
+		"messagesReceived": float64(1),
+		"messagesSent":     float64(3),
+	}, clientLines[0]["otherData"])
+	assert.Equal(t, map[string]interface{}{
+		"first": map[string]interface{}{"n": float64(0), "password": "*****"},
+		"last":  map[string]interface{}{"n": float64(2), "password": "*****"},
+	}, clientLines[0]["request"])
+	assert.Equal(t, "3", clientLines[0]["response"])
+}
+
+func TestStreamClientInterceptorUnaryOverStream(t *testing.T) {
+	clientLogger, clientPath := tdrtest.NewLogger(t)
+	conn, _ := startServer(t, Config{}, Config{Logger: clientLogger})
+
+	stream, err := conn.NewStream(context.Background(), &grpc.StreamDesc{}, "/grpc.health.v1.Health/Check")
+	require.NoError(t, err)
+	require.NoError(t, stream.SendMsg(&healthpb.HealthCheckRequest{}))
+	require.NoError(t, stream.CloseSend())
+	var resp healthpb.HealthCheckResponse
+	require.NoError(t, stream.RecvMsg(&resp))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+
+	clientLines := tdrtest.Read(t, clientLogger, clientPath)
+	require.Len(t, clientLines, 1)
+	assert.Equal(t, "/grpc.health.v1.Health/Check", clientLines[0]["method"])
+	assert.Equal(t, "OK", clientLines[0]["statusCode"])
+	assert.Equal(t, map[string]interface{}{
+		"direction":        "outbound",
+		"streamType":       "server",
+		"messagesReceived": float64(1),
+		"messagesSent":     float64(1),
+	}, clientLines[0]["otherData"])
+}
+
+func TestStreamClientInterceptorAbandonedStream(t *testing.T) {
+	clientLogger, clientPath := tdrtest.NewLogger(t)
+	conn, _ := startServer(t, Config{}, Config{Logger: clientLogger})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := healthpb.NewHealthClient(conn).Watch(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	cancel()
+
+	require.Eventually(t, func() bool {
+		_ = clientLogger.Sync()
+		info, err := os.Stat(clientPath)
+		return err == nil && info.Size() > 0
+	}, time.Second, 10*time.Millisecond)
+
+	clientLines := tdrtest.Read(t, clientLogger, clientPath)
+	require.Len(t, clientLines, 1)
+	assert.Equal(t, "Canceled", clientLines[0]["statusCode"])
+	assert.Equal(t, float64(0), clientLines[0]["otherData"].(map[string]interface{})["messagesReceived"])
+}
+
+func TestUnaryServerInterceptorMasksProto(t *testing.T) {
+	logger, path := tdrtest.NewLogger(t)
+	interceptor := UnaryServerInterceptor(Config{Logger: logger})
+
+	req, err := structpb.NewStruct(map[string]interface{}{"username": "john", "password": "secret"})
+	require.NoError(t, err)
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/auth.Auth/Login"}
+	_, err = interceptor(context.Background(), req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return structpb.NewStruct(map[string]interface{}{"access_token": "abc"})
+	})
+	require.NoError(t, err)
+
+	lines := tdrtest.Read(t, logger, path)
+	require.Len(t, lines, 1)
+	assert.Equal(t, map[string]interface{}{"username": "john", "password": "*****"}, lines[0]["request"])
+	assert.Equal(t, map[string]interface{}{"access_token": "*****"}, lines[0]["response"])
+}
+
+func TestUnaryServerInterceptorPanic(t *testing.T) {
+	logger, path := tdrtest.NewLogger(t)
+	interceptor := UnaryServerInterceptor(Config{Logger: logger})
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/svc/Panic"}
+	assert.PanicsWithValue(t, "boom", func() {
+		_, _ = interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			panic("boom")
+		})
+	})
+
+	lines := tdrtest.Read(t, logger, path)
+	require.Len(t, lines, 1)
+	assert.Equal(t, "Internal", lines[0]["statusCode"])
+	assert.Equal(t, "panic: boom", lines[0]["error"])
+}
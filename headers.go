@@ -0,0 +1,155 @@
+package golog
+
+import (
+	"iter"
+	"net/http"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// removeAuth returns a sanitized copy of a TDR header. Sensitive headers are
+// dropped or masked and sensitive cookies are masked inside Cookie and
+// Set-Cookie. The caller's header is never modified, so a request can still
+// be proxied after it was logged. Both HTTP stacks produce an http.Header;
+// other header types are returned unchanged.
+func (m *masker) removeAuth(header interface{}) interface{} {
+	source, ok := headerMap(header)
+	if !ok {
+		return header
+	}
+
+	result := make(http.Header, len(source))
+	for name, values := range source {
+		lower := strings.ToLower(name)
+		switch {
+		case m.headers[lower]:
+			strategy := m.headerStrategy(name)
+			if strategy.kind == StrategyDrop {
+				continue
+			}
+			masked := make([]string, len(values))
+			for i, value := range values {
+				masked[i] = stringOf(strategy.apply(value, m.hashSalt))
+			}
+			result[name] = masked
+		case lower == "cookie":
+			if masked := m.maskCookies(values); len(masked) > 0 {
+				result[name] = masked
+			}
+		case lower == "set-cookie":
+			if masked := m.maskSetCookies(values); len(masked) > 0 {
+				result[name] = masked
+			}
+		default:
+			result[name] = append([]string(nil), values...)
+		}
+	}
+	return result
+}
+
+// headerMap reads the supported TDR header types as an http.Header. The
+// returned map must not be modified, it may be the caller's own header.
+func headerMap(header interface{}) (http.Header, bool) {
+	switch h := header.(type) {
+	case http.Header:
+		return h, true
+	case *fasthttp.RequestHeader:
+		return fasthttpHeaderMap(h.Len(), h.All()), true
+	case *fasthttp.ResponseHeader:
+		return fasthttpHeaderMap(h.Len(), h.All()), true
+	case fasthttp.RequestHeader:
+		// passed by value, as golog accepted before the pointer was
+		return fasthttpHeaderMap(h.Len(), h.All()), true
+	case fasthttp.ResponseHeader:
+		return fasthttpHeaderMap(h.Len(), h.All()), true
+	default:
+		return nil, false
+	}
+}
+
+func fasthttpHeaderMap(size int, all iter.Seq2[[]byte, []byte]) http.Header {
+	result := make(http.Header, size)
+	for k, v := range all {
+		result.Add(string(k), string(v))
+	}
+	return result
+}
+
+// headerStrategy returns the strategy for a sensitive header. Headers are
+// dropped unless a strategy is configured for them.
+func (m *masker) headerStrategy(name string) maskStrategy {
+	if strategy, ok := m.strategies[strings.ToLower(name)]; ok {
+		return strategy
+	}
+	return maskStrategy{kind: StrategyDrop}
+}
+
+// cookieStrategy returns the strategy for a cookie, or false when the
+// cookie is not sensitive.
+func (m *masker) cookieStrategy(name string) (maskStrategy, bool) {
+	lower := strings.ToLower(name)
+	if m.cookies[lower] {
+		return m.bodyStrategy(lower), true
+	}
+	if rule, ok := m.sensitiveRule(name); ok {
+		return m.bodyStrategy(rule), true
+	}
+	return maskStrategy{}, false
+}
+
+// maskCookies masks the sensitive cookies of Cookie header values
+// ("a=1; b=2"), keeping the other cookies as they are.
+func (m *masker) maskCookies(values []string) []string {
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		pairs := strings.Split(value, ";")
+		kept := pairs[:0]
+		for _, pair := range pairs {
+			pair = strings.TrimSpace(pair)
+			if pair == "" {
+				continue
+			}
+			masked, ok := m.maskCookiePair(pair)
+			if ok {
+				kept = append(kept, masked)
+			}
+		}
+		if len(kept) > 0 {
+			result = append(result, strings.Join(kept, "; "))
+		}
+	}
+	return result
+}
+
+// maskSetCookies masks the value of sensitive Set-Cookie headers, keeping
+// their attributes.
+func (m *masker) maskSetCookies(values []string) []string {
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		pair, attrs, hasAttrs := strings.Cut(value, ";")
+		masked, ok := m.maskCookiePair(strings.TrimSpace(pair))
+		if !ok {
+			continue
+		}
+		if hasAttrs {
+			masked += ";" + attrs
+		}
+		result = append(result, masked)
+	}
+	return result
+}
+
+// maskCookiePair masks a single name=value pair. ok is false when the
+// cookie has to be dropped.
+func (m *masker) maskCookiePair(pair string) (masked string, ok bool) {
+	name, value, _ := strings.Cut(pair, "=")
+	strategy, sensitive := m.cookieStrategy(strings.TrimSpace(name))
+	if !sensitive {
+		return pair, true
+	}
+	if strategy.kind == StrategyDrop {
+		return "", false
+	}
+	return name + "=" + stringOf(strategy.apply(value, m.hashSalt)), true
+}
@@ -0,0 +1,135 @@
+package golog
+
+import (
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestRemoveAuthDoesNotMutateHeader(t *testing.T) {
+	m := policyMasker(nil)
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer token")
+	header.Set("Accept", "application/json")
+
+	result := m.removeAuth(header).(http.Header)
+	assert.Empty(t, result.Get("Authorization"))
+	assert.Equal(t, "application/json", result.Get("Accept"))
+	assert.Equal(t, "Bearer token", header.Get("Authorization"), "live header must keep its auth")
+
+	result.Set("Accept", "text/plain")
+	assert.Equal(t, "application/json", header.Get("Accept"), "result must not share values with the live header")
+
+	var fastHeader fasthttp.RequestHeader
+	fastHeader.Set("Authorization", "Bearer token")
+	fastHeader.Set("Accept", "application/json")
+
+	fastResult := m.removeAuth(&fastHeader).(http.Header)
+	assert.Empty(t, fastResult.Get("Authorization"))
+	assert.Equal(t, "application/json", fastResult.Get("Accept"))
+	assert.Equal(t, "Bearer token", string(fastHeader.Peek("Authorization")))
+}
+
+func TestRemoveAuthCookies(t *testing.T) {
+	m, err := newMasker(MaskingPolicy{
+		Keys:       []string{"token"},
+		Headers:    []string{"Authorization"},
+		Cookies:    []string{"SESSIONID", "csrf"},
+		Strategies: map[string]string{"csrf": StrategyDrop, "sessionid": KeepFirst(2)},
+	})
+	require.NoError(t, err)
+
+	header := http.Header{}
+	header.Add("Cookie", "SESSIONID=abcdef; theme=dark; csrf=x; token=t")
+	header.Add("Set-Cookie", "SESSIONID=abcdef; Path=/; HttpOnly")
+	header.Add("Set-Cookie", "csrf=x; Path=/")
+	header.Add("Set-Cookie", "theme=dark; Max-Age=60")
+
+	result := m.removeAuth(header).(http.Header)
+	assert.Equal(t, []string{"SESSIONID=ab****; theme=dark; token=*****"}, result.Values("Cookie"))
+	assert.Equal(t, []string{"SESSIONID=ab****; Path=/; HttpOnly", "theme=dark; Max-Age=60"}, result.Values("Set-Cookie"))
+	assert.Equal(t, "SESSIONID=abcdef; theme=dark; csrf=x; token=t", header.Get("Cookie"))
+
+	header = http.Header{}
+	header.Set("Cookie", "csrf=x")
+	result = m.removeAuth(header).(http.Header)
+	assert.NotContains(t, result, "Cookie", "a header left without cookies is removed")
+}
+
+func TestRemoveAuthFasthttpCookies(t *testing.T) {
+	m, err := newMasker(MaskingPolicy{Cookies: []string{"session"}})
+	require.NoError(t, err)
+
+	var req fasthttp.RequestHeader
+	req.SetCookie("session", "abc")
+	req.SetCookie("theme", "dark")
+	result := m.removeAuth(&req).(http.Header)
+	assert.Equal(t, "session=*****; theme=dark", result.Get("Cookie"))
+	assert.Equal(t, "abc", string(req.Cookie("session")))
+
+	var resp fasthttp.ResponseHeader
+	cookie := fasthttp.AcquireCookie()
+	defer fasthttp.ReleaseCookie(cookie)
+	cookie.SetKey("session")
+	cookie.SetValue("abc")
+	cookie.SetPath("/")
+	resp.SetCookie(cookie)
+	result = m.removeAuth(&resp).(http.Header)
+	assert.Equal(t, "session=*****; path=/", result.Get("Set-Cookie"))
+}
+
+func TestTDRHeaderFasthttp(t *testing.T) {
+	tmpDir := t.TempDir()
+	logger := NewLogger(Config{
+		App:          "testapp",
+		AppVer:       "1.0.0",
+		Env:          "development",
+		FileLocation: tmpDir,
+		FileMaxSize:  10,
+	})
+
+	var header fasthttp.RequestHeader
+	header.Set("Authorization", "Bearer token")
+	header.Set("X-Request-Id", "r-1")
+	logger.TDR(LogModel{Header: &header})
+	require.NoError(t, logger.Sync())
+
+	lines := readLogLines(t, filepath.Join(tmpDir, "tdr.log"))
+	require.Len(t, lines, 1)
+	logged, ok := lines[0]["header"].(map[string]interface{})
+	require.True(t, ok, "fasthttp headers are logged as a map")
+	assert.Equal(t, []interface{}{"r-1"}, logged["X-Request-Id"])
+	assert.NotContains(t, logged, "Authorization")
+	assert.Equal(t, "Bearer token", string(header.Peek("Authorization")))
+}
+
+func TestTDRHeaderFasthttpByValue(t *testing.T) {
+	tmpDir := t.TempDir()
+	logger := NewLogger(Config{
+		App:          "testapp",
+		AppVer:       "1.0.0",
+		Env:          "development",
+		FileLocation: tmpDir,
+		FileMaxSize:  10,
+	})
+
+	var header fasthttp.RequestHeader
+	header.Set("Authorization", "Bearer token")
+	header.Set("X-Request-Id", "r-1")
+	// a copy, as callers passing the header by value make
+	logger.TDR(LogModel{Header: reflect.ValueOf(&header).Elem().Interface()})
+	require.NoError(t, logger.Sync())
+
+	lines := readLogLines(t, filepath.Join(tmpDir, "tdr.log"))
+	require.Len(t, lines, 1)
+	logged, ok := lines[0]["header"].(map[string]interface{})
+	require.True(t, ok, "fasthttp headers are logged as a map")
+	assert.Equal(t, []interface{}{"r-1"}, logged["X-Request-Id"])
+	assert.NotContains(t, logged, "Authorization")
+}
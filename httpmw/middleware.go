@@ -0,0 +1,215 @@
+// Package httpmw provides net/http middleware that propagates the golog trace
+// context and writes a TDR record for every request.
+package httpmw
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tommynurwantoro/golog"
+	"github.com/tommynurwantoro/golog/internal/capture"
+	"github.com/tommynurwantoro/golog/internal/middleware"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultMaxBodySize is the number of request and response body bytes
+// captured for the TDR record when Config.MaxBodySize is zero.
+const DefaultMaxBodySize = middleware.DefaultMaxBodySize
+
+// Config configures the middleware returned by New. The zero value logs
+// through the singleton logger with the default headers and body size.
+type Config struct {
+	// Logger used to write TDR records.
+	// If nil, the singleton logger loaded with golog.Load is used.
+	Logger golog.LoggerInterface
+
+	// Header carrying the trace ID. Defaults to golog.TraceIDHeader.
+	// The resolved trace ID is echoed back in the response header.
+	TraceHeader string
+
+	// Header carrying the correlation ID. Defaults to "X-Correlation-Id".
+	CorrelationHeader string
+
+	// Header carrying the client IP when running behind a proxy,
+	// e.g. "X-Forwarded-For". If empty, the remote address is used.
+	SrcIPHeader string
+
+	// Maximum number of body bytes captured per direction. Longer bodies
+	// are logged as truncated, subject to the logger's golog.BodyLimits.
+	// Defaults to DefaultMaxBodySize. A negative value disables body capture.
+	MaxBodySize int
+
+	// Generates a trace ID when the request carries none.
+	// Defaults to golog.NewTraceID.
+	GenerateTraceID func() string
+}
+
+func (c *Config) validate() {
+	middleware.HTTPDefaults(&c.TraceHeader, &c.CorrelationHeader, &c.MaxBodySize, &c.GenerateTraceID)
+}
+
+// New returns a middleware that binds the trace ID, source IP, port and path
+// to the request context and logs the request through TDR once the handler
+// returns. Panics in the handler are logged and then re-raised.
+func New(conf Config) func(http.Handler) http.Handler {
+	conf.validate()
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ctx := r.Context()
+
+			traceID := r.Header.Get(conf.TraceHeader)
+			if traceID == "" {
+				if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+					traceID = sc.TraceID().String()
+				} else {
+					traceID = conf.GenerateTraceID()
+				}
+			}
+			srcIP := sourceIP(r, conf.SrcIPHeader)
+			ip, port := localAddr(r)
+
+			ctx = golog.WithTraceID(ctx, traceID)
+			ctx = golog.WithSrcIP(ctx, srcIP)
+			ctx = golog.WithPort(ctx, port)
+			ctx = golog.WithPath(ctx, r.URL.Path)
+			w.Header().Set(conf.TraceHeader, traceID)
+
+			// the request body is captured as the handler reads it, so
+			// streamed uploads are never buffered beyond the limit
+			reqBody := capture.NewReader(r.Body, conf.MaxBodySize)
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = reqBody
+			}
+			rw := &responseWriter{ResponseWriter: w, body: capture.Buffer{Limit: conf.MaxBodySize}}
+
+			defer func() {
+				rec := recover()
+
+				model := golog.LogModel{
+					TraceID:       traceID,
+					CorrelationID: r.Header.Get(conf.CorrelationHeader),
+					SrcIP:         srcIP,
+					IP:            ip,
+					Port:          port,
+					Path:          r.URL.RequestURI(),
+					Method:        r.Method,
+					Header:        r.Header,
+					Request:       middleware.Body(reqBody, r.Header.Get("Content-Type")),
+					Response:      middleware.Body(&rw.body, w.Header().Get("Content-Type")),
+					ResponseTime:  time.Since(start),
+				}
+
+				status := rw.status
+				if rec != nil && !rw.wroteHeader {
+					status = http.StatusInternalServerError
+				}
+				if status == 0 {
+					status = http.StatusOK
+				}
+				model.HttpStatus = uint64(status)
+				model.StatusCode = strconv.Itoa(status)
+
+				if rec != nil {
+					model.Error = fmt.Sprint(rec)
+				}
+				if rw.hijacked {
+					model.OtherData = map[string]interface{}{"hijacked": true}
+				}
+
+				middleware.LogTDR(conf.Logger, ctx, model)
+
+				if rec != nil {
+					panic(rec)
+				}
+			}()
+
+			next.ServeHTTP(rw, r.WithContext(ctx))
+		})
+	}
+}
+
+func sourceIP(r *http.Request, header string) string {
+	if header != "" {
+		if v := r.Header.Get(header); v != "" {
+			return middleware.ForwardedIP(v)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func localAddr(r *http.Request) (string, string) {
+	addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	if !ok {
+		return "", ""
+	}
+	host, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String(), ""
+	}
+	return host, port
+}
+
+// responseWriter records the status code and captures the response body.
+// It supports flushing and hijacking when the underlying writer does.
+type responseWriter struct {
+	http.ResponseWriter
+	body        capture.Buffer
+	status      int
+	wroteHeader bool
+	hijacked    bool
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = status >= 200 || status == http.StatusSwitchingProtocols
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	_, _ = w.body.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *responseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil {
+		w.hijacked = true
+		if w.status == 0 {
+			w.status = http.StatusSwitchingProtocols
+		}
+	}
+	return conn, rw, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
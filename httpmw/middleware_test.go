@@ -0,0 +1,173 @@
+package httpmw
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tommynurwantoro/golog"
+	"github.com/tommynurwantoro/golog/internal/tdrtest"
+)
+
+// newTestServer serves handler and signals on done once the wrapped handler,
+// including the middleware's TDR logging, has returned.
+func newTestServer(handler http.Handler) (*httptest.Server, chan struct{}) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+		handler.ServeHTTP(w, r)
+	}))
+	return server, done
+}
+
+func TestMiddleware(t *testing.T) {
+	logger, path := tdrtest.NewLogger(t)
+
+	var handlerTraceID string
+	handler := New(Config{Logger: logger})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlerTraceID, _ = golog.GetTraceID(r.Context())
+		body, _ := io.ReadAll(r.Body)
+		assert.JSONEq(t, `{"username":"john","password":"secret"}`, string(body))
+
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":1,"token":"abc"}`))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/users?password=secret&page=2", strings.NewReader(`{"username":"john","password":"secret"}`))
+	req.Header.Set("Authorization", "Bearer x")
+	req.Header.Set("X-Correlation-Id", "corr-1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Len(t, handlerTraceID, 32)
+	assert.Equal(t, handlerTraceID, rec.Header().Get(golog.TraceIDHeader))
+	assert.Equal(t, "Bearer x", req.Header.Get("Authorization"))
+
+	lines := tdrtest.Read(t, logger, path)
+	require.Len(t, lines, 1)
+	line := lines[0]
+	assert.Equal(t, handlerTraceID, line["traceId"])
+	assert.Equal(t, "192.0.2.1", line["srcIP"])
+	assert.Equal(t, "/users?password=*****&page=2", line["path"])
+	assert.Equal(t, "corr-1", line["correlationId"])
+	assert.Equal(t, "POST", line["method"])
+	assert.Equal(t, "201", line["statusCode"])
+	assert.Equal(t, float64(201), line["httpStatus"])
+	assert.Equal(t, map[string]interface{}{"username": "john", "password": "*****"}, line["request"])
+	assert.Equal(t, map[string]interface{}{"id": float64(1), "token": "*****"}, line["response"])
+}
+
+func TestMiddlewarePropagatesTraceID(t *testing.T) {
+	logger, path := tdrtest.NewLogger(t)
+
+	handler := New(Config{Logger: logger})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceID, _ := golog.GetTraceID(r.Context())
+		assert.Equal(t, "trace-123", traceID)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	req.Header.Set(golog.TraceIDHeader, "trace-123")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, "trace-123", rec.Header().Get(golog.TraceIDHeader))
+
+	lines := tdrtest.Read(t, logger, path)
+	require.Len(t, lines, 1)
+	assert.Equal(t, "trace-123", lines[0]["traceId"])
+	assert.Equal(t, "200", lines[0]["statusCode"])
+}
+
+func TestMiddlewareBodyLimit(t *testing.T) {
+	logger, path := tdrtest.NewLogger(t)
+
+	handler := New(Config{Logger: logger, MaxBodySize: 4})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		_, _ = w.Write([]byte("response body"))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader("request body"))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, "response body", rec.Body.String())
+
+	lines := tdrtest.Read(t, logger, path)
+	require.Len(t, lines, 1)
+	assert.Equal(t, map[string]interface{}{"truncated": true, "size": float64(12), "body": "requ"}, lines[0]["request"])
+	assert.Equal(t, map[string]interface{}{"truncated": true, "size": float64(13), "body": "resp"}, lines[0]["response"])
+}
+
+func TestMiddlewarePanic(t *testing.T) {
+	logger, path := tdrtest.NewLogger(t)
+
+	handler := New(Config{Logger: logger})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+	rec := httptest.NewRecorder()
+	assert.PanicsWithValue(t, "boom", func() {
+		handler.ServeHTTP(rec, req)
+	})
+
+	lines := tdrtest.Read(t, logger, path)
+	require.Len(t, lines, 1)
+	assert.Equal(t, "500", lines[0]["statusCode"])
+	assert.Equal(t, "boom", lines[0]["error"])
+}
+
+func TestMiddlewareStreaming(t *testing.T) {
+	logger, path := tdrtest.NewLogger(t)
+
+	handler := New(Config{Logger: logger})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, chunk := range []string{"a", "b", "c"} {
+			_, _ = w.Write([]byte(chunk))
+			require.NoError(t, http.NewResponseController(w).Flush())
+		}
+	}))
+
+	server, done := newTestServer(handler)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/stream")
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "abc", string(body))
+	<-done
+
+	lines := tdrtest.Read(t, logger, path)
+	require.Len(t, lines, 1)
+	assert.Equal(t, "abc", lines[0]["response"])
+}
+
+func TestMiddlewareHijack(t *testing.T) {
+	logger, path := tdrtest.NewLogger(t)
+
+	handler := New(Config{Logger: logger})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, buf, err := http.NewResponseController(w).Hijack()
+		require.NoError(t, err)
+		defer conn.Close()
+		_, _ = buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: close\r\n\r\n")
+		_ = buf.Flush()
+	}))
+
+	server, done := newTestServer(handler)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/ws")
+	require.NoError(t, err)
+	resp.Body.Close()
+	<-done
+
+	lines := tdrtest.Read(t, logger, path)
+	require.Len(t, lines, 1)
+	assert.Equal(t, "101", lines[0]["statusCode"])
+	assert.Equal(t, map[string]interface{}{"hijacked": true}, lines[0]["otherData"])
+}
@@ -0,0 +1,785 @@
+package golog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// SinkHTTP ships entries in batches to an HTTP endpoint. See HTTPConfig.
+const SinkHTTP = "http"
+
+// Request formats for HTTPConfig.Format.
+const (
+	// HTTPNDJSON posts the entries as newline-delimited JSON.
+	HTTPNDJSON = "ndjson"
+	// HTTPLoki posts to the Loki push API, /loki/api/v1/push.
+	HTTPLoki = "loki"
+	// HTTPElasticsearch posts to the Elasticsearch bulk API, /_bulk.
+	HTTPElasticsearch = "elasticsearch"
+)
+
+// Defaults for HTTPConfig.
+const (
+	DefaultHTTPBatchSize     = 1000
+	DefaultHTTPBatchBytes    = 1024 * 1024
+	DefaultHTTPFlushInterval = 1000
+	DefaultHTTPMaxRetries    = 3
+	DefaultHTTPMinBackoff    = 500
+	DefaultHTTPMaxBackoff    = 30000
+	DefaultHTTPSpoolMaxSize  = 100
+	DefaultHTTPMaxPending    = 100
+)
+
+// httpRequestTimeout bounds a single request to the endpoint.
+const httpRequestTimeout = 10 * time.Second
+
+// HTTPConfig configures a SinkHTTP sink. SinkConfig.Address is the URL the
+// batches are posted to.
+//
+// Entries are collected into a batch until it holds BatchSize entries or
+// BatchBytes bytes, or FlushInterval has passed, then posted from a
+// background goroutine. A batch that fails with a network error, a 408, a
+// 429 or a 5xx response is retried with exponential backoff; when the
+// retries are exhausted it is saved to SpoolDir, and later batches queue up
+// behind it there until the endpoint is back, so entries arrive in order.
+// Batches rejected with another status are dropped, and so is the oldest
+// batch waiting to be posted when MaxPending batches are waiting. Sync waits
+// until the entries written before it are posted, spooled or dropped, and
+// returns the delivery errors and drops seen since the previous Sync,
+// including the documents of HTTPElasticsearch batches that the bulk API
+// accepted but failed to index.
+type HTTPConfig struct {
+	// Request format: HTTPNDJSON (default), HTTPLoki or HTTPElasticsearch.
+	// Only HTTPLoki takes console encoded entries.
+	Format string `json:"format"`
+
+	// Headers added to every request, e.g. Authorization.
+	Headers map[string]string `json:"headers"`
+
+	// Compress requests with gzip.
+	Gzip bool `json:"gzip"`
+
+	// Entries per batch. Defaults to DefaultHTTPBatchSize.
+	BatchSize int `json:"batchSize"`
+
+	// Bytes of entries per batch, before compression. Defaults to
+	// DefaultHTTPBatchBytes.
+	BatchBytes int `json:"batchBytes"`
+
+	// Milliseconds before a batch that is not full is posted. Defaults to
+	// DefaultHTTPFlushInterval.
+	FlushInterval int `json:"flushInterval"`
+
+	// Retries of a failed batch before it is spooled. Defaults to
+	// DefaultHTTPMaxRetries; a negative value disables retries.
+	MaxRetries int `json:"maxRetries"`
+
+	// Milliseconds before the first retry, doubled on every retry up to
+	// MaxBackoff. Default to DefaultHTTPMinBackoff and
+	// DefaultHTTPMaxBackoff.
+	MinBackoff int `json:"minBackoff"`
+	MaxBackoff int `json:"maxBackoff"`
+
+	// Directory keeping the batches that could not be delivered, replayed
+	// oldest first when the endpoint is back, including after a restart.
+	// If empty, such batches are dropped.
+	SpoolDir string `json:"spoolDir"`
+
+	// Maximum size of SpoolDir in megabytes; the oldest batches are dropped
+	// beyond it. Defaults to DefaultHTTPSpoolMaxSize.
+	SpoolMaxSize int `json:"spoolMaxSize"`
+
+	// Batches kept in memory while waiting to be posted; the oldest is
+	// dropped beyond it. Defaults to DefaultHTTPMaxPending.
+	MaxPending int `json:"maxPending"`
+
+	// Stream labels of HTTPLoki requests, in addition to app, env and
+	// stream.
+	Labels map[string]string `json:"labels"`
+
+	// Index of HTTPElasticsearch documents. If empty, the index must be
+	// part of the URL, as in http://localhost:9200/logs/_bulk.
+	Index string `json:"index"`
+
+	// Client sending the requests. Defaults to a client with a 10 second
+	// timeout.
+	Client *http.Client `json:"-"`
+}
+
+// withDefaults returns c with the defaults of unset fields.
+func (c HTTPConfig) withDefaults() HTTPConfig {
+	if c.Format == "" {
+		c.Format = HTTPNDJSON
+	}
+	if c.BatchSize == 0 {
+		c.BatchSize = DefaultHTTPBatchSize
+	}
+	if c.BatchBytes == 0 {
+		c.BatchBytes = DefaultHTTPBatchBytes
+	}
+	if c.FlushInterval == 0 {
+		c.FlushInterval = DefaultHTTPFlushInterval
+	}
+	if c.MaxRetries == 0 {
+		c.MaxRetries = DefaultHTTPMaxRetries
+	}
+	if c.MinBackoff == 0 {
+		c.MinBackoff = DefaultHTTPMinBackoff
+	}
+	if c.MaxBackoff == 0 {
+		c.MaxBackoff = DefaultHTTPMaxBackoff
+	}
+	if c.MaxBackoff < c.MinBackoff {
+		c.MaxBackoff = c.MinBackoff
+	}
+	if c.SpoolMaxSize == 0 {
+		c.SpoolMaxSize = DefaultHTTPSpoolMaxSize
+	}
+	if c.MaxPending == 0 {
+		c.MaxPending = DefaultHTTPMaxPending
+	}
+	if c.Client == nil {
+		c.Client = &http.Client{Timeout: httpRequestTimeout}
+	}
+	return c
+}
+
+func validateHTTPSink(s SinkConfig) error {
+	var c HTTPConfig
+	if s.HTTP != nil {
+		c = *s.HTTP
+	}
+
+	var errs []error
+	if u, err := url.Parse(s.Address); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("golog: sink %q: %q is not an http or https URL", s.Name, s.Address))
+	}
+	switch c.Format {
+	case "", HTTPNDJSON, HTTPElasticsearch:
+		if s.Encoding == EncodingConsole {
+			errs = append(errs, fmt.Errorf("golog: sink %q: only loki batches take console encoding", s.Name))
+		}
+	case HTTPLoki:
+	default:
+		errs = append(errs, fmt.Errorf("golog: sink %q: unknown http format %q", s.Name, c.Format))
+	}
+	for name, value := range map[string]int{
+		"batchSize": c.BatchSize, "batchBytes": c.BatchBytes, "flushInterval": c.FlushInterval,
+		"minBackoff": c.MinBackoff, "maxBackoff": c.MaxBackoff, "spoolMaxSize": c.SpoolMaxSize,
+		"maxPending": c.MaxPending,
+	} {
+		if value < 0 {
+			errs = append(errs, fmt.Errorf("golog: sink %q: negative %s %d", s.Name, name, value))
+		}
+	}
+	if info, err := os.Stat(c.SpoolDir); c.SpoolDir != "" && err == nil && !info.IsDir() {
+		errs = append(errs, fmt.Errorf("golog: sink %q: spool %s is not a directory", s.Name, c.SpoolDir))
+	}
+	return errors.Join(errs...)
+}
+
+func openHTTPSink(s SinkConfig, conf Config) sinkOutput {
+	var c HTTPConfig
+	if s.HTTP != nil {
+		c = *s.HTTP
+	}
+	c = c.withDefaults()
+
+	stream := s.Stream
+	if stream == "" {
+		stream = StreamSystem
+	}
+	var format func([]httpRecord) []byte
+	contentType := "application/x-ndjson"
+	switch c.Format {
+	case HTTPLoki:
+		labels := map[string]string{"app": conf.App, "env": conf.Env, "stream": stream}
+		for k, v := range c.Labels {
+			labels[k] = v
+		}
+		format = lokiFormat(labels)
+		contentType = "application/json"
+	case HTTPElasticsearch:
+		format = bulkFormat(c.Index)
+	default:
+		format = ndjsonFormat
+	}
+
+	w := newHTTPWriter(s.Address, c, format, contentType)
+	return sinkOutput{
+		writer: w,
+		encoder: func(encoderConfig zapcore.EncoderConfig) zapcore.Encoder {
+			return &httpEncoder{Encoder: s.encoder(encoderConfig)}
+		},
+		closer: w,
+	}
+}
+
+var httpPool = buffer.NewPool()
+
+// httpEncoder prefixes each entry with its time in Unix nanoseconds and a
+// space, for the request formats that carry the time apart from the entry.
+type httpEncoder struct {
+	zapcore.Encoder
+}
+
+func (e *httpEncoder) Clone() zapcore.Encoder {
+	return &httpEncoder{Encoder: e.Encoder.Clone()}
+}
+
+func (e *httpEncoder) EncodeEntry(entry zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	msg, err := e.Encoder.EncodeEntry(entry, fields)
+	if err != nil {
+		return nil, err
+	}
+	defer msg.Free()
+
+	buf := httpPool.Get()
+	buf.AppendInt(entry.Time.UnixNano())
+	buf.AppendByte(' ')
+	buf.AppendString(strings.TrimRight(msg.String(), "\n"))
+	return buf, nil
+}
+
+// httpRecord is an entry written by an httpEncoder.
+type httpRecord struct {
+	time  string
+	entry []byte
+}
+
+func ndjsonFormat(records []httpRecord) []byte {
+	var b bytes.Buffer
+	for _, r := range records {
+		b.Write(r.entry)
+		b.WriteByte('\n')
+	}
+	return b.Bytes()
+}
+
+func bulkFormat(index string) func([]httpRecord) []byte {
+	action := []byte(`{"index":{}}` + "\n")
+	if index != "" {
+		quoted, _ := json.Marshal(index)
+		action = []byte(`{"index":{"_index":` + string(quoted) + "}}\n")
+	}
+	return func(records []httpRecord) []byte {
+		var b bytes.Buffer
+		for _, r := range records {
+			b.Write(action)
+			b.Write(r.entry)
+			b.WriteByte('\n')
+		}
+		return b.Bytes()
+	}
+}
+
+func lokiFormat(labels map[string]string) func([]httpRecord) []byte {
+	type lokiStream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	}
+	type lokiPush struct {
+		Streams []lokiStream `json:"streams"`
+	}
+	return func(records []httpRecord) []byte {
+		values := make([][2]string, len(records))
+		for i, r := range records {
+			values[i] = [2]string{r.time, string(r.entry)}
+		}
+		body, _ := json.Marshal(lokiPush{Streams: []lokiStream{{Stream: labels, Values: values}}})
+		return body
+	}
+}
+
+// httpWriter collects entries into batches, posted by a background
+// goroutine.
+type httpWriter struct {
+	url         string
+	conf        HTTPConfig
+	format      func([]httpRecord) []byte
+	contentType string
+	spool       *spool
+
+	mu      sync.Mutex
+	batch   []httpRecord
+	bytes   int
+	started time.Time
+	pending [][]byte
+	// cut and shipped count batches, so Sync can wait for the batches
+	// cut before it was called; dropped batches count as shipped
+	cut         uint64
+	shipped     uint64
+	shippedCond *sync.Cond
+	dropped     int
+	err         error
+	closed      bool
+
+	kick chan struct{}
+	stop chan struct{}
+	done chan struct{}
+
+	// state of the shipping goroutine
+	backoff   time.Duration
+	nextRetry time.Time
+}
+
+func newHTTPWriter(endpoint string, conf HTTPConfig, format func([]httpRecord) []byte, contentType string) *httpWriter {
+	w := &httpWriter{
+		url:         endpoint,
+		conf:        conf,
+		format:      format,
+		contentType: contentType,
+		kick:        make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+		backoff:     time.Duration(conf.MinBackoff) * time.Millisecond,
+	}
+	w.shippedCond = sync.NewCond(&w.mu)
+	if conf.SpoolDir != "" {
+		s, err := openSpool(conf.SpoolDir, int64(conf.SpoolMaxSize)*1024*1024)
+		if err != nil {
+			w.err = err
+		}
+		w.spool = s
+	}
+	go w.run()
+	return w
+}
+
+// Write adds an entry to the current batch, cutting the batch when it is
+// full.
+func (w *httpWriter) Write(p []byte) (int, error) {
+	ts, entry, ok := bytes.Cut(p, []byte{' '})
+	if !ok {
+		return 0, errors.New("golog: http sink entry without a time")
+	}
+	r := httpRecord{time: string(ts), entry: append([]byte(nil), entry...)}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, errors.New("golog: write to closed http sink")
+	}
+	if len(w.batch) == 0 {
+		w.started = time.Now()
+	}
+	w.batch = append(w.batch, r)
+	w.bytes += len(r.entry)
+	if len(w.batch) >= w.conf.BatchSize || w.bytes >= w.conf.BatchBytes {
+		w.cutBatch()
+	}
+	return len(p), nil
+}
+
+// cutBatch queues the current batch for shipping, dropping the oldest
+// pending batch if the queue is full. Callers hold w.mu.
+func (w *httpWriter) cutBatch() {
+	if len(w.batch) == 0 {
+		return
+	}
+	if len(w.pending) >= w.conf.MaxPending {
+		w.pending[0] = nil
+		w.pending = w.pending[1:]
+		w.dropped++
+		w.shipped++
+		w.shippedCond.Broadcast()
+	}
+	w.pending = append(w.pending, w.format(w.batch))
+	w.batch, w.bytes = nil, 0
+	w.cut++
+	select {
+	case w.kick <- struct{}{}:
+	default:
+	}
+}
+
+// run ships the batches until the writer is closed.
+func (w *httpWriter) run() {
+	defer close(w.done)
+
+	interval := time.Duration(w.conf.FlushInterval) * time.Millisecond
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.kick:
+		case <-ticker.C:
+			w.mu.Lock()
+			if len(w.batch) > 0 && time.Since(w.started) >= interval {
+				w.cutBatch()
+			}
+			w.mu.Unlock()
+		case <-w.stop:
+			w.mu.Lock()
+			w.cutBatch()
+			w.mu.Unlock()
+			w.ship()
+			return
+		}
+		w.ship()
+	}
+}
+
+// ship delivers the pending batches in order, then replays the spool if a
+// retry is due.
+func (w *httpWriter) ship() {
+	for {
+		w.mu.Lock()
+		if len(w.pending) == 0 {
+			w.mu.Unlock()
+			break
+		}
+		body := w.pending[0]
+		w.pending = w.pending[1:]
+		w.mu.Unlock()
+
+		err := w.deliver(body)
+
+		w.mu.Lock()
+		w.shipped++
+		if err != nil {
+			w.err = errors.Join(w.err, err)
+		}
+		w.shippedCond.Broadcast()
+		w.mu.Unlock()
+	}
+	if err := w.replay(); err != nil {
+		w.mu.Lock()
+		w.err = errors.Join(w.err, err)
+		w.mu.Unlock()
+	}
+}
+
+// deliver posts body, retrying with backoff, or spools it. While the spool
+// holds batches, body is spooled behind them to keep the order.
+func (w *httpWriter) deliver(body []byte) error {
+	if w.spool != nil && w.spool.len() > 0 {
+		if err := w.spool.add(body); err != nil {
+			return err
+		}
+		return w.replay()
+	}
+
+	backoff := time.Duration(w.conf.MinBackoff) * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		retry, err := w.post(body)
+		if err == nil {
+			return nil
+		}
+		if !retry {
+			return droppedBatch("a batch", err)
+		}
+		if attempt >= w.conf.MaxRetries || !w.sleep(backoff) {
+			if w.spool == nil {
+				return droppedBatch("a batch", err)
+			}
+			w.nextRetry = time.Now().Add(w.backoff)
+			return errors.Join(err, w.spool.add(body))
+		}
+		backoff = min(backoff*2, time.Duration(w.conf.MaxBackoff)*time.Millisecond)
+	}
+}
+
+// replay posts the spooled batches oldest first, once the backoff since
+// the last failure has passed. It stops at the first batch that fails.
+func (w *httpWriter) replay() error {
+	if w.spool == nil || time.Now().Before(w.nextRetry) {
+		return nil
+	}
+	maxBackoff := time.Duration(w.conf.MaxBackoff) * time.Millisecond
+	for _, name := range w.spool.names() {
+		body, err := w.spool.read(name)
+		if err != nil {
+			return err
+		}
+		retry, err := w.post(body)
+		if err != nil && retry {
+			w.nextRetry = time.Now().Add(w.backoff)
+			w.backoff = min(w.backoff*2, maxBackoff)
+			return nil
+		}
+		w.backoff = time.Duration(w.conf.MinBackoff) * time.Millisecond
+		if rmErr := w.spool.remove(name); rmErr != nil {
+			return rmErr
+		}
+		if err != nil {
+			return droppedBatch("a spooled batch", err)
+		}
+	}
+	return nil
+}
+
+// droppedBatch describes the failure of a batch that is not posted again.
+// Documents rejected by the bulk API are reported as is, since the rest of
+// their batch was indexed.
+func droppedBatch(what string, err error) error {
+	var rejected *bulkError
+	if errors.As(err, &rejected) {
+		return err
+	}
+	return fmt.Errorf("golog: http sink dropped %s: %w", what, err)
+}
+
+// sleep waits for d, returning false if the writer is closed meanwhile.
+func (w *httpWriter) sleep(d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-w.stop:
+		return false
+	}
+}
+
+// post sends one batch and reports whether a failure is worth retrying.
+func (w *httpWriter) post(body []byte) (retry bool, err error) {
+	var reader io.Reader = bytes.NewReader(body)
+	if w.conf.Gzip {
+		var b bytes.Buffer
+		zw := gzip.NewWriter(&b)
+		_, _ = zw.Write(body)
+		_ = zw.Close()
+		reader = &b
+	}
+	req, err := http.NewRequest(http.MethodPost, w.url, reader)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", w.contentType)
+	if w.conf.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for k, v := range w.conf.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := w.conf.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	switch {
+	case resp.StatusCode < 300:
+		if w.conf.Format == HTTPElasticsearch {
+			return false, bulkErrors(io.MultiReader(bytes.NewReader(msg), resp.Body))
+		}
+		return false, nil
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode >= 500:
+		return true, fmt.Errorf("golog: POST %s: %s", w.url, resp.Status)
+	default:
+		return false, fmt.Errorf("golog: POST %s: %s: %s", w.url, resp.Status, bytes.TrimSpace(msg))
+	}
+}
+
+// bulkError reports the documents of a batch that the Elasticsearch bulk
+// API failed to index.
+type bulkError struct {
+	failed int
+	total  int
+	reason string
+}
+
+func (e *bulkError) Error() string {
+	return fmt.Sprintf("golog: elasticsearch rejected %d of %d documents: %s", e.failed, e.total, e.reason)
+}
+
+// bulkErrors reads a bulk API response, which has a 2xx status even when
+// some documents were not indexed, and reports those documents.
+func bulkErrors(body io.Reader) error {
+	var resp struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int `json:"status"`
+			Error  struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(body).Decode(&resp); err != nil || !resp.Errors {
+		return nil
+	}
+	rejected := &bulkError{total: len(resp.Items)}
+	for _, item := range resp.Items {
+		for _, result := range item {
+			if result.Status < 300 {
+				continue
+			}
+			rejected.failed++
+			if rejected.reason == "" {
+				rejected.reason = result.Error.Type + ": " + result.Error.Reason
+			}
+		}
+	}
+	if rejected.failed == 0 {
+		return nil
+	}
+	return rejected
+}
+
+// Sync posts the current batch and waits until every batch cut before the
+// call is delivered, spooled or dropped. It returns the delivery errors
+// seen since the previous Sync.
+func (w *httpWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.cutBatch()
+	target := w.cut
+	for w.shipped < target && !w.closed {
+		w.shippedCond.Wait()
+	}
+	return w.takeErr()
+}
+
+// takeErr returns and resets the failures and drops seen so far. w.mu must
+// be held.
+func (w *httpWriter) takeErr() error {
+	err := w.err
+	if w.dropped > 0 {
+		err = errors.Join(err, fmt.Errorf("golog: http sink queue full, dropped %d batches", w.dropped))
+	}
+	w.err = nil
+	w.dropped = 0
+	return err
+}
+
+// Close ships the remaining entries, spooling what cannot be delivered
+// without waiting for retries, and stops the background goroutine.
+func (w *httpWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.shippedCond.Broadcast()
+	w.mu.Unlock()
+
+	close(w.stop)
+	<-w.done
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.takeErr()
+}
+
+// writesEntries keeps async writes from merging entries, which each carry
+// their own time.
+func (w *httpWriter) writesEntries() bool {
+	return true
+}
+
+// spool keeps batches in numbered files of a directory.
+type spool struct {
+	dir     string
+	maxSize int64
+
+	mu   sync.Mutex
+	next uint64
+}
+
+func openSpool(dir string, maxSize int64) (*spool, error) {
+	s := &spool{dir: dir, maxSize: maxSize}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return s, fmt.Errorf("golog: http sink spool: %w", err)
+	}
+	if names := s.names(); len(names) > 0 {
+		last, _ := strconv.ParseUint(strings.TrimSuffix(names[len(names)-1], ".batch"), 10, 64)
+		s.next = last + 1
+	}
+	return s, nil
+}
+
+// names returns the spooled batches, oldest first.
+func (s *spool) names() []string {
+	entries, _ := os.ReadDir(s.dir)
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".batch") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *spool) len() int {
+	return len(s.names())
+}
+
+// add saves body as the newest batch, dropping the oldest ones beyond the
+// maximum size.
+func (s *spool) add(body []byte) error {
+	s.mu.Lock()
+	name := fmt.Sprintf("%020d.batch", s.next)
+	s.next++
+	s.mu.Unlock()
+
+	// written aside and renamed, so a crash leaves no partial batch
+	tmp := filepath.Join(s.dir, name+".tmp")
+	if err := os.WriteFile(tmp, body, 0o600); err != nil {
+		return fmt.Errorf("golog: http sink spool: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, name)); err != nil {
+		return fmt.Errorf("golog: http sink spool: %w", err)
+	}
+	return s.trim()
+}
+
+func (s *spool) trim() error {
+	names := s.names()
+	sizes := make([]int64, len(names))
+	var total int64
+	for i, name := range names {
+		if info, err := os.Stat(filepath.Join(s.dir, name)); err == nil {
+			sizes[i] = info.Size()
+			total += sizes[i]
+		}
+	}
+	var dropped int
+	for i := 0; total > s.maxSize && i < len(names)-1; i++ {
+		if err := s.remove(names[i]); err != nil {
+			return err
+		}
+		total -= sizes[i]
+		dropped++
+	}
+	if dropped > 0 {
+		return fmt.Errorf("golog: http sink spool full, dropped %d batches", dropped)
+	}
+	return nil
+}
+
+func (s *spool) read(name string) ([]byte, error) {
+	body, err := os.ReadFile(filepath.Join(s.dir, name))
+	if err != nil {
+		return nil, fmt.Errorf("golog: http sink spool: %w", err)
+	}
+	return body, nil
+}
+
+func (s *spool) remove(name string) error {
+	if err := os.Remove(filepath.Join(s.dir, name)); err != nil {
+		return fmt.Errorf("golog: http sink spool: %w", err)
+	}
+	return nil
+}
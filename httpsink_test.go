@@ -0,0 +1,362 @@
+package golog
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// batchServer records the requests it receives, answering with status
+// while it is not zero.
+type batchServer struct {
+	*httptest.Server
+	status atomic.Int32
+
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   []string
+}
+
+func newBatchServer(t *testing.T) *batchServer {
+	t.Helper()
+	s := &batchServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status := s.status.Load(); status != 0 {
+			w.WriteHeader(int(status))
+			return
+		}
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			body = zr
+		}
+		data, _ := io.ReadAll(body)
+		s.mu.Lock()
+		s.requests = append(s.requests, r)
+		s.bodies = append(s.bodies, string(data))
+		s.mu.Unlock()
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *batchServer) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.bodies...)
+}
+
+// messages returns the messages of the NDJSON entries received, in order.
+func (s *batchServer) messages(t *testing.T) []string {
+	t.Helper()
+	var messages []string
+	for _, body := range s.received() {
+		for _, line := range strings.Split(strings.TrimSuffix(body, "\n"), "\n") {
+			var entry map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(line), &entry))
+			messages = append(messages, entry["message"].(string))
+		}
+	}
+	return messages
+}
+
+func newHTTPSinkLogger(t *testing.T, url string, conf *HTTPConfig) *Log {
+	t.Helper()
+	logger := NewLogger(Config{
+		App: "testapp",
+		Env: "production",
+		Sinks: []SinkConfig{
+			{Name: "http", Type: SinkHTTP, Address: url, HTTP: conf},
+		},
+	}).(*Log)
+	t.Cleanup(func() { _ = logger.Close() })
+	return logger
+}
+
+func TestHTTPSinkBatches(t *testing.T) {
+	server := newBatchServer(t)
+	logger := newHTTPSinkLogger(t, server.URL, &HTTPConfig{
+		BatchSize:     2,
+		FlushInterval: 60000,
+		Gzip:          true,
+		Headers:       map[string]string{"Authorization": "Bearer token"},
+	})
+
+	for i := 1; i <= 5; i++ {
+		logger.Info(strconv.Itoa(i))
+	}
+	require.NoError(t, logger.Sync())
+
+	bodies := server.received()
+	require.Len(t, bodies, 3, "batches of two, then the rest on Sync")
+	assert.Equal(t, 2, strings.Count(bodies[0], "\n"))
+	assert.Equal(t, 1, strings.Count(bodies[2], "\n"))
+	assert.Equal(t, []string{"1", "2", "3", "4", "5"}, server.messages(t))
+
+	req := server.requests[0]
+	assert.Equal(t, "application/x-ndjson", req.Header.Get("Content-Type"))
+	assert.Equal(t, "gzip", req.Header.Get("Content-Encoding"))
+	assert.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+}
+
+func TestHTTPSinkFlushInterval(t *testing.T) {
+	server := newBatchServer(t)
+	logger := newHTTPSinkLogger(t, server.URL, &HTTPConfig{BatchBytes: 1 << 20, FlushInterval: 20})
+
+	logger.Info("waiting")
+	assert.Eventually(t, func() bool {
+		return len(server.received()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestHTTPSinkLoki(t *testing.T) {
+	server := newBatchServer(t)
+	logger := NewLogger(Config{
+		App: "testapp",
+		Env: "production",
+		Sinks: []SinkConfig{
+			{Name: "loki", Stream: StreamTDR, Type: SinkHTTP, Address: server.URL,
+				HTTP: &HTTPConfig{Format: HTTPLoki, Labels: map[string]string{"team": "payments"}}},
+		},
+	}).(*Log)
+	defer logger.Close()
+
+	before := time.Now()
+	logger.TDR(LogModel{CorrelationID: "c-1"})
+	require.NoError(t, logger.Sync())
+
+	bodies := server.received()
+	require.Len(t, bodies, 1)
+	assert.Equal(t, "application/json", server.requests[0].Header.Get("Content-Type"))
+	var push struct {
+		Streams []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		} `json:"streams"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(bodies[0]), &push))
+	require.Len(t, push.Streams, 1)
+	assert.Equal(t, map[string]string{"app": "testapp", "env": "production", "stream": "tdr", "team": "payments"},
+		push.Streams[0].Stream)
+	require.Len(t, push.Streams[0].Values, 1)
+	ts, err := strconv.ParseInt(push.Streams[0].Values[0][0], 10, 64)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, ts, before.UnixNano())
+	assert.Contains(t, push.Streams[0].Values[0][1], `"correlationId":"c-1"`)
+}
+
+func TestHTTPSinkElasticsearch(t *testing.T) {
+	server := newBatchServer(t)
+	logger := newHTTPSinkLogger(t, server.URL+"/_bulk", &HTTPConfig{Format: HTTPElasticsearch, Index: "logs"})
+
+	logger.Info("one")
+	logger.Info("two")
+	require.NoError(t, logger.Sync())
+
+	bodies := server.received()
+	require.Len(t, bodies, 1)
+	lines := strings.Split(strings.TrimSuffix(bodies[0], "\n"), "\n")
+	require.Len(t, lines, 4)
+	assert.Equal(t, `{"index":{"_index":"logs"}}`, lines[0])
+	assert.Contains(t, lines[1], `"message":"one"`)
+	assert.Equal(t, `{"index":{"_index":"logs"}}`, lines[2])
+	assert.Contains(t, lines[3], `"message":"two"`)
+	assert.Equal(t, "/_bulk", server.requests[0].URL.Path)
+}
+
+func TestHTTPSinkElasticsearchItemErrors(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if !failing.Load() {
+			_, _ = w.Write([]byte(`{"took":1,"errors":false,"items":[{"index":{"status":201}}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"took":1,"errors":true,"items":[
+			{"index":{"_index":"logs","status":201}},
+			{"index":{"_index":"logs","status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse field [count]"}}},
+			{"index":{"_index":"logs","status":429,"error":{"type":"es_rejected_execution_exception","reason":"queue full"}}}
+		]}`))
+	}))
+	defer server.Close()
+	logger := newHTTPSinkLogger(t, server.URL+"/_bulk", &HTTPConfig{Format: HTTPElasticsearch, Index: "logs"})
+
+	logger.Info("one")
+	logger.Info("two")
+	logger.Info("three")
+	err := logger.Sync()
+	assert.EqualError(t, err, "golog: elasticsearch rejected 2 of 3 documents: mapper_parsing_exception: failed to parse field [count]")
+
+	failing.Store(false)
+	logger.Info("four")
+	assert.NoError(t, logger.Sync())
+}
+
+func TestHTTPWriterDropsOldestWhenPendingFull(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(data))
+		first := len(bodies) == 1
+		mu.Unlock()
+		if first {
+			close(started)
+			<-release
+		}
+	}))
+	defer server.Close()
+	w := newHTTPWriter(server.URL, HTTPConfig{BatchSize: 1, MaxPending: 2}.withDefaults(), ndjsonFormat, "application/x-ndjson")
+	defer w.Close()
+	write := func(entry string) {
+		_, err := w.Write([]byte("1 " + entry))
+		require.NoError(t, err)
+	}
+
+	write("1")
+	<-started
+	write("2")
+	write("3")
+	write("4")
+	close(release)
+	assert.EqualError(t, w.Sync(), "golog: http sink queue full, dropped 1 batches")
+	assert.NoError(t, w.Sync(), "reported once")
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"1\n", "3\n", "4\n"}, bodies)
+}
+
+func TestHTTPSinkRetries(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	logger := newHTTPSinkLogger(t, server.URL, &HTTPConfig{MinBackoff: 1, MaxBackoff: 5})
+
+	logger.Info("retried")
+	require.NoError(t, logger.Sync())
+	assert.Equal(t, int32(3), attempts.Load())
+}
+
+func TestHTTPSinkDropsRejectedBatches(t *testing.T) {
+	server := newBatchServer(t)
+	server.status.Store(http.StatusBadRequest)
+	spoolDir := t.TempDir()
+	logger := newHTTPSinkLogger(t, server.URL, &HTTPConfig{MinBackoff: 1, SpoolDir: spoolDir})
+
+	logger.Info("rejected")
+	assert.ErrorContains(t, logger.Sync(), "400 Bad Request")
+	entries, err := os.ReadDir(spoolDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "a rejected batch is not retried later")
+}
+
+func TestHTTPSinkSpoolsAndReplaysInOrder(t *testing.T) {
+	server := newBatchServer(t)
+	server.status.Store(http.StatusBadGateway)
+	spoolDir := t.TempDir()
+	logger := newHTTPSinkLogger(t, server.URL, &HTTPConfig{
+		BatchSize:  1,
+		MaxRetries: 1,
+		MinBackoff: 1,
+		MaxBackoff: 5,
+		SpoolDir:   spoolDir,
+	})
+
+	logger.Info("1")
+	assert.ErrorContains(t, logger.Sync(), "502 Bad Gateway")
+	logger.Info("2")
+	logger.Info("3")
+	require.NoError(t, logger.Sync())
+	entries, err := os.ReadDir(spoolDir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+	assert.Empty(t, server.received())
+
+	// the backend is back
+	server.status.Store(0)
+	time.Sleep(10 * time.Millisecond)
+	logger.Info("4")
+	require.NoError(t, logger.Sync())
+	assert.Equal(t, []string{"1", "2", "3", "4"}, server.messages(t))
+	entries, err = os.ReadDir(spoolDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestHTTPSinkReplaysSpoolAfterRestart(t *testing.T) {
+	server := newBatchServer(t)
+	server.status.Store(http.StatusServiceUnavailable)
+	spoolDir := t.TempDir()
+	conf := &HTTPConfig{MaxRetries: -1, FlushInterval: 10, SpoolDir: spoolDir}
+
+	logger := newHTTPSinkLogger(t, server.URL, conf)
+	logger.Info("before restart")
+	assert.ErrorContains(t, logger.Close(), "503 Service Unavailable", "the failure is reported, the batch kept")
+	entries, err := os.ReadDir(spoolDir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	server.status.Store(0)
+	logger = newHTTPSinkLogger(t, server.URL, conf)
+	logger.Info("after restart")
+	assert.Eventually(t, func() bool {
+		return len(server.received()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"before restart", "after restart"}, server.messages(t))
+}
+
+func TestSpoolDropsOldestBeyondMaxSize(t *testing.T) {
+	s, err := openSpool(t.TempDir(), 10)
+	require.NoError(t, err)
+	require.NoError(t, s.add([]byte("123456")))
+	assert.ErrorContains(t, s.add([]byte("789012")), "dropped 1 batches")
+	names := s.names()
+	require.Len(t, names, 1)
+	body, err := s.read(names[0])
+	require.NoError(t, err)
+	assert.Equal(t, "789012", string(body))
+}
+
+func TestHTTPSinkValidate(t *testing.T) {
+	assert.NoError(t, SinkConfig{Name: "x", Type: SinkHTTP, Address: "http://localhost:3100/loki/api/v1/push",
+		Encoding: EncodingConsole, HTTP: &HTTPConfig{Format: HTTPLoki}}.Validate())
+	assert.Error(t, SinkConfig{Name: "x", Type: SinkHTTP}.Validate())
+	assert.Error(t, SinkConfig{Name: "x", Type: SinkHTTP, Address: "localhost:9200"}.Validate())
+	assert.Error(t, SinkConfig{Name: "x", Type: SinkHTTP, Address: "http://localhost:9200",
+		Encoding: EncodingConsole}.Validate())
+	assert.Error(t, SinkConfig{Name: "x", Type: SinkHTTP, Address: "http://localhost:9200",
+		HTTP: &HTTPConfig{Format: "splunk"}}.Validate())
+	assert.Error(t, SinkConfig{Name: "x", Type: SinkHTTP, Address: "http://localhost:9200",
+		HTTP: &HTTPConfig{BatchSize: -1}}.Validate())
+	assert.Error(t, SinkConfig{Name: "x", Type: SinkHTTP, Address: "http://localhost:9200",
+		HTTP: &HTTPConfig{MaxPending: -1}}.Validate())
+
+	file := writeConfigFile(t, "spool", "")
+	assert.Error(t, SinkConfig{Name: "x", Type: SinkHTTP, Address: "http://localhost:9200",
+		HTTP: &HTTPConfig{SpoolDir: file}}.Validate())
+}
@@ -0,0 +1,88 @@
+// Package capture records the bodies of HTTP requests and responses for TDR
+// records, shared by golog.Transport and the middleware packages.
+package capture
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"sync"
+)
+
+// Buffer keeps the first Limit bytes written to it and counts the rest.
+// Once the body outgrows the limit it is hashed, so the TDR record can
+// identify a body it does not carry in full. A zero Limit disables capture.
+// It is safe for concurrent use.
+type Buffer struct {
+	Limit int
+
+	mu   sync.Mutex
+	buf  bytes.Buffer
+	size int64
+	hash hash.Hash
+}
+
+// Write records p. It never fails.
+func (c *Buffer) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.size += int64(len(p))
+	if c.hash != nil {
+		c.hash.Write(p)
+		return len(p), nil
+	}
+	room := c.Limit - c.buf.Len()
+	if len(p) <= room {
+		c.buf.Write(p)
+		return len(p), nil
+	}
+	if c.Limit == 0 {
+		return len(p), nil
+	}
+	c.buf.Write(p[:room])
+	c.hash = sha256.New()
+	c.hash.Write(c.buf.Bytes())
+	c.hash.Write(p[room:])
+	return len(p), nil
+}
+
+// Snapshot returns a copy of the captured bytes, the size of the whole body
+// and, if it outgrew the limit, its hex SHA-256. ok is false when there is
+// no body or capture is disabled.
+func (c *Buffer) Snapshot() (data []byte, size int64, sum string, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.size == 0 || c.Limit == 0 {
+		return nil, 0, "", false
+	}
+	if c.hash != nil {
+		sum = hex.EncodeToString(c.hash.Sum(nil))
+	}
+	return bytes.Clone(c.buf.Bytes()), c.size, sum, true
+}
+
+// Reader captures a body as it is read, so streamed bodies are never
+// buffered beyond the capture limit. Only Read and Close are exposed, so
+// the wrapped body is not mistaken for a writable one.
+type Reader struct {
+	io.ReadCloser
+	buf Buffer
+}
+
+// NewReader returns a Reader of body capturing up to limit bytes.
+func NewReader(body io.ReadCloser, limit int) *Reader {
+	return &Reader{ReadCloser: body, buf: Buffer{Limit: limit}}
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	_, _ = r.buf.Write(p[:n])
+	return n, err
+}
+
+// Snapshot returns what was read so far, as Buffer.Snapshot does.
+func (r *Reader) Snapshot() (data []byte, size int64, sum string, ok bool) {
+	return r.buf.Snapshot()
+}
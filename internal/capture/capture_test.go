@@ -0,0 +1,53 @@
+package capture
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer(t *testing.T) {
+	var b Buffer
+	_, _ = b.Write([]byte("ignored"))
+	_, _, _, ok := b.Snapshot()
+	assert.False(t, ok, "capture disabled")
+
+	b = Buffer{Limit: 4}
+	_, _, _, ok = b.Snapshot()
+	assert.False(t, ok, "no body")
+
+	_, _ = b.Write([]byte("abc"))
+	data, size, sum, ok := b.Snapshot()
+	require.True(t, ok)
+	assert.Equal(t, "abc", string(data))
+	assert.Equal(t, int64(3), size)
+	assert.Empty(t, sum)
+
+	_, _ = b.Write([]byte("defgh"))
+	data, size, sum, _ = b.Snapshot()
+	whole := sha256.Sum256([]byte("abcdefgh"))
+	assert.Equal(t, "abcd", string(data))
+	assert.Equal(t, int64(8), size)
+	assert.Equal(t, hex.EncodeToString(whole[:]), sum)
+}
+
+func TestReader(t *testing.T) {
+	r := NewReader(io.NopCloser(strings.NewReader("hello world")), 5)
+	read, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(read))
+
+	data, size, sum, ok := r.Snapshot()
+	require.True(t, ok)
+	assert.Equal(t, "hello", string(data))
+	assert.Equal(t, int64(11), size)
+	assert.NotEmpty(t, sum)
+
+	_, writable := interface{}(r).(io.Writer)
+	assert.False(t, writable)
+}
@@ -0,0 +1,81 @@
+// Package middleware holds the configuration defaults and the TDR emission
+// shared by the httpmw, fasthttpmw and grpcmw packages.
+package middleware
+
+import (
+	"context"
+	"strings"
+
+	"github.com/tommynurwantoro/golog"
+)
+
+// DefaultCorrelationHeader is the header carrying the correlation ID when
+// an HTTP middleware configuration names none.
+const DefaultCorrelationHeader = "X-Correlation-Id"
+
+// DefaultMaxBodySize is the number of body bytes an HTTP middleware
+// captures per direction when its configuration sets none.
+const DefaultMaxBodySize = 64 * 1024
+
+// Defaults fills in the trace ID key, with defaultKey, and the trace ID
+// generator, with golog.NewTraceID, when they are unset.
+func Defaults(traceKey *string, defaultKey string, generateTraceID *func() string) {
+	if *traceKey == "" {
+		*traceKey = defaultKey
+	}
+	if *generateTraceID == nil {
+		*generateTraceID = golog.NewTraceID
+	}
+}
+
+// HTTPDefaults fills in the options of the HTTP middlewares as Defaults
+// does, with golog.TraceIDHeader as the trace header, and the correlation
+// header and the body size. A negative body size disables capture and is
+// resolved to zero.
+func HTTPDefaults(traceHeader, correlationHeader *string, maxBodySize *int, generateTraceID *func() string) {
+	Defaults(traceHeader, golog.TraceIDHeader, generateTraceID)
+	if *correlationHeader == "" {
+		*correlationHeader = DefaultCorrelationHeader
+	}
+	if *maxBodySize == 0 {
+		*maxBodySize = DefaultMaxBodySize
+	}
+	if *maxBodySize < 0 {
+		*maxBodySize = 0
+	}
+}
+
+// LogTDR writes model through logger, or through the singleton logger
+// loaded with golog.Load when logger is nil.
+func LogTDR(logger golog.LoggerInterface, ctx context.Context, model golog.LogModel) {
+	if logger != nil {
+		logger.WithContext(ctx).TDR(model)
+		return
+	}
+	if l := golog.WithContext(ctx); l != nil {
+		l.TDR(model)
+	}
+}
+
+// ForwardedIP returns the client address of a proxy header value such as
+// X-Forwarded-For, the first of its comma-separated addresses.
+func ForwardedIP(value string) string {
+	first, _, _ := strings.Cut(value, ",")
+	return strings.TrimSpace(first)
+}
+
+// Snapshotter is implemented by the capture.Buffer and capture.Reader
+// recording a body.
+type Snapshotter interface {
+	Snapshot() (data []byte, size int64, sum string, ok bool)
+}
+
+// Body returns the body recorded by c for the TDR record, or nil when there
+// is none or capture is disabled.
+func Body(c Snapshotter, contentType string) interface{} {
+	data, size, sum, ok := c.Snapshot()
+	if !ok {
+		return nil
+	}
+	return golog.Body{Data: data, Size: size, ContentType: contentType, SHA256: sum}
+}
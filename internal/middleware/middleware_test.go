@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tommynurwantoro/golog"
+	"github.com/tommynurwantoro/golog/internal/capture"
+)
+
+func TestHTTPDefaults(t *testing.T) {
+	var traceHeader, correlationHeader string
+	var maxBodySize int
+	var generate func() string
+	HTTPDefaults(&traceHeader, &correlationHeader, &maxBodySize, &generate)
+	assert.Equal(t, golog.TraceIDHeader, traceHeader)
+	assert.Equal(t, DefaultCorrelationHeader, correlationHeader)
+	assert.Equal(t, DefaultMaxBodySize, maxBodySize)
+	assert.NotNil(t, generate)
+
+	traceHeader, correlationHeader, maxBodySize = "X-Request-Id", "X-Flow-Id", -1
+	HTTPDefaults(&traceHeader, &correlationHeader, &maxBodySize, &generate)
+	assert.Equal(t, "X-Request-Id", traceHeader)
+	assert.Equal(t, "X-Flow-Id", correlationHeader)
+	assert.Equal(t, 0, maxBodySize)
+}
+
+func TestForwardedIP(t *testing.T) {
+	assert.Equal(t, "203.0.113.7", ForwardedIP(" 203.0.113.7 , 10.0.0.1"))
+	assert.Equal(t, "203.0.113.7", ForwardedIP("203.0.113.7"))
+}
+
+func TestBodyHashesOverflow(t *testing.T) {
+	c := &capture.Buffer{Limit: 4}
+	_, _ = c.Write([]byte("request "))
+	_, _ = c.Write([]byte("body"))
+
+	sum := sha256.Sum256([]byte("request body"))
+	assert.Equal(t, golog.Body{
+		Data:        []byte("requ"),
+		Size:        12,
+		ContentType: "text/plain",
+		SHA256:      hex.EncodeToString(sum[:]),
+	}, Body(c, "text/plain"))
+
+	small := &capture.Buffer{Limit: 64}
+	_, _ = small.Write([]byte("body"))
+	assert.Equal(t, golog.Body{Data: []byte("body"), Size: 4}, Body(small, ""))
+	assert.Nil(t, Body(&capture.Buffer{Limit: 64}, ""))
+}
@@ -0,0 +1,50 @@
+// Package tdrtest provides the test logger and TDR reader shared by the
+// middleware packages' tests.
+package tdrtest
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/require"
+	"github.com/tommynurwantoro/golog"
+)
+
+// NewLogger returns a logger writing to a temporary directory, and the path
+// of its TDR file.
+func NewLogger(t testing.TB) (golog.LoggerInterface, string) {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+	logger := golog.NewLogger(golog.Config{
+		App:          "testapp",
+		AppVer:       "1.0.0",
+		Env:          "development",
+		FileLocation: tmpDir,
+		FileMaxSize:  10,
+	})
+	return logger, filepath.Join(tmpDir, "tdr.log")
+}
+
+// Read syncs logger and returns the TDR records written to path.
+func Read(t testing.TB, logger golog.LoggerInterface, path string) []map[string]interface{} {
+	t.Helper()
+	require.NoError(t, logger.Sync())
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+	}
+	require.NoError(t, scanner.Err())
+	return lines
+}
@@ -0,0 +1,161 @@
+package golog
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/sys/unix"
+)
+
+// readJournalRecord receives a record on conn, reading it from the passed
+// memfd if the datagram carries one, and returns its fields.
+func readJournalRecord(t *testing.T, conn *net.UnixConn) (fields map[string]string, memfd bool) {
+	t.Helper()
+	buf := make([]byte, 1024*1024)
+	oob := make([]byte, unix.CmsgSpace(4))
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+	require.NoError(t, err)
+	data := buf[:n]
+
+	if oobn > 0 {
+		msgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+		require.NoError(t, err)
+		require.Len(t, msgs, 1)
+		fds, err := unix.ParseUnixRights(&msgs[0])
+		require.NoError(t, err)
+		require.Len(t, fds, 1)
+		f := os.NewFile(uintptr(fds[0]), "memfd")
+		defer f.Close()
+		seals, err := unix.FcntlInt(f.Fd(), unix.F_GET_SEALS, 0)
+		require.NoError(t, err)
+		assert.NotZero(t, seals&unix.F_SEAL_WRITE, "the memfd is sealed")
+		// the file offset is shared with the sender, which left it at the end
+		data, err = io.ReadAll(io.NewSectionReader(f, 0, 1<<30))
+		require.NoError(t, err)
+		memfd = true
+	}
+	return parseJournalRecord(t, data), memfd
+}
+
+func parseJournalRecord(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	fields := map[string]string{}
+	for len(data) > 0 {
+		line, rest, _ := bytes.Cut(data, []byte("\n"))
+		if key, value, ok := bytes.Cut(line, []byte("=")); ok {
+			fields[string(key)] = string(value)
+			data = rest
+			continue
+		}
+		require.GreaterOrEqual(t, len(rest), 8)
+		size := binary.LittleEndian.Uint64(rest)
+		value := rest[8 : 8+size]
+		require.Equal(t, byte('\n'), rest[8+size])
+		fields[string(line)] = string(value)
+		data = rest[9+size:]
+	}
+	return fields
+}
+
+func listenJournal(t *testing.T) (*net.UnixConn, string) {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "journal.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn, socket
+}
+
+func TestJournaldSink(t *testing.T) {
+	assert.NoError(t, SinkConfig{Name: "journal", Type: SinkJournald}.Validate(), "the address is optional")
+	conn, socket := listenJournal(t)
+
+	logger := NewLogger(Config{
+		App:    "testapp",
+		AppVer: "1.0.0",
+		Env:    "production",
+		Sinks: []SinkConfig{
+			{Name: "journal", Type: SinkJournald, Address: socket},
+			{Name: "journal-console", Type: SinkJournald, Address: socket, Encoding: EncodingConsole,
+				Level: levelPtr(zapcore.ErrorLevel), Journald: &JournaldConfig{Identifier: "console"}},
+		},
+	})
+	defer logger.(*Log).Close()
+
+	logger.Named("payment").WithContext(WithTraceID(context.Background(), "abc123")).Warn("careful")
+	fields, memfd := readJournalRecord(t, conn)
+	assert.False(t, memfd)
+	assert.Equal(t, "4", fields["PRIORITY"])
+	assert.Equal(t, "testapp", fields["SYSLOG_IDENTIFIER"])
+	assert.Equal(t, "testapp", fields["APP"])
+	assert.Equal(t, "1.0.0", fields["APPVER"])
+	assert.Equal(t, "production", fields["ENV"])
+	assert.Equal(t, "abc123", fields["TRACEID"])
+	assert.Equal(t, "payment", fields["COMPONENT"])
+	assert.Equal(t, "system", fields["GOLOG_STREAM"])
+	assert.Contains(t, fields["MESSAGE"], `"message":"careful"`)
+
+	logger.Error("broken\nline", nil)
+	var records []map[string]string
+	for range 2 {
+		fields, _ := readJournalRecord(t, conn)
+		records = append(records, fields)
+	}
+	if records[0]["SYSLOG_IDENTIFIER"] == "console" {
+		records[0], records[1] = records[1], records[0]
+	}
+	assert.Equal(t, "3", records[0]["PRIORITY"])
+	assert.NotContains(t, records[0], "TRACEID")
+	assert.Equal(t, "console", records[1]["SYSLOG_IDENTIFIER"])
+	assert.Contains(t, records[1]["MESSAGE"], "ERROR\tbroken\nline", "multi-line values are length-prefixed")
+}
+
+func TestJournaldSinkMemfd(t *testing.T) {
+	conn, socket := listenJournal(t)
+
+	logger := NewLogger(Config{
+		App:        "testapp",
+		Env:        "production",
+		BodyLimits: &BodyLimits{MaxRequestSize: -1, HashAbove: -1},
+		Sinks: []SinkConfig{
+			{Name: "journal", Stream: StreamTDR, Type: SinkJournald, Address: socket},
+		},
+	})
+	defer logger.(*Log).Close()
+
+	body := strings.Repeat("x", 1024*1024)
+	logger.TDR(LogModel{CorrelationID: "c-1", Request: body})
+	fields, memfd := readJournalRecord(t, conn)
+	assert.True(t, memfd, "a record larger than a datagram is passed in a memfd")
+	assert.Equal(t, "tdr", fields["GOLOG_STREAM"])
+	assert.Contains(t, fields["MESSAGE"], `"correlationId":"c-1"`)
+	assert.True(t, strings.Contains(fields["MESSAGE"], body), "the body is whole")
+}
+
+func TestJournaldWriterRedials(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "journal.sock")
+	w := newJournalWriter(socket)
+	defer w.Close()
+	_, err := w.Write([]byte("MESSAGE=lost\n"))
+	assert.Error(t, err, "journald is not running")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = w.Write([]byte("MESSAGE=found\n"))
+	require.NoError(t, err)
+	fields, _ := readJournalRecord(t, conn)
+	assert.Equal(t, "found", fields["MESSAGE"])
+}
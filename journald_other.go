@@ -0,0 +1,26 @@
+//go:build !linux
+
+package golog
+
+import "errors"
+
+const journalSupported = false
+
+// journalWriter fails every write, as the journal is only on Linux.
+type journalWriter struct{}
+
+func newJournalWriter(string) *journalWriter {
+	return &journalWriter{}
+}
+
+func (w *journalWriter) Write([]byte) (int, error) {
+	return 0, errors.New("golog: journald is only available on Linux")
+}
+
+func (w *journalWriter) Sync() error {
+	return nil
+}
+
+func (w *journalWriter) Close() error {
+	return nil
+}
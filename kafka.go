@@ -0,0 +1,683 @@
+package golog
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// SinkKafka publishes entries to a Kafka topic. See KafkaConfig.
+const SinkKafka = "kafka"
+
+// Acknowledgements for KafkaConfig.Acks.
+const (
+	// KafkaAcksAll waits for every in-sync replica.
+	KafkaAcksAll = "all"
+	// KafkaAcksLeader waits for the partition leader only.
+	KafkaAcksLeader = "1"
+	// KafkaAcksNone does not wait for the broker.
+	KafkaAcksNone = "0"
+)
+
+// SASL mechanisms for KafkaConfig.SASLMechanism.
+const (
+	KafkaSASLPlain       = "PLAIN"
+	KafkaSASLScramSHA256 = "SCRAM-SHA-256"
+	KafkaSASLScramSHA512 = "SCRAM-SHA-512"
+)
+
+// Defaults for KafkaConfig.
+const (
+	DefaultKafkaBatchSize     = 100
+	DefaultKafkaBatchBytes    = 1024 * 1024
+	DefaultKafkaFlushInterval = 100
+	DefaultKafkaQueueSize     = 10000
+	DefaultKafkaMaxRetries    = 3
+	DefaultKafkaMinBackoff    = 100
+	DefaultKafkaMaxBackoff    = 10000
+)
+
+// KafkaConfig configures a SinkKafka sink. SinkConfig.Address is a comma
+// separated list of bootstrap brokers, e.g. "kafka-1:9092,kafka-2:9092".
+//
+// The sink is meant for the TDR stream, alongside tdr.log: every record is
+// published as one message, with the JSON entry as value and its
+// correlationId as key, or its traceId if it has no correlation ID, so the
+// records of a transaction land in the same partition. Partitions are
+// chosen like the default partitioner of the Java client. Messages are
+// published with the franz-go client, which follows the partition leaders
+// and compresses batches with snappy.
+//
+// Entries are queued in memory, up to QueueSize, and published from a
+// background goroutine in batches of BatchSize entries or BatchBytes
+// bytes, at least every FlushInterval. A batch that fails is retried with
+// exponential backoff; when the retries are exhausted, or the queue is
+// full, entries are appended to FallbackFile instead, and further entries
+// go there until the backoff since the last failure has passed. Sync waits
+// until the entries written before it are published or written to the
+// fallback file, and returns the failures seen since the previous Sync.
+type KafkaConfig struct {
+	// Topic the entries are published to.
+	Topic string `json:"topic"`
+
+	// Acknowledgements waited for: KafkaAcksAll (default), KafkaAcksLeader
+	// or KafkaAcksNone.
+	Acks string `json:"acks"`
+
+	// Client ID sent to the brokers. Defaults to Config.App.
+	ClientID string `json:"clientId"`
+
+	// Entries per batch. Defaults to DefaultKafkaBatchSize.
+	BatchSize int `json:"batchSize"`
+
+	// Bytes of entries per batch. Defaults to DefaultKafkaBatchBytes.
+	BatchBytes int `json:"batchBytes"`
+
+	// Milliseconds before a batch that is not full is published. Defaults
+	// to DefaultKafkaFlushInterval.
+	FlushInterval int `json:"flushInterval"`
+
+	// Entries queued in memory. Defaults to DefaultKafkaQueueSize.
+	QueueSize int `json:"queueSize"`
+
+	// Retries of a failed batch. Defaults to DefaultKafkaMaxRetries; a
+	// negative value disables retries.
+	MaxRetries int `json:"maxRetries"`
+
+	// Milliseconds before the first retry, doubled on every retry up to
+	// MaxBackoff. Default to DefaultKafkaMinBackoff and
+	// DefaultKafkaMaxBackoff.
+	MinBackoff int `json:"minBackoff"`
+	MaxBackoff int `json:"maxBackoff"`
+
+	// File the entries that could not be published are appended to, in
+	// the format of tdr.log and rotated with the FileMaxSize, FileMaxBackup
+	// and FileMaxAge of the Config. If empty, such entries are dropped.
+	FallbackFile string `json:"fallbackFile"`
+
+	// Connect to the brokers over TLS.
+	TLS bool `json:"tls"`
+
+	// PEM file of the CA that signed the broker certificates, for TLS. The
+	// system roots are used if empty.
+	CAFile string `json:"caFile"`
+
+	// TLS settings, overriding CAFile. A non-nil TLSConfig implies TLS.
+	TLSConfig *tls.Config `json:"-"`
+
+	// SASL mechanism authenticating the client: KafkaSASLPlain,
+	// KafkaSASLScramSHA256 or KafkaSASLScramSHA512. Empty disables SASL.
+	SASLMechanism string `json:"saslMechanism"`
+	SASLUsername  string `json:"saslUsername"`
+	SASLPassword  string `json:"saslPassword"`
+}
+
+// withDefaults returns c with the defaults of unset fields.
+func (c KafkaConfig) withDefaults() KafkaConfig {
+	if c.Acks == "" {
+		c.Acks = KafkaAcksAll
+	}
+	if c.BatchSize == 0 {
+		c.BatchSize = DefaultKafkaBatchSize
+	}
+	if c.BatchBytes == 0 {
+		c.BatchBytes = DefaultKafkaBatchBytes
+	}
+	if c.FlushInterval == 0 {
+		c.FlushInterval = DefaultKafkaFlushInterval
+	}
+	if c.QueueSize == 0 {
+		c.QueueSize = DefaultKafkaQueueSize
+	}
+	if c.MaxRetries == 0 {
+		c.MaxRetries = DefaultKafkaMaxRetries
+	}
+	if c.MinBackoff == 0 {
+		c.MinBackoff = DefaultKafkaMinBackoff
+	}
+	if c.MaxBackoff == 0 {
+		c.MaxBackoff = DefaultKafkaMaxBackoff
+	}
+	if c.MaxBackoff < c.MinBackoff {
+		c.MaxBackoff = c.MinBackoff
+	}
+	return c
+}
+
+// clientOptions returns the options of the franz-go client publishing to
+// brokers.
+func (c KafkaConfig) clientOptions(brokers []string) ([]kgo.Opt, error) {
+	acks := kgo.AllISRAcks()
+	switch c.Acks {
+	case KafkaAcksLeader:
+		acks = kgo.LeaderAck()
+	case KafkaAcksNone:
+		acks = kgo.NoAck()
+	}
+	tries := c.MaxRetries + 1
+	if c.MaxRetries < 0 {
+		tries = 1
+	}
+	minBackoff := time.Duration(c.MinBackoff) * time.Millisecond
+	maxBackoff := time.Duration(c.MaxBackoff) * time.Millisecond
+
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(brokers...),
+		kgo.ClientID(c.ClientID),
+		kgo.DefaultProduceTopic(c.Topic),
+		kgo.RequiredAcks(acks),
+		// idempotent writes require acks=all and the IDEMPOTENT_WRITE ACL;
+		// without them a retried batch may rarely be published twice
+		kgo.DisableIdempotentWrite(),
+		kgo.RecordPartitioner(kgo.StickyKeyPartitioner(nil)),
+		kgo.RecordRetries(tries),
+		// leaders are looked up again after the backoff of a failed batch,
+		// rather than franz-go's five seconds, within its 10ms floor
+		kgo.MetadataMinAge(max(minBackoff, 10*time.Millisecond)),
+		kgo.RetryBackoffFn(func(attempt int) time.Duration {
+			backoff := minBackoff
+			for i := 1; i < attempt && backoff < maxBackoff; i++ {
+				backoff *= 2
+			}
+			return min(backoff, maxBackoff)
+		}),
+	}
+	if c.TLS || c.TLSConfig != nil {
+		config, err := kafkaTLSConfig(c)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, kgo.DialTLSConfig(config))
+	}
+	if mechanism := c.saslMechanism(); mechanism != nil {
+		opts = append(opts, kgo.SASL(mechanism))
+	}
+	return opts, nil
+}
+
+// kafkaTLSConfig returns the TLS settings of c. The client sets the server
+// name of each broker it dials.
+func kafkaTLSConfig(c KafkaConfig) (*tls.Config, error) {
+	if c.TLSConfig != nil {
+		return c.TLSConfig.Clone(), nil
+	}
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("golog: no certificate in %s", c.CAFile)
+		}
+	}
+	return config, nil
+}
+
+// saslMechanism returns the SASL mechanism of c, or nil if it has none.
+func (c KafkaConfig) saslMechanism() sasl.Mechanism {
+	switch c.SASLMechanism {
+	case KafkaSASLPlain:
+		return plain.Auth{User: c.SASLUsername, Pass: c.SASLPassword}.AsMechanism()
+	case KafkaSASLScramSHA256:
+		return scram.Auth{User: c.SASLUsername, Pass: c.SASLPassword}.AsSha256Mechanism()
+	case KafkaSASLScramSHA512:
+		return scram.Auth{User: c.SASLUsername, Pass: c.SASLPassword}.AsSha512Mechanism()
+	default:
+		return nil
+	}
+}
+
+func validateKafkaSink(s SinkConfig) error {
+	var c KafkaConfig
+	if s.Kafka != nil {
+		c = *s.Kafka
+	}
+
+	var errs []error
+	if c.Topic == "" {
+		errs = append(errs, fmt.Errorf("golog: sink %q: kafka sink without a topic", s.Name))
+	}
+	switch c.Acks {
+	case "", KafkaAcksAll, KafkaAcksLeader, KafkaAcksNone:
+	default:
+		errs = append(errs, fmt.Errorf("golog: sink %q: unknown kafka acks %q", s.Name, c.Acks))
+	}
+	switch c.SASLMechanism {
+	case "":
+	case KafkaSASLPlain, KafkaSASLScramSHA256, KafkaSASLScramSHA512:
+		if c.SASLUsername == "" {
+			errs = append(errs, fmt.Errorf("golog: sink %q: kafka sasl without a username", s.Name))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("golog: sink %q: unknown kafka sasl mechanism %q", s.Name, c.SASLMechanism))
+	}
+	if c.CAFile != "" && c.TLSConfig == nil {
+		if _, err := os.Stat(c.CAFile); err != nil {
+			errs = append(errs, fmt.Errorf("golog: sink %q: %w", s.Name, err))
+		}
+	}
+	for name, value := range map[string]int{
+		"batchSize": c.BatchSize, "batchBytes": c.BatchBytes, "flushInterval": c.FlushInterval,
+		"queueSize": c.QueueSize, "minBackoff": c.MinBackoff, "maxBackoff": c.MaxBackoff,
+	} {
+		if value < 0 {
+			errs = append(errs, fmt.Errorf("golog: sink %q: negative %s %d", s.Name, name, value))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func openKafkaSink(s SinkConfig, conf Config) sinkOutput {
+	var c KafkaConfig
+	if s.Kafka != nil {
+		c = *s.Kafka
+	}
+	c = c.withDefaults()
+	if c.ClientID == "" {
+		c.ClientID = conf.App
+	}
+
+	var fallback *lumberjack.Logger
+	if c.FallbackFile != "" {
+		fallback = &lumberjack.Logger{
+			Filename:   c.FallbackFile,
+			MaxSize:    conf.FileMaxSize, // megabytes
+			MaxBackups: conf.FileMaxBackup,
+			MaxAge:     conf.FileMaxAge, // days
+		}
+	}
+	client := newKafkaClient(splitList(s.Address), c)
+	w := newKafkaWriter(client, c, fallback)
+	return sinkOutput{
+		writer: w,
+		encoder: func(encoderConfig zapcore.EncoderConfig) zapcore.Encoder {
+			return &kafkaEncoder{fieldsEncoder: fieldsEncoder{Encoder: s.encoder(encoderConfig)}}
+		},
+		closer: w,
+	}
+}
+
+var kafkaPool = buffer.NewPool()
+
+// kafkaEncoder prefixes each entry with its time in Unix milliseconds, the
+// length of its key and the key, separated by spaces.
+type kafkaEncoder struct {
+	fieldsEncoder
+}
+
+func (e *kafkaEncoder) Clone() zapcore.Encoder {
+	return &kafkaEncoder{fieldsEncoder: e.clone()}
+}
+
+func (e *kafkaEncoder) EncodeEntry(entry zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	msg, err := e.Encoder.EncodeEntry(entry, fields)
+	if err != nil {
+		return nil, err
+	}
+	defer msg.Free()
+
+	key := e.lookup("correlationId", fields)
+	if key == "" {
+		key = e.lookup(TraceIDKey.String(), fields)
+	}
+	buf := kafkaPool.Get()
+	buf.AppendInt(entry.Time.UnixMilli())
+	buf.AppendByte(' ')
+	buf.AppendInt(int64(len(key)))
+	buf.AppendByte(' ')
+	buf.AppendString(key)
+	buf.AppendString(strings.TrimRight(msg.String(), "\n"))
+	return buf, nil
+}
+
+// parseKafkaEntry splits an entry written by a kafkaEncoder.
+func parseKafkaEntry(p []byte) (kafkaMessage, error) {
+	ts, rest, ok1 := bytes.Cut(p, []byte{' '})
+	size, rest, ok2 := bytes.Cut(rest, []byte{' '})
+	timestamp, err1 := strconv.ParseInt(string(ts), 10, 64)
+	n, err2 := strconv.Atoi(string(size))
+	if !ok1 || !ok2 || err1 != nil || err2 != nil || n < 0 || n > len(rest) {
+		return kafkaMessage{}, errors.New("golog: malformed kafka sink entry")
+	}
+	m := kafkaMessage{timestamp: timestamp, value: append([]byte(nil), rest[n:]...)}
+	if n > 0 {
+		m.key = append([]byte(nil), rest[:n]...)
+	}
+	return m, nil
+}
+
+// kafkaMessage is a record to publish.
+type kafkaMessage struct {
+	// timestamp in Unix milliseconds
+	timestamp int64
+	key       []byte
+	value     []byte
+}
+
+// kafkaClient publishes messages with a franz-go client.
+type kafkaClient struct {
+	client *kgo.Client
+	// err is the failure to create the client, returned by every produce
+	err error
+}
+
+func newKafkaClient(brokers []string, conf KafkaConfig) *kafkaClient {
+	opts, err := conf.clientOptions(brokers)
+	if err != nil {
+		return &kafkaClient{err: fmt.Errorf("golog: kafka client: %w", err)}
+	}
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		return &kafkaClient{err: fmt.Errorf("golog: kafka client: %w", err)}
+	}
+	return &kafkaClient{client: client}
+}
+
+// produce publishes messages, waiting for their acknowledgements. It
+// returns the messages that could not be published, in order, and the
+// first error.
+func (c *kafkaClient) produce(ctx context.Context, messages []kafkaMessage) (failed []kafkaMessage, err error) {
+	if c.err != nil {
+		return messages, c.err
+	}
+	records := make([]*kgo.Record, len(messages))
+	index := make(map[*kgo.Record]int, len(messages))
+	for i, m := range messages {
+		records[i] = &kgo.Record{Key: m.key, Value: m.value, Timestamp: time.UnixMilli(m.timestamp)}
+		index[records[i]] = i
+	}
+	failures := make([]bool, len(messages))
+	for _, result := range c.client.ProduceSync(ctx, records...) {
+		if result.Err != nil {
+			failures[index[result.Record]] = true
+			if err == nil {
+				err = fmt.Errorf("golog: kafka produce: %w", result.Err)
+			}
+		}
+	}
+	for i, m := range messages {
+		if failures[i] {
+			failed = append(failed, m)
+		}
+	}
+	return failed, err
+}
+
+func (c *kafkaClient) close() error {
+	if c.client != nil {
+		c.client.Close()
+	}
+	return nil
+}
+
+// kafkaWriter queues entries, published by a background goroutine.
+type kafkaWriter struct {
+	client   *kafkaClient
+	conf     KafkaConfig
+	fallback *lumberjack.Logger
+
+	mu    sync.Mutex
+	queue []kafkaMessage
+	bytes int
+	// queued and handled count entries, so Sync can wait for the entries
+	// queued before it was called
+	queued      uint64
+	handled     uint64
+	handledCond *sync.Cond
+	flushAt     time.Time
+	err         error
+	closed      bool
+
+	// kick asks for the full batches to be published, flush for every
+	// queued entry
+	kick  chan struct{}
+	flush chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+	// ctx is cancelled on Close, so that the retries of a batch being
+	// published are not waited for
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// state of the publishing goroutine
+	backoff   time.Duration
+	nextRetry time.Time
+}
+
+func newKafkaWriter(client *kafkaClient, conf KafkaConfig, fallback *lumberjack.Logger) *kafkaWriter {
+	w := &kafkaWriter{
+		client:   client,
+		conf:     conf,
+		fallback: fallback,
+		kick:     make(chan struct{}, 1),
+		flush:    make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		backoff:  time.Duration(conf.MinBackoff) * time.Millisecond,
+	}
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+	w.handledCond = sync.NewCond(&w.mu)
+	go w.run()
+	return w
+}
+
+// Write queues an entry, or writes it to the fallback file if the queue is
+// full.
+func (w *kafkaWriter) Write(p []byte) (int, error) {
+	m, err := parseKafkaEntry(p)
+	if err != nil {
+		return 0, err
+	}
+
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return 0, errors.New("golog: write to closed kafka sink")
+	}
+	if len(w.queue) >= w.conf.QueueSize {
+		w.mu.Unlock()
+		if err := w.writeFallback([]kafkaMessage{m}); err != nil {
+			return 0, fmt.Errorf("golog: kafka sink queue full: %w", err)
+		}
+		return len(p), nil
+	}
+	if len(w.queue) == 0 {
+		w.flushAt = time.Now().Add(time.Duration(w.conf.FlushInterval) * time.Millisecond)
+	}
+	w.queue = append(w.queue, m)
+	w.bytes += len(m.key) + len(m.value)
+	w.queued++
+	full := len(w.queue) >= w.conf.BatchSize || w.bytes >= w.conf.BatchBytes
+	w.mu.Unlock()
+
+	if full {
+		w.signal()
+	}
+	return len(p), nil
+}
+
+func (w *kafkaWriter) signal() {
+	select {
+	case w.kick <- struct{}{}:
+	default:
+	}
+}
+
+// run publishes the queue until the writer is closed.
+func (w *kafkaWriter) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(time.Duration(w.conf.FlushInterval) * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		var flush bool
+		select {
+		case <-w.kick:
+		case <-w.flush:
+			flush = true
+		case <-ticker.C:
+			w.mu.Lock()
+			flush = len(w.queue) > 0 && !time.Now().Before(w.flushAt)
+			w.mu.Unlock()
+		case <-w.stop:
+			// the entries left are published even if the brokers are
+			// backing off
+			w.nextRetry = time.Time{}
+			ctx, cancel := context.WithTimeout(context.Background(), kafkaCloseTimeout)
+			w.publish(ctx, true)
+			cancel()
+			return
+		}
+		w.publish(w.ctx, flush)
+	}
+}
+
+// kafkaCloseTimeout bounds the publication of the entries left on Close.
+const kafkaCloseTimeout = 5 * time.Second
+
+// publish sends the full batches of the queue, and the partial one too if
+// all is set.
+func (w *kafkaWriter) publish(ctx context.Context, all bool) {
+	for {
+		w.mu.Lock()
+		n, size := 0, 0
+		for n < len(w.queue) && n < w.conf.BatchSize && size < w.conf.BatchBytes {
+			size += len(w.queue[n].key) + len(w.queue[n].value)
+			n++
+		}
+		full := n == w.conf.BatchSize || size >= w.conf.BatchBytes
+		if n == 0 || (!full && !all) {
+			w.mu.Unlock()
+			return
+		}
+		batch := w.queue[:n:n]
+		w.queue = w.queue[n:]
+		w.bytes -= size
+		if len(w.queue) > 0 {
+			w.flushAt = time.Now().Add(time.Duration(w.conf.FlushInterval) * time.Millisecond)
+		}
+		w.mu.Unlock()
+
+		err := w.deliver(ctx, batch)
+
+		w.mu.Lock()
+		w.handled += uint64(n)
+		if err != nil {
+			w.err = errors.Join(w.err, err)
+		}
+		w.handledCond.Broadcast()
+		w.mu.Unlock()
+	}
+}
+
+// deliver publishes batch, which the client retries with backoff, and
+// writes what cannot be published to the fallback file. While the brokers
+// are backing off, batch goes to the fallback file directly.
+func (w *kafkaWriter) deliver(ctx context.Context, batch []kafkaMessage) error {
+	if time.Now().Before(w.nextRetry) {
+		return w.writeFallback(batch)
+	}
+
+	failed, err := w.client.produce(ctx, batch)
+	if len(failed) == 0 {
+		w.backoff = time.Duration(w.conf.MinBackoff) * time.Millisecond
+		return nil
+	}
+	// a message rejected for itself, e.g. as too large, says nothing of
+	// the brokers
+	var kafkaErr *kerr.Error
+	if !errors.As(err, &kafkaErr) || kafkaErr.Retriable {
+		w.nextRetry = time.Now().Add(w.backoff)
+		w.backoff = min(w.backoff*2, time.Duration(w.conf.MaxBackoff)*time.Millisecond)
+	}
+	return errors.Join(err, w.writeFallback(failed))
+}
+
+// writeFallback appends the values of messages to the fallback file.
+func (w *kafkaWriter) writeFallback(messages []kafkaMessage) error {
+	if w.fallback == nil {
+		return fmt.Errorf("golog: kafka sink dropped %d entries", len(messages))
+	}
+	var b bytes.Buffer
+	for _, m := range messages {
+		b.Write(m.value)
+		b.WriteByte('\n')
+	}
+	if _, err := w.fallback.Write(b.Bytes()); err != nil {
+		return fmt.Errorf("golog: kafka sink fallback: %w", err)
+	}
+	return nil
+}
+
+// Sync publishes the queued entries and waits until every entry queued
+// before the call is published or written to the fallback file. It returns
+// the failures seen since the previous Sync.
+func (w *kafkaWriter) Sync() error {
+	select {
+	case w.flush <- struct{}{}:
+	default:
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	target := w.queued
+	for w.handled < target && !w.closed {
+		w.handledCond.Wait()
+	}
+	err := w.err
+	w.err = nil
+	return err
+}
+
+// Close publishes the queued entries, waiting for the brokers up to
+// kafkaCloseTimeout and writing what cannot be published to the fallback
+// file, and stops the background goroutine.
+func (w *kafkaWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.handledCond.Broadcast()
+	w.mu.Unlock()
+
+	w.cancel()
+	close(w.stop)
+	<-w.done
+
+	w.mu.Lock()
+	err := w.err
+	w.err = nil
+	w.mu.Unlock()
+	errs := []error{err, w.client.close()}
+	if w.fallback != nil {
+		errs = append(errs, w.fallback.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// writesEntries keeps async writes from merging entries, which are each
+// published as one message.
+func (w *kafkaWriter) writesEntries() bool {
+	return true
+}
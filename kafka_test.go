@@ -0,0 +1,637 @@
+package golog
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/klauspost/compress/s2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// fakeKafka is an in-process broker leading every partition of its topics.
+// It answers the ApiVersions, Metadata and Produce requests of the sink,
+// and authenticates it with SASL PLAIN if users is set.
+type fakeKafka struct {
+	ln         net.Listener
+	partitions int32
+	// users maps the user names to their passwords
+	users map[string]string
+
+	mu       sync.Mutex
+	messages map[int32][]kafkaMessage
+	produces []fakeProduce
+	metadata int
+	// produceErrors are returned, one per produce request, before any
+	// message is accepted
+	produceErrors []int16
+}
+
+type fakeProduce struct {
+	clientID string
+	acks     int16
+	messages int
+}
+
+func newFakeKafka(t *testing.T, partitions int32) *fakeKafka {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	return serveFakeKafka(t, ln, partitions, nil)
+}
+
+// serveFakeKafka serves a fakeKafka on ln.
+func serveFakeKafka(t *testing.T, ln net.Listener, partitions int32, users map[string]string) *fakeKafka {
+	k := &fakeKafka{ln: ln, partitions: partitions, users: users, messages: map[int32][]kafkaMessage{}}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go k.serve(conn)
+		}
+	}()
+	return k
+}
+
+func (k *fakeKafka) addr() string {
+	return k.ln.Addr().String()
+}
+
+func (k *fakeKafka) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authenticated := k.users == nil
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return
+		}
+		req := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(r, req); err != nil || len(req) < 10 {
+			return
+		}
+		key := int16(binary.BigEndian.Uint16(req))
+		version := int16(binary.BigEndian.Uint16(req[2:]))
+		correlationID := binary.BigEndian.Uint32(req[4:])
+		body := req[10:]
+		var clientID string
+		if n := int16(binary.BigEndian.Uint16(req[8:])); n > 0 && int(n) <= len(body) {
+			clientID, body = string(body[:n]), body[n:]
+		}
+
+		kreq := kmsg.RequestForKey(key)
+		if kreq == nil {
+			return
+		}
+		kreq.SetVersion(version)
+		if kreq.IsFlexible() {
+			// the sink sends no tagged fields in request headers
+			if len(body) == 0 || body[0] != 0 {
+				return
+			}
+			body = body[1:]
+		}
+		if err := kreq.ReadFrom(body); err != nil {
+			return
+		}
+
+		var resp kmsg.Response
+		switch kreq := kreq.(type) {
+		case *kmsg.ApiVersionsRequest:
+			resp = k.apiVersionsResponse(kreq)
+		case *kmsg.SASLHandshakeRequest:
+			resp = kreq.ResponseKind()
+			if kreq.Mechanism != KafkaSASLPlain {
+				resp.(*kmsg.SASLHandshakeResponse).ErrorCode = 33 // UNSUPPORTED_SASL_MECHANISM
+			}
+		case *kmsg.SASLAuthenticateRequest:
+			resp = kreq.ResponseKind()
+			// PLAIN sends "authzid NUL user NUL password"
+			parts := strings.Split(string(kreq.SASLAuthBytes), "\x00")
+			if len(parts) == 3 && k.users[parts[1]] == parts[2] && parts[2] != "" {
+				authenticated = true
+			} else {
+				resp.(*kmsg.SASLAuthenticateResponse).ErrorCode = 58 // SASL_AUTHENTICATION_FAILED
+			}
+		case *kmsg.MetadataRequest:
+			if !authenticated {
+				return
+			}
+			resp = k.metadataResponse(kreq)
+		case *kmsg.ProduceRequest:
+			if !authenticated {
+				return
+			}
+			resp = k.produceResponse(kreq, clientID)
+			if kreq.Acks == 0 {
+				continue
+			}
+		default:
+			return
+		}
+
+		out := binary.BigEndian.AppendUint32(make([]byte, 4, 64), correlationID)
+		if resp.IsFlexible() && key != 18 { // ApiVersions keeps header v0
+			out = append(out, 0)
+		}
+		out = resp.AppendTo(out)
+		binary.BigEndian.PutUint32(out, uint32(len(out)-4))
+		if _, err := conn.Write(out); err != nil {
+			return
+		}
+	}
+}
+
+func (k *fakeKafka) apiVersionsResponse(req *kmsg.ApiVersionsRequest) kmsg.Response {
+	resp := req.ResponseKind().(*kmsg.ApiVersionsResponse)
+	resp.ApiKeys = []kmsg.ApiVersionsResponseApiKey{
+		{ApiKey: 0, MinVersion: 3, MaxVersion: 8},  // Produce
+		{ApiKey: 3, MinVersion: 4, MaxVersion: 8},  // Metadata
+		{ApiKey: 18, MinVersion: 0, MaxVersion: 3}, // ApiVersions
+	}
+	if k.users != nil {
+		resp.ApiKeys = append(resp.ApiKeys,
+			kmsg.ApiVersionsResponseApiKey{ApiKey: 17, MinVersion: 1, MaxVersion: 1}, // SaslHandshake
+			kmsg.ApiVersionsResponseApiKey{ApiKey: 36, MinVersion: 0, MaxVersion: 1}, // SaslAuthenticate
+		)
+	}
+	return resp
+}
+
+func (k *fakeKafka) metadataResponse(req *kmsg.MetadataRequest) kmsg.Response {
+	k.mu.Lock()
+	k.metadata++
+	k.mu.Unlock()
+
+	host, port, _ := net.SplitHostPort(k.addr())
+	portNum, _ := strconv.Atoi(port)
+	resp := req.ResponseKind().(*kmsg.MetadataResponse)
+	resp.Brokers = []kmsg.MetadataResponseBroker{{NodeID: 0, Host: host, Port: int32(portNum)}}
+	for _, topic := range req.Topics {
+		t := kmsg.MetadataResponseTopic{Topic: topic.Topic}
+		for p := int32(0); p < k.partitions; p++ {
+			t.Partitions = append(t.Partitions, kmsg.MetadataResponseTopicPartition{
+				Partition: p, Leader: 0, Replicas: []int32{0}, ISR: []int32{0},
+			})
+		}
+		resp.Topics = append(resp.Topics, t)
+	}
+	return resp
+}
+
+// produceResponse stores the messages of a produce request.
+func (k *fakeKafka) produceResponse(req *kmsg.ProduceRequest, clientID string) kmsg.Response {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	var code int16
+	if len(k.produceErrors) > 0 {
+		code, k.produceErrors = k.produceErrors[0], k.produceErrors[1:]
+	}
+
+	produce := fakeProduce{clientID: clientID, acks: req.Acks}
+	resp := req.ResponseKind().(*kmsg.ProduceResponse)
+	for _, topic := range req.Topics {
+		rt := kmsg.ProduceResponseTopic{Topic: topic.Topic}
+		for _, partition := range topic.Partitions {
+			rt.Partitions = append(rt.Partitions, kmsg.ProduceResponseTopicPartition{
+				Partition: partition.Partition, ErrorCode: code, LogStartOffset: -1,
+			})
+			if code != 0 {
+				continue
+			}
+			messages := decodeRecordBatch(partition.Records)
+			k.messages[partition.Partition] = append(k.messages[partition.Partition], messages...)
+			produce.messages += len(messages)
+		}
+		resp.Topics = append(resp.Topics, rt)
+	}
+	k.produces = append(k.produces, produce)
+	return resp
+}
+
+// decodeRecordBatch decodes a record batch, uncompressed or compressed with
+// snappy. It returns nil for a malformed batch.
+func decodeRecordBatch(raw []byte) []kafkaMessage {
+	var batch kmsg.RecordBatch
+	if err := batch.ReadFrom(raw); err != nil {
+		return nil
+	}
+	records := batch.Records
+	switch batch.Attributes & 7 {
+	case 0:
+	case 2:
+		var err error
+		if records, err = s2.Decode(nil, records); err != nil {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	var messages []kafkaMessage
+	for i := int32(0); i < batch.NumRecords; i++ {
+		var record kmsg.Record
+		if err := record.ReadFrom(records); err != nil {
+			return nil
+		}
+		// skip the record and its varint length
+		_, n := binary.Varint(records)
+		if n <= 0 || n+int(record.Length) > len(records) {
+			return nil
+		}
+		records = records[n+int(record.Length):]
+		messages = append(messages, kafkaMessage{
+			timestamp: batch.FirstTimestamp + record.TimestampDelta64,
+			key:       record.Key,
+			value:     record.Value,
+		})
+	}
+	return messages
+}
+
+// fakeMessage is a message received by a fakeKafka.
+type fakeMessage struct {
+	kafkaMessage
+	partition int32
+}
+
+// received returns the messages received, with their partition.
+func (k *fakeKafka) received() []fakeMessage {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	var messages []fakeMessage
+	for p, ms := range k.messages {
+		for _, m := range ms {
+			messages = append(messages, fakeMessage{kafkaMessage: m, partition: p})
+		}
+	}
+	return messages
+}
+
+func (k *fakeKafka) count() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	var n int
+	for _, messages := range k.messages {
+		n += len(messages)
+	}
+	return n
+}
+
+func newKafkaSinkLogger(t *testing.T, address string, conf *KafkaConfig) (*Log, string) {
+	t.Helper()
+	tmpDir := t.TempDir()
+	logger := NewLogger(Config{
+		App:          "testapp",
+		Env:          "production",
+		FileLocation: tmpDir,
+		FileMaxSize:  10,
+		Sinks: []SinkConfig{
+			{Name: "kafka", Stream: StreamTDR, Type: SinkKafka, Address: address, Kafka: conf},
+		},
+	}).(*Log)
+	t.Cleanup(func() { _ = logger.Close() })
+	return logger, tmpDir
+}
+
+func TestKafkaSinkPublishesTDR(t *testing.T) {
+	broker := newFakeKafka(t, 3)
+	logger, tmpDir := newKafkaSinkLogger(t, "127.0.0.1:1,"+broker.addr(), &KafkaConfig{Topic: "tdr"})
+
+	logger.TDR(LogModel{CorrelationID: "c-1", Path: "/one"})
+	logger.TDR(LogModel{CorrelationID: "c-2", Path: "/two"})
+	logger.TDR(LogModel{CorrelationID: "c-1", Path: "/three"})
+	logger.WithContext(WithTraceID(context.Background(), "t-1")).TDR(LogModel{Path: "/four"})
+	require.NoError(t, logger.Sync())
+
+	messages := broker.received()
+	require.Len(t, messages, 4)
+	for _, m := range messages {
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal(m.value, &entry))
+		key := entry["correlationId"].(string)
+		if key == "" {
+			key = entry["traceId"].(string)
+		}
+		assert.Equal(t, key, string(m.key))
+		assert.Equal(t, javaPartition(m.key, 3), m.partition, "partitioned by key")
+		assert.InDelta(t, time.Now().UnixMilli(), m.timestamp, 60000)
+	}
+	assert.Equal(t, []string{"c-1", "c-2", "c-1", "t-1"}, keysByPath(t, messages, "/one", "/two", "/three", "/four"))
+
+	broker.mu.Lock()
+	assert.Equal(t, "testapp", broker.produces[0].clientID)
+	assert.Equal(t, int16(-1), broker.produces[0].acks)
+	broker.mu.Unlock()
+
+	assert.Len(t, readLogLines(t, filepath.Join(tmpDir, "tdr.log")), 4, "tdr.log is still written")
+}
+
+// keysByPath returns the keys of the messages with the given paths.
+func keysByPath(t *testing.T, messages []fakeMessage, paths ...string) []string {
+	t.Helper()
+	byPath := map[string]string{}
+	for _, m := range messages {
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal(m.value, &entry))
+		byPath[fmt.Sprint(entry["path"])] = string(m.key)
+	}
+	keys := make([]string, len(paths))
+	for i, path := range paths {
+		keys[i] = byPath[path]
+	}
+	return keys
+}
+
+func TestKafkaSinkBatchesAndAcks(t *testing.T) {
+	broker := newFakeKafka(t, 1)
+	logger, _ := newKafkaSinkLogger(t, broker.addr(), &KafkaConfig{
+		Topic:         "tdr",
+		Acks:          KafkaAcksLeader,
+		ClientID:      "analytics",
+		BatchSize:     2,
+		FlushInterval: 60000,
+	})
+
+	for i := 0; i < 5; i++ {
+		logger.TDR(LogModel{CorrelationID: strconv.Itoa(i)})
+	}
+	require.NoError(t, logger.Sync())
+
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	require.Len(t, broker.produces, 3, "batches of two, then the rest on Sync")
+	assert.Equal(t, 2, broker.produces[0].messages)
+	assert.Equal(t, 1, broker.produces[2].messages)
+	assert.Equal(t, int16(1), broker.produces[0].acks)
+	assert.Equal(t, "analytics", broker.produces[0].clientID)
+	for i, m := range broker.messages[0] {
+		assert.Equal(t, strconv.Itoa(i), string(m.key), "in order")
+	}
+}
+
+func TestKafkaSinkWithoutAcks(t *testing.T) {
+	broker := newFakeKafka(t, 2)
+	logger, _ := newKafkaSinkLogger(t, broker.addr(), &KafkaConfig{Topic: "tdr", Acks: KafkaAcksNone})
+
+	logger.TDR(LogModel{CorrelationID: "c-1"})
+	logger.TDR(LogModel{CorrelationID: "c-2"})
+	require.NoError(t, logger.Sync())
+	assert.Eventually(t, func() bool { return broker.count() == 2 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestKafkaSinkRetriesAfterLeaderChange(t *testing.T) {
+	broker := newFakeKafka(t, 2)
+	broker.produceErrors = []int16{6} // NOT_LEADER_OR_FOLLOWER
+	logger, _ := newKafkaSinkLogger(t, broker.addr(), &KafkaConfig{Topic: "tdr", MinBackoff: 1})
+
+	logger.TDR(LogModel{CorrelationID: "c-1"})
+	require.NoError(t, logger.Sync())
+	assert.Equal(t, 1, broker.count())
+	broker.mu.Lock()
+	assert.GreaterOrEqual(t, broker.metadata, 2, "the leaders are looked up again")
+	broker.mu.Unlock()
+}
+
+func TestKafkaSinkFallbackFile(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	down := ln.Addr().String()
+	ln.Close()
+
+	fallback := filepath.Join(t.TempDir(), "kafka-fallback.log")
+	logger, _ := newKafkaSinkLogger(t, down, &KafkaConfig{
+		Topic:        "tdr",
+		MaxRetries:   1,
+		MinBackoff:   1,
+		FallbackFile: fallback,
+	})
+
+	logger.TDR(LogModel{CorrelationID: "c-1"})
+	logger.TDR(LogModel{CorrelationID: "c-2"})
+	assert.ErrorContains(t, logger.Sync(), "kafka produce")
+
+	lines := readLogLines(t, fallback)
+	require.Len(t, lines, 2)
+	assert.Equal(t, "c-1", lines[0]["correlationId"])
+	assert.Equal(t, "c-2", lines[1]["correlationId"])
+}
+
+func TestKafkaSinkDropsWithoutFallbackFile(t *testing.T) {
+	broker := newFakeKafka(t, 1)
+	broker.produceErrors = []int16{10} // MESSAGE_TOO_LARGE
+	logger, _ := newKafkaSinkLogger(t, broker.addr(), &KafkaConfig{Topic: "tdr"})
+
+	logger.TDR(LogModel{CorrelationID: "c-1"})
+	err := logger.Sync()
+	assert.ErrorContains(t, err, "MESSAGE_TOO_LARGE")
+	assert.ErrorContains(t, err, "dropped 1 entries")
+	assert.Zero(t, broker.count())
+}
+
+func TestKafkaSinkTLSAndSASL(t *testing.T) {
+	dir := t.TempDir()
+	cert := selfSignedCert(t, dir)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	require.NoError(t, err)
+	broker := serveFakeKafka(t, ln, 1, map[string]string{"golog": "secret"})
+
+	logger, _ := newKafkaSinkLogger(t, broker.addr(), &KafkaConfig{
+		Topic:         "tdr",
+		TLS:           true,
+		CAFile:        filepath.Join(dir, "ca.pem"),
+		SASLMechanism: KafkaSASLPlain,
+		SASLUsername:  "golog",
+		SASLPassword:  "secret",
+	})
+	logger.TDR(LogModel{CorrelationID: "c-1"})
+	require.NoError(t, logger.Sync())
+	assert.Equal(t, 1, broker.count())
+
+	logger, _ = newKafkaSinkLogger(t, broker.addr(), &KafkaConfig{
+		Topic:         "tdr",
+		TLS:           true,
+		CAFile:        filepath.Join(dir, "ca.pem"),
+		SASLMechanism: KafkaSASLPlain,
+		SASLUsername:  "golog",
+		SASLPassword:  "wrong",
+		MaxRetries:    -1,
+	})
+	logger.TDR(LogModel{CorrelationID: "c-2"})
+	assert.ErrorContains(t, logger.Sync(), "SASL_AUTHENTICATION_FAILED")
+	assert.Equal(t, 1, broker.count())
+}
+
+func TestKafkaSinkQueueFull(t *testing.T) {
+	broker := newFakeKafka(t, 1)
+	fallback := filepath.Join(t.TempDir(), "kafka-fallback.log")
+	conf := KafkaConfig{Topic: "tdr", FallbackFile: fallback, QueueSize: 2}.withDefaults()
+	w := newKafkaWriter(newKafkaClient([]string{broker.addr()}, conf), conf, &lumberjack.Logger{Filename: fallback})
+	defer w.Close()
+
+	// the publishing goroutine waits for the flush interval
+	for i := 0; i < 3; i++ {
+		_, err := w.Write([]byte("0 2 c" + strconv.Itoa(i) + `{"n":` + strconv.Itoa(i) + "}"))
+		require.NoError(t, err)
+	}
+	lines := readLogLines(t, fallback)
+	require.Len(t, lines, 1, "the entry beyond the queue goes to the fallback file")
+	assert.Equal(t, float64(2), lines[0]["n"])
+
+	require.NoError(t, w.Sync())
+	assert.Equal(t, 2, broker.count())
+}
+
+// javaPartition returns the partition of key among n partitions chosen by
+// the default partitioner of the Java client.
+func javaPartition(key []byte, n int32) int32 {
+	return (murmur2(key) & 0x7fffffff) % n
+}
+
+func murmur2(data []byte) int32 {
+	const (
+		seed = 0x9747b28c
+		m    = 0x5bd1e995
+		r    = 24
+	)
+	length := len(data)
+	h := uint32(seed) ^ uint32(length)
+	for i := 0; i+4 <= length; i += 4 {
+		k := binary.LittleEndian.Uint32(data[i:])
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+	tail := data[length&^3:]
+	switch len(tail) {
+	case 3:
+		h ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(tail[0])
+		h *= m
+	}
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return int32(h)
+}
+
+func TestMurmur2(t *testing.T) {
+	// values of the Java client, from org.apache.kafka.common.utils.UtilsTest
+	for data, want := range map[string]int32{
+		"21":                         -973932308,
+		"foobar":                     -790332482,
+		"a-little-bit-long-string":   -985981536,
+		"a-little-bit-longer-string": -1486304829,
+		"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8": -58897971,
+		"abc": 479470107,
+	} {
+		assert.Equal(t, want, murmur2([]byte(data)), data)
+	}
+}
+
+// TestKafkaSinkBroker publishes to the brokers of GOLOG_KAFKA_BROKERS, a
+// comma separated list, in the topic of GOLOG_KAFKA_TOPIC ("golog-test" by
+// default), which must exist. GOLOG_KAFKA_TLS=1 enables TLS, and
+// GOLOG_KAFKA_SASL_MECHANISM, GOLOG_KAFKA_SASL_USERNAME and
+// GOLOG_KAFKA_SASL_PASSWORD configure SASL.
+func TestKafkaSinkBroker(t *testing.T) {
+	brokers := os.Getenv("GOLOG_KAFKA_BROKERS")
+	if brokers == "" {
+		t.Skip("GOLOG_KAFKA_BROKERS is not set")
+	}
+	topic := os.Getenv("GOLOG_KAFKA_TOPIC")
+	if topic == "" {
+		topic = "golog-test"
+	}
+	conf := KafkaConfig{
+		Topic:         topic,
+		TLS:           os.Getenv("GOLOG_KAFKA_TLS") == "1",
+		SASLMechanism: os.Getenv("GOLOG_KAFKA_SASL_MECHANISM"),
+		SASLUsername:  os.Getenv("GOLOG_KAFKA_SASL_USERNAME"),
+		SASLPassword:  os.Getenv("GOLOG_KAFKA_SASL_PASSWORD"),
+	}
+	require.NoError(t, SinkConfig{Name: "kafka", Stream: StreamTDR, Type: SinkKafka, Address: brokers, Kafka: &conf}.Validate())
+
+	start := time.Now()
+	fallback := filepath.Join(t.TempDir(), "kafka-fallback.log")
+	conf.FallbackFile = fallback
+	logger, _ := newKafkaSinkLogger(t, brokers, &conf)
+	correlationID := "golog-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	for i := 0; i < 10; i++ {
+		logger.TDR(LogModel{CorrelationID: correlationID, Path: "/" + strconv.Itoa(i)})
+	}
+	require.NoError(t, logger.Sync())
+	require.NoError(t, logger.Close())
+
+	_, err := os.Stat(fallback)
+	assert.True(t, os.IsNotExist(err), "no entry goes to the fallback file")
+
+	opts, err := conf.withDefaults().clientOptions(splitList(brokers))
+	require.NoError(t, err)
+	consumer, err := kgo.NewClient(append(opts,
+		kgo.ConsumeTopics(topic), kgo.ConsumeResetOffset(kgo.NewOffset().AfterMilli(start.UnixMilli())))...)
+	require.NoError(t, err)
+	defer consumer.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	var paths []string
+	for len(paths) < 10 && ctx.Err() == nil {
+		consumer.PollFetches(ctx).EachRecord(func(r *kgo.Record) {
+			if string(r.Key) != correlationID {
+				return
+			}
+			var entry map[string]interface{}
+			require.NoError(t, json.Unmarshal(r.Value, &entry))
+			paths = append(paths, fmt.Sprint(entry["path"]))
+		})
+	}
+	require.Len(t, paths, 10)
+	for i, path := range paths {
+		assert.Equal(t, "/"+strconv.Itoa(i), path, "in order")
+	}
+}
+
+func TestKafkaSinkValidate(t *testing.T) {
+	assert.NoError(t, SinkConfig{Name: "x", Stream: StreamTDR, Type: SinkKafka, Address: "localhost:9092",
+		Kafka: &KafkaConfig{Topic: "tdr", Acks: KafkaAcksLeader}}.Validate())
+	assert.Error(t, SinkConfig{Name: "x", Type: SinkKafka, Kafka: &KafkaConfig{Topic: "tdr"}}.Validate())
+	assert.Error(t, SinkConfig{Name: "x", Type: SinkKafka, Address: "localhost:9092"}.Validate())
+	assert.Error(t, SinkConfig{Name: "x", Type: SinkKafka, Address: "localhost:9092",
+		Kafka: &KafkaConfig{Topic: "tdr", Acks: "2"}}.Validate())
+	assert.Error(t, SinkConfig{Name: "x", Type: SinkKafka, Address: "localhost:9092",
+		Kafka: &KafkaConfig{Topic: "tdr", QueueSize: -1}}.Validate())
+	assert.NoError(t, SinkConfig{Name: "x", Stream: StreamTDR, Type: SinkKafka, Address: "localhost:9092",
+		Kafka: &KafkaConfig{Topic: "tdr", TLS: true, SASLMechanism: KafkaSASLScramSHA512, SASLUsername: "golog"}}.Validate())
+	assert.Error(t, SinkConfig{Name: "x", Type: SinkKafka, Address: "localhost:9092",
+		Kafka: &KafkaConfig{Topic: "tdr", SASLMechanism: "GSSAPI", SASLUsername: "golog"}}.Validate())
+	assert.Error(t, SinkConfig{Name: "x", Type: SinkKafka, Address: "localhost:9092",
+		Kafka: &KafkaConfig{Topic: "tdr", SASLMechanism: KafkaSASLPlain}}.Validate())
+	assert.Error(t, SinkConfig{Name: "x", Type: SinkKafka, Address: "localhost:9092",
+		Kafka: &KafkaConfig{Topic: "tdr", TLS: true, CAFile: "/nonexistent/ca.pem"}}.Validate())
+}
@@ -0,0 +1,138 @@
+package golog
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/goccy/go-json"
+	"go.uber.org/zap/zapcore"
+)
+
+// LevelController is implemented by loggers whose levels can change at
+// runtime. *Log implements it; the level is shared by a logger and every
+// logger derived from it with WithContext or With.
+type LevelController interface {
+	// Level returns the minimum level of system log entries.
+	Level() zapcore.Level
+	// SetLevel changes the minimum level of system log entries.
+	SetLevel(level zapcore.Level)
+	// TDRLevel returns the minimum level of TDR records, which are written
+	// at InfoLevel.
+	TDRLevel() zapcore.Level
+	// SetTDRLevel changes the minimum level of TDR records. A level above
+	// InfoLevel turns TDR logging off.
+	SetTDRLevel(level zapcore.Level)
+	// ComponentLevels returns the levels of the components named with
+	// Named, which override the system level.
+	ComponentLevels() map[string]zapcore.Level
+	// SetComponentLevels replaces the levels of the named components.
+	SetComponentLevels(levels map[string]zapcore.Level)
+}
+
+func (l *Log) Level() zapcore.Level {
+	return l.state.levels.global.Level()
+}
+
+func (l *Log) SetLevel(level zapcore.Level) {
+	l.state.levels.global.SetLevel(level)
+}
+
+func (l *Log) TDRLevel() zapcore.Level {
+	return l.state.levelTDR.Level()
+}
+
+func (l *Log) SetTDRLevel(level zapcore.Level) {
+	l.state.levelTDR.SetLevel(level)
+}
+
+type levelPayload struct {
+	Level      string            `json:"level,omitempty"`
+	TDRLevel   string            `json:"tdrLevel,omitempty"`
+	Components map[string]string `json:"components,omitempty"`
+}
+
+type levelError struct {
+	Error string `json:"error"`
+}
+
+// LevelHandler returns an http.Handler that reports the levels of l on GET
+// and changes them on PUT, e.g.
+//
+//	curl -X PUT -d '{"level":"debug"}' localhost:8080/log/level
+//	{"level":"debug","tdrLevel":"info"}
+//
+// Any field of the PUT body may be omitted to keep that level. Component
+// levels are replaced as a whole by the "components" object, e.g.
+// {"components":{"payment":"debug"}}; send {"components":{}} to clear them.
+// Mount it on an internal admin port only.
+func LevelHandler(l LevelController) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			var payload levelPayload
+			if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+				writeLevelJSON(w, http.StatusBadRequest, levelError{Error: fmt.Sprintf("invalid body: %v", err)})
+				return
+			}
+
+			var level, levelTDR zapcore.Level
+			var err error
+			if payload.Level != "" {
+				if level, err = zapcore.ParseLevel(payload.Level); err != nil {
+					writeLevelJSON(w, http.StatusBadRequest, levelError{Error: err.Error()})
+					return
+				}
+			}
+			if payload.TDRLevel != "" {
+				if levelTDR, err = zapcore.ParseLevel(payload.TDRLevel); err != nil {
+					writeLevelJSON(w, http.StatusBadRequest, levelError{Error: err.Error()})
+					return
+				}
+			}
+
+			var components map[string]zapcore.Level
+			if payload.Components != nil {
+				components = make(map[string]zapcore.Level, len(payload.Components))
+				for name, text := range payload.Components {
+					if components[name], err = zapcore.ParseLevel(text); err != nil {
+						writeLevelJSON(w, http.StatusBadRequest, levelError{Error: fmt.Sprintf("component %q: %v", name, err)})
+						return
+					}
+				}
+			}
+
+			if payload.Level != "" {
+				l.SetLevel(level)
+			}
+			if payload.TDRLevel != "" {
+				l.SetTDRLevel(levelTDR)
+			}
+			if components != nil {
+				l.SetComponentLevels(components)
+			}
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			writeLevelJSON(w, http.StatusMethodNotAllowed, levelError{Error: "only GET and PUT are supported"})
+			return
+		}
+
+		payload := levelPayload{
+			Level:    l.Level().String(),
+			TDRLevel: l.TDRLevel().String(),
+		}
+		if components := l.ComponentLevels(); len(components) > 0 {
+			payload.Components = make(map[string]string, len(components))
+			for name, level := range components {
+				payload.Components[name] = level.String()
+			}
+		}
+		writeLevelJSON(w, http.StatusOK, payload)
+	})
+}
+
+func writeLevelJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
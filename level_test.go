@@ -0,0 +1,112 @@
+package golog
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+)
+
+func newLevelTestLogger(t *testing.T) (*Log, string) {
+	t.Helper()
+	tmpDir := t.TempDir()
+	logger := NewLogger(Config{
+		App:          "testapp",
+		AppVer:       "1.0.0",
+		Env:          "production",
+		FileLocation: tmpDir,
+		FileMaxSize:  10,
+	})
+	return logger.(*Log), tmpDir
+}
+
+func TestSetLevel(t *testing.T) {
+	l, tmpDir := newLevelTestLogger(t)
+	child := l.WithContext(context.Background())
+
+	child.Debug("hidden")
+	l.SetLevel(zapcore.DebugLevel)
+	child.Debug("shown")
+	l.SetLevel(zapcore.ErrorLevel)
+	child.Info("hidden")
+
+	l.SetTDRLevel(zapcore.WarnLevel)
+	l.TDR(LogModel{CorrelationID: "hidden"})
+	l.SetTDRLevel(zapcore.InfoLevel)
+	l.TDR(LogModel{CorrelationID: "shown"})
+	require.NoError(t, l.Sync())
+
+	lines := readLogLines(t, filepath.Join(tmpDir, "system.log"))
+	require.Len(t, lines, 1)
+	assert.Equal(t, "shown", lines[0]["message"])
+
+	lines = readLogLines(t, filepath.Join(tmpDir, "tdr.log"))
+	require.Len(t, lines, 1)
+	assert.Equal(t, "shown", lines[0]["correlationId"])
+}
+
+func TestLevelHandler(t *testing.T) {
+	l, _ := newLevelTestLogger(t)
+	handler := LevelHandler(l)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/log/level", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"level":"info","tdrLevel":"info"}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/log/level", strings.NewReader(`{"level":"debug"}`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"level":"debug","tdrLevel":"info"}`, rec.Body.String())
+	assert.Equal(t, zapcore.DebugLevel, l.Level())
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/log/level", strings.NewReader(`{"tdrLevel":"warn"}`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"level":"debug","tdrLevel":"warn"}`, rec.Body.String())
+	assert.Equal(t, zapcore.WarnLevel, l.TDRLevel())
+}
+
+func TestLevelHandlerRejectsBadRequests(t *testing.T) {
+	l, _ := newLevelTestLogger(t)
+	handler := LevelHandler(l)
+
+	for _, body := range []string{`{"level":"verbose"}`, `{"level":"debug","tdrLevel":"loud"}`, `not json`} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/log/level", strings.NewReader(body)))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+		assert.Contains(t, rec.Body.String(), `"error"`, body)
+	}
+	assert.Equal(t, zapcore.InfoLevel, l.Level(), "a rejected request changes nothing")
+	assert.Equal(t, zapcore.InfoLevel, l.TDRLevel())
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/log/level", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, "GET, PUT", rec.Header().Get("Allow"))
+}
+
+func TestSingletonLevel(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	assert.Equal(t, zapcore.InfoLevel, Level())
+	SetLevel(zapcore.DebugLevel)
+
+	Load(Config{
+		App:          "testapp",
+		AppVer:       "1.0.0",
+		Env:          "production",
+		FileLocation: t.TempDir(),
+		FileMaxSize:  10,
+	})
+	SetLevel(zapcore.WarnLevel)
+	assert.Equal(t, zapcore.WarnLevel, Level())
+}
@@ -5,10 +5,10 @@ import (
 	"sync"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 var (
-	once      sync.Once
 	singleton LoggerInterface
 	mu        sync.RWMutex
 )
@@ -16,10 +16,18 @@ var (
 // Load constructs and returns a singleton logger instance.
 // The logger is initialized only once on the first call.
 func Load(config Config) LoggerInterface {
-	once.Do(func() {
-		singleton = NewLogger(config)
-	})
+	mu.RLock()
+	l := singleton
+	mu.RUnlock()
+	if l != nil {
+		return l
+	}
 
+	mu.Lock()
+	defer mu.Unlock()
+	if singleton == nil {
+		singleton = NewLogger(config)
+	}
 	return singleton
 }
 
@@ -28,10 +36,11 @@ func Load(config Config) LoggerInterface {
 func Reset() {
 	mu.Lock()
 	defer mu.Unlock()
-	once = sync.Once{}
 	singleton = nil
 }
 
+// WithContext returns a child of the singleton logger bound to ctx.
+// The singleton itself is not modified.
 func WithContext(ctx context.Context) LoggerInterface {
 	mu.RLock()
 	defer mu.RUnlock()
@@ -41,6 +50,28 @@ func WithContext(ctx context.Context) LoggerInterface {
 	return nil
 }
 
+// With returns a child of the singleton logger with fields pre-bound.
+// The singleton itself is not modified.
+func With(fields ...zap.Field) LoggerInterface {
+	mu.RLock()
+	defer mu.RUnlock()
+	if singleton != nil {
+		return singleton.With(fields...)
+	}
+	return nil
+}
+
+// Named returns a child of the singleton logger for the named component.
+// The singleton itself is not modified.
+func Named(name string) LoggerInterface {
+	mu.RLock()
+	defer mu.RUnlock()
+	if singleton != nil {
+		return singleton.Named(name)
+	}
+	return nil
+}
+
 // Debug logs a message at DebugLevel.
 func Debug(msg string, fields ...zap.Field) {
 	mu.RLock()
@@ -119,3 +150,34 @@ func Sync() error {
 	}
 	return singleton.Sync()
 }
+
+// SetLevel changes the minimum level of system log entries of the singleton
+// logger at runtime.
+func SetLevel(level zapcore.Level) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if l, ok := singleton.(LevelController); ok {
+		l.SetLevel(level)
+	}
+}
+
+// Level returns the minimum level of system log entries of the singleton
+// logger, or InfoLevel when it is not loaded.
+func Level() zapcore.Level {
+	mu.RLock()
+	defer mu.RUnlock()
+	if l, ok := singleton.(LevelController); ok {
+		return l.Level()
+	}
+	return zapcore.InfoLevel
+}
+
+// SetComponentLevels replaces the levels of the components of the singleton
+// logger at runtime.
+func SetComponentLevels(levels map[string]zapcore.Level) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if l, ok := singleton.(LevelController); ok {
+		l.SetComponentLevels(levels)
+	}
+}
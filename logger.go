@@ -2,109 +2,65 @@ package golog
 
 import (
 	"context"
-	"net/http"
 	"os"
 	"strings"
 	"time"
 
 	"github.com/goccy/go-json"
-	"github.com/valyala/fasthttp"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"gopkg.in/natefinch/lumberjack.v2"
 )
 
-var SENSITIVE_HEADER = []string{
-	"Authorization",
-	"Signature",
-	"Apikey",
-}
-
-var SENSITIVE_ATTR = map[string]bool{
-	"password":      true,
-	"license":       true,
-	"license_code":  true,
-	"token":         true,
-	"access_token":  true,
-	"refresh_token": true,
-}
-
+// Log is the default LoggerInterface implementation. A Log is never mutated
+// after construction; WithContext and With return derived copies that share
+// the underlying cores, so a single instance is safe for concurrent use.
 type Log struct {
-	logger    *zap.Logger
-	loggerTDR *zap.Logger
-	ctx       *context.Context
+	logger     *zap.Logger
+	loggerTDR  *zap.Logger
+	ctx        context.Context
+	extractors []namedExtractor
+	state      *logState
 }
 
 func NewLogger(conf Config) LoggerInterface {
-	// Validate and set defaults
-	conf.Validate()
-
-	rotator := &lumberjack.Logger{
-		Filename:   conf.FileLocation + "/system.log",
-		MaxSize:    conf.FileMaxSize, // megabytes
-		MaxBackups: conf.FileMaxBackup,
-		MaxAge:     conf.FileMaxAge, // days
-	}
-
-	rotatorTDR := &lumberjack.Logger{
-		Filename:   conf.FileTDRLocation + "/tdr.log",
-		MaxSize:    conf.FileMaxSize, // megabytes
-		MaxBackups: conf.FileMaxBackup,
-		MaxAge:     conf.FileMaxAge, // days
-	}
-
-	encoderConfig := zap.NewDevelopmentEncoderConfig()
-
-	if conf.Env == "production" {
-		encoderConfig = zap.NewProductionEncoderConfig()
+	// Set defaults; invalid settings are reported by Validate, not here
+	_ = conf.Validate()
+
+	async := &asyncOutputs{}
+	out := &outputs{async: async, conf: conf.Async, sinks: openSinks(conf, async)}
+
+	if conf.FileLocation != "" {
+		rotator := &lumberjack.Logger{
+			Filename:   conf.FileLocation + "/system.log",
+			MaxSize:    conf.FileMaxSize, // megabytes
+			MaxBackups: conf.FileMaxBackup,
+			MaxAge:     conf.FileMaxAge, // days
+		}
+		out.system = async.wrap(zapcore.AddSync(rotator), conf.Async, &async.system)
+		out.files = append(out.files, rotator)
 	}
 
-	encoderConfig.TimeKey = "timestamp"
-	encoderConfig.LevelKey = "logLevel"
-	encoderConfig.MessageKey = "message"
-	encoderConfig.StacktraceKey = "stacktrace"
-	encoderConfig.EncodeTime = zapcore.TimeEncoderOfLayout(time.RFC3339)
-	encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
-
-	jsonEncoder := zapcore.NewJSONEncoder(encoderConfig)
-	consoleEncoder := zapcore.NewConsoleEncoder(encoderConfig)
-
-	logLevel := conf.LogLevel
-	if logLevel == 0 {
-		logLevel = zapcore.InfoLevel
+	if conf.FileTDRLocation != "" {
+		rotatorTDR := &lumberjack.Logger{
+			Filename:   conf.FileTDRLocation + "/tdr.log",
+			MaxSize:    conf.FileMaxSize, // megabytes
+			MaxBackups: conf.FileMaxBackup,
+			MaxAge:     conf.FileMaxAge, // days
+		}
+		out.tdr = async.wrap(zapcore.AddSync(rotatorTDR), conf.Async, &async.tdr)
+		out.files = append(out.files, rotatorTDR)
 	}
 
-	core := zapcore.NewCore(
-		jsonEncoder,
-		zapcore.AddSync(rotator),
-		zap.NewAtomicLevelAt(logLevel),
-	)
-
-	coreTDR := zapcore.NewCore(
-		jsonEncoder,
-		zapcore.AddSync(rotatorTDR),
-		zap.NewAtomicLevelAt(logLevel),
-	)
-
-	if conf.Stdout {
-		core = zapcore.NewTee(
-			core,
-			zapcore.NewCore(
-				consoleEncoder,
-				zapcore.AddSync(os.Stdout),
-				zap.NewAtomicLevelAt(logLevel),
-			),
-		)
-
-		coreTDR = zapcore.NewTee(
-			coreTDR,
-			zapcore.NewCore(
-				consoleEncoder,
-				zapcore.AddSync(os.Stdout),
-				zap.NewAtomicLevelAt(logLevel),
-			),
-		)
+	// Shared by every core of a stream, so SetLevel and SetTDRLevel apply
+	// to the file and console outputs at once. System cores let through
+	// the lowest level of any component; componentCore filters the rest.
+	state := &logState{
+		outputs:  out,
+		levels:   newComponentLevels(zap.NewAtomicLevelAt(conf.LogLevel), conf.ComponentLevels),
+		levelTDR: zap.NewAtomicLevelAt(conf.LogLevel),
 	}
+	state.current.Store(state.newGeneration(conf))
 
 	appVer := conf.AppVer
 
@@ -117,95 +73,140 @@ func NewLogger(conf Config) LoggerInterface {
 		}
 	}
 
-	logger := zap.New(core, zap.AddStacktrace(zap.ErrorLevel), zap.AddCallerSkip(2)).With(
+	logger := zap.New(&swapCore{state: state}, zap.AddStacktrace(zap.ErrorLevel), zap.AddCallerSkip(2)).With(
 		zap.String("app", conf.App),
 		zap.String("appVer", appVer),
 		zap.String("env", conf.Env),
 	)
 
-	loggerTDR := zap.New(coreTDR, zap.AddCallerSkip(2)).With(
+	loggerTDR := zap.New(&swapCore{state: state, tdr: true}, zap.AddCallerSkip(2)).With(
 		zap.String("app", conf.App),
 		zap.String("appVer", appVer),
 		zap.String("env", conf.Env),
 	)
 
 	return &Log{
-		logger:    logger,
-		loggerTDR: loggerTDR,
-		ctx:       nil,
+		logger:     logger,
+		loggerTDR:  loggerTDR,
+		extractors: sortedExtractors(conf.ContextExtractors),
+		state:      state,
+	}
+}
+
+// newCores builds the system and TDR cores for conf on top of the outputs
+// and levels of s, which outlive any single configuration.
+func (s *logState) newCores(conf Config) (system, tdr zapcore.Core) {
+	encoderConfig := zap.NewDevelopmentEncoderConfig()
+
+	if conf.Env == "production" {
+		encoderConfig = zap.NewProductionEncoderConfig()
+	}
+
+	encoderConfig.TimeKey = "timestamp"
+	encoderConfig.LevelKey = "logLevel"
+	encoderConfig.MessageKey = "message"
+	encoderConfig.NameKey = ComponentKey
+	encoderConfig.StacktraceKey = "stacktrace"
+	encoderConfig.EncodeTime = zapcore.TimeEncoderOfLayout(time.RFC3339)
+	encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+
+	jsonEncoder := zapcore.NewJSONEncoder(encoderConfig)
+	consoleEncoder := zapcore.NewConsoleEncoder(encoderConfig)
+
+	var systemCores, tdrCores []zapcore.Core
+	if s.outputs.system != nil {
+		systemCores = append(systemCores, zapcore.NewCore(jsonEncoder, s.outputs.system, s.levels))
+	}
+	if s.outputs.tdr != nil {
+		tdrCores = append(tdrCores, zapcore.NewCore(jsonEncoder, s.outputs.tdr, s.levelTDR))
 	}
+
+	if conf.Stdout {
+		stdoutSystem, stdoutTDR := s.outputs.stdout()
+		systemCores = append(systemCores, zapcore.NewCore(consoleEncoder, stdoutSystem, s.levels))
+		tdrCores = append(tdrCores, zapcore.NewCore(consoleEncoder, stdoutTDR, s.levelTDR))
+	}
+
+	for _, sink := range s.outputs.sinks {
+		if sink.conf.Stream == StreamTDR {
+			tdrCores = append(tdrCores, sink.core(encoderConfig, s.levelTDR))
+		} else {
+			systemCores = append(systemCores, sink.core(encoderConfig, s.levels))
+		}
+	}
+
+	return &componentCore{Core: sample(zapcore.NewTee(systemCores...), conf.Sampling), levels: s.levels}, zapcore.NewTee(tdrCores...)
 }
 
+// WithContext returns a child logger bound to ctx. The receiver is left
+// untouched, so concurrent callers never observe each other's context.
 func (l *Log) WithContext(ctx context.Context) LoggerInterface {
-	l.ctx = &ctx
-	return l
+	child := *l
+	child.ctx = ctx
+	return &child
+}
+
+// With returns a child logger that adds fields to every system and TDR entry.
+func (l *Log) With(fields ...zap.Field) LoggerInterface {
+	child := *l
+	child.logger = l.logger.With(fields...)
+	child.loggerTDR = l.loggerTDR.With(fields...)
+	return &child
 }
 
 func (l *Log) Debug(msg string, fields ...zap.Field) {
-	if l.ctx != nil {
-		ctxField := populateFieldFromContext(*l.ctx)
-		fields = append(fields, ctxField...)
-	}
+	fields = l.withContextFields(fields)
 	l.logger.Debug(msg, fields...)
 }
 
 func (l *Log) Info(msg string, fields ...zap.Field) {
-	if l.ctx != nil {
-		ctxField := populateFieldFromContext(*l.ctx)
-		fields = append(fields, ctxField...)
-	}
+	fields = l.withContextFields(fields)
 	l.logger.Info(msg, fields...)
 }
 
 func (l *Log) Warn(msg string, fields ...zap.Field) {
-	if l.ctx != nil {
-		ctxField := populateFieldFromContext(*l.ctx)
-		fields = append(fields, ctxField...)
-	}
+	fields = l.withContextFields(fields)
 	l.logger.Warn(msg, fields...)
 }
 
 func (l *Log) Error(msg string, err error, fields ...zap.Field) {
-	if l.ctx != nil {
-		ctxField := populateFieldFromContext(*l.ctx)
-		fields = append(fields, ctxField...)
-	}
+	fields = l.withContextFields(fields)
 	fields = append(fields, zap.Any("error", toJSON(err)))
+	l.recordSpanEvent(zapcore.ErrorLevel, msg, err)
 	l.logger.Error(msg, fields...)
 }
 
 func (l *Log) Fatal(msg string, err error, fields ...zap.Field) {
-	if l.ctx != nil {
-		ctxField := populateFieldFromContext(*l.ctx)
-		fields = append(fields, ctxField...)
-	}
+	fields = l.withContextFields(fields)
 	fields = append(fields, zap.Any("error", toJSON(err)))
+	l.recordSpanEvent(zapcore.FatalLevel, msg, err)
 	l.logger.Fatal(msg, fields...)
 }
 
 func (l *Log) Panic(msg string, err error, fields ...zap.Field) {
-	if l.ctx != nil {
-		ctxField := populateFieldFromContext(*l.ctx)
-		fields = append(fields, ctxField...)
-	}
+	fields = l.withContextFields(fields)
 	fields = append(fields, zap.Any("error", toJSON(err)))
+	l.recordSpanEvent(zapcore.PanicLevel, msg, err)
 	l.logger.Panic(msg, fields...)
 }
 
 func (l *Log) TDR(log LogModel) {
-	fields := make([]zap.Field, 0)
-	if l.ctx != nil {
-		ctxField := populateFieldFromContext(*l.ctx)
-		fields = append(fields, ctxField...)
-	}
+	gen := l.state.current.Load()
+	fields := l.withContextFields(make([]zap.Field, 0, 14))
 
 	fields = append(fields, zap.String("correlationId", log.CorrelationID))
-	fields = append(fields, zap.Any("header", removeAuth(log.Header)))
-	fields = append(fields, zap.Any("request", toJSON(maskField(log.Request))))
+	if log.Path != "" {
+		// the record's own path carries the query string, so it replaces
+		// the one taken from the context
+		fields = removeField(fields, PathKey.String())
+		fields = append(fields, zap.String(PathKey.String(), gen.masker.maskPath(log.Path)))
+	}
+	fields = append(fields, zap.Any("header", gen.masker.removeAuth(log.Header)))
+	fields = append(fields, zap.Any("request", gen.body(log.Request, headerContentType(log.Header), gen.limits.maxRequest)))
 	fields = append(fields, zap.String("statusCode", log.StatusCode))
 	fields = append(fields, zap.String("method", log.Method))
 	fields = append(fields, zap.Uint64("httpStatus", log.HttpStatus))
-	fields = append(fields, zap.Any("response", toJSON(maskField(log.Response))))
+	fields = append(fields, zap.Any("response", gen.body(log.Response, "", gen.limits.maxResponse)))
 	fields = append(fields, zap.Int64("rt", log.ResponseTime.Milliseconds()))
 	fields = append(fields, zap.Any("error", toJSON(log.Error)))
 	fields = append(fields, zap.Any("otherData", toJSON(log.OtherData)))
@@ -224,6 +225,45 @@ func (l *Log) Sync() error {
 	return err2
 }
 
+// AsyncStats reports the dropped and buffered entries of the asynchronous
+// write pipeline enabled with Config.Async.
+func (l *Log) AsyncStats() AsyncStats {
+	return l.state.outputs.async.stats()
+}
+
+// Close flushes every buffered entry, stops the background writers of an
+// asynchronous logger and closes its system and TDR files and the files and
+// sockets of its sinks. The logger, and every logger derived from it with
+// WithContext or With, must not be used afterwards.
+func (l *Log) Close() error {
+	return l.state.outputs.close()
+}
+
+// withContextFields appends the fields extracted from the bound context, if any.
+func (l *Log) withContextFields(fields []zap.Field) []zap.Field {
+	if l.ctx == nil {
+		return fields
+	}
+	return append(fields, populateFieldFromContext(l.ctx, l.extractors...)...)
+}
+
+// removeField drops every field with the given key.
+func removeField(fields []zap.Field, key string) []zap.Field {
+	kept := fields[:0]
+	for _, f := range fields {
+		if f.Key != key {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+func (l *Log) recordSpanEvent(level zapcore.Level, msg string, err error) {
+	if l.ctx != nil && l.state.current.Load().spanEvents {
+		recordSpanEvent(l.ctx, level, msg, err)
+	}
+}
+
 func toJSON(object interface{}) interface{} {
 	if object == nil {
 		return nil
@@ -243,111 +283,3 @@ func toJSON(object interface{}) interface{} {
 	}
 	return object
 }
-
-func removeAuth(header interface{}) interface{} {
-	// Fasthttp
-	if mapHeader, ok := header.(fasthttp.RequestHeader); ok {
-		for _, val := range SENSITIVE_HEADER {
-			mapHeader.Del(val)
-		}
-		return string(mapHeader.Header())
-	}
-
-	// Http
-	if mapHeader, ok := header.(http.Header); ok {
-		for _, val := range SENSITIVE_HEADER {
-			mapHeader.Del(val)
-		}
-	}
-
-	return header
-}
-
-func maskField(body interface{}) interface{} {
-	if body == nil {
-		return nil
-	}
-
-	// Handle []byte input
-	if bodyByte, ok := body.([]byte); ok {
-		if len(bodyByte) == 0 {
-			return bodyByte
-		}
-		var bodyMap map[string]interface{}
-		if err := json.Unmarshal(bodyByte, &bodyMap); err != nil {
-			return string(bodyByte)
-		}
-		return maskFieldMap(bodyMap)
-	}
-
-	// Handle map[string]interface{} directly (avoid re-marshaling)
-	if bodyMap, ok := body.(map[string]interface{}); ok {
-		return maskFieldMap(bodyMap)
-	}
-
-	return body
-}
-
-func maskFieldMap(bodyMap map[string]interface{}) map[string]interface{} {
-	if bodyMap == nil {
-		return nil
-	}
-
-	result := make(map[string]interface{}, len(bodyMap))
-	for key, value := range bodyMap {
-		switch v := value.(type) {
-		case map[string]interface{}:
-			// Recursively mask nested maps without marshaling
-			result[key] = maskFieldMap(v)
-		case []interface{}:
-			// Handle arrays
-			maskedArray := make([]interface{}, len(v))
-			for i, item := range v {
-				if itemMap, ok := item.(map[string]interface{}); ok {
-					maskedArray[i] = maskFieldMap(itemMap)
-				} else {
-					maskedArray[i] = item
-				}
-			}
-			result[key] = maskedArray
-		default:
-			if isSensitiveField(key) {
-				result[key] = strings.Repeat("*", 5)
-			} else {
-				result[key] = value
-			}
-		}
-	}
-	return result
-}
-
-func isSensitiveField(key string) bool {
-	if _, ok := SENSITIVE_ATTR[strings.ToLower(key)]; ok {
-		return true
-	}
-	return false
-}
-
-func populateFieldFromContext(ctx context.Context) []zap.Field {
-	// Pre-allocate with estimated capacity (max 4 fields)
-	fieldFromCtx := make([]zap.Field, 0, 4)
-
-	// Support both typed keys and string keys for backward compatibility
-	if v, ok := GetTraceID(ctx); ok {
-		fieldFromCtx = append(fieldFromCtx, zap.String(TraceIDKey.String(), v))
-	}
-
-	if v, ok := GetSrcIP(ctx); ok {
-		fieldFromCtx = append(fieldFromCtx, zap.String(SrcIPKey.String(), v))
-	}
-
-	if v, ok := GetPort(ctx); ok {
-		fieldFromCtx = append(fieldFromCtx, zap.String(PortKey.String(), v))
-	}
-
-	if v, ok := GetPath(ctx); ok {
-		fieldFromCtx = append(fieldFromCtx, zap.String(PathKey.String(), v))
-	}
-
-	return fieldFromCtx
-}
@@ -1,13 +1,17 @@
 package golog
 
 import (
+	"bufio"
 	"context"
+	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/goccy/go-json"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
@@ -69,6 +73,117 @@ func TestLoggerWithContext(t *testing.T) {
 	logger.Warn("Warning message")
 }
 
+func TestWithContextDoesNotMutateParent(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	config := Config{
+		App:          "testapp",
+		AppVer:       "1.0.0",
+		Env:          "development",
+		FileLocation: tmpDir,
+		FileMaxSize:  10,
+	}
+
+	logger := NewLogger(config)
+
+	child := logger.WithContext(WithTraceID(context.Background(), "trace-child"))
+	assert.NotSame(t, logger, child)
+
+	logger.Info("parent")
+	child.Info("child")
+	require.NoError(t, logger.Sync())
+
+	lines := readLogLines(t, filepath.Join(tmpDir, "system.log"))
+	require.Len(t, lines, 2)
+	assert.NotContains(t, lines[0], "traceId")
+	assert.Equal(t, "trace-child", lines[1]["traceId"])
+}
+
+func TestWith(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	config := Config{
+		App:          "testapp",
+		AppVer:       "1.0.0",
+		Env:          "development",
+		FileLocation: tmpDir,
+		FileMaxSize:  10,
+	}
+
+	logger := NewLogger(config)
+
+	child := logger.With(zap.String("userId", "u-1")).
+		WithContext(WithTraceID(context.Background(), "trace-1"))
+	child.Info("child")
+	child.TDR(LogModel{Method: "GET"})
+	logger.Info("parent")
+	require.NoError(t, logger.Sync())
+
+	lines := readLogLines(t, filepath.Join(tmpDir, "system.log"))
+	require.Len(t, lines, 2)
+	assert.Equal(t, "u-1", lines[0]["userId"])
+	assert.Equal(t, "trace-1", lines[0]["traceId"])
+	assert.NotContains(t, lines[1], "userId")
+
+	tdrLines := readLogLines(t, filepath.Join(tmpDir, "tdr.log"))
+	require.Len(t, tdrLines, 1)
+	assert.Equal(t, "u-1", tdrLines[0]["userId"])
+	assert.Equal(t, "trace-1", tdrLines[0]["traceId"])
+}
+
+func TestWithContextConcurrent(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	tmpDir := t.TempDir()
+
+	config := Config{
+		App:          "testapp",
+		AppVer:       "1.0.0",
+		Env:          "development",
+		FileLocation: tmpDir,
+		FileMaxSize:  10,
+	}
+
+	Load(config)
+
+	const handlers = 50
+	var wg sync.WaitGroup
+	for i := 0; i < handlers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := fmt.Sprintf("req-%d", i)
+			ctx := WithTraceID(context.Background(), "trace-"+id)
+			ctx = WithSrcIP(ctx, "ip-"+id)
+
+			log := WithContext(ctx)
+			for j := 0; j < 10; j++ {
+				log.Info("handling", zap.String("req", id))
+				log.TDR(LogModel{CorrelationID: id})
+			}
+		}(i)
+	}
+	wg.Wait()
+	require.NoError(t, Sync())
+
+	lines := readLogLines(t, filepath.Join(tmpDir, "system.log"))
+	require.Len(t, lines, handlers*10)
+	for _, line := range lines {
+		id := line["req"]
+		assert.Equal(t, fmt.Sprintf("trace-%s", id), line["traceId"])
+		assert.Equal(t, fmt.Sprintf("ip-%s", id), line["srcIP"])
+	}
+
+	tdrLines := readLogLines(t, filepath.Join(tmpDir, "tdr.log"))
+	require.Len(t, tdrLines, handlers*10)
+	for _, line := range tdrLines {
+		id := line["correlationId"]
+		assert.Equal(t, fmt.Sprintf("trace-%s", id), line["traceId"])
+		assert.Equal(t, fmt.Sprintf("ip-%s", id), line["srcIP"])
+	}
+}
+
 func TestLoggerError(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -125,6 +240,39 @@ func TestTDR(t *testing.T) {
 	logger.TDR(tdr)
 }
 
+func TestCloseReleasesFiles(t *testing.T) {
+	// the open files of the process are listed in /proc on Linux
+	if _, err := os.ReadDir("/proc/self/fd"); err != nil {
+		t.Skip("open files cannot be listed:", err)
+	}
+	tmpDir := t.TempDir()
+	openFiles := func() int {
+		fds, _ := os.ReadDir("/proc/self/fd")
+		n := 0
+		for _, fd := range fds {
+			target, err := os.Readlink(filepath.Join("/proc/self/fd", fd.Name()))
+			if err == nil && filepath.Dir(target) == tmpDir {
+				n++
+			}
+		}
+		return n
+	}
+
+	logger := NewLogger(Config{
+		App:          "testapp",
+		AppVer:       "1.0.0",
+		Env:          "development",
+		FileLocation: tmpDir,
+		FileMaxSize:  10,
+	})
+	logger.Info("hello")
+	logger.TDR(LogModel{Path: "/"})
+	require.Equal(t, 2, openFiles())
+
+	require.NoError(t, logger.(*Log).Close())
+	assert.Zero(t, openFiles())
+}
+
 func TestConfigValidation(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -142,7 +290,7 @@ func TestConfigValidation(t *testing.T) {
 	}
 
 	// Validate config explicitly (this is called inside NewLogger)
-	config.Validate()
+	require.NoError(t, config.Validate())
 
 	// Verify default FileTDRLocation was set
 	expectedTDRLocation := tmpDir
@@ -288,6 +436,65 @@ func TestContextKeysBackwardCompatibility(t *testing.T) {
 	logger.Info("Test backward compatibility")
 }
 
+type tenantKey struct{}
+
+func TestRegisterContextExtractor(t *testing.T) {
+	RegisterContextExtractor("tenantId", StringContextExtractor(tenantKey{}, "tenantId"))
+	defer UnregisterContextExtractor("tenantId")
+
+	tmpDir := t.TempDir()
+	config := Config{
+		App:          "testapp",
+		AppVer:       "1.0.0",
+		Env:          "development",
+		FileLocation: tmpDir,
+		FileMaxSize:  10,
+		ContextExtractors: map[string]ContextExtractor{
+			"requestId": func(ctx context.Context) (zap.Field, bool) {
+				return zap.String("requestId", "req-1"), true
+			},
+		},
+	}
+
+	logger := NewLogger(config)
+
+	ctx := WithTraceID(context.Background(), "trace-1")
+	ctx = context.WithValue(ctx, tenantKey{}, "tenant-1")
+	logger.WithContext(ctx).Info("with tenant")
+	logger.WithContext(ctx).TDR(LogModel{Method: "GET"})
+	require.NoError(t, logger.Sync())
+
+	lines := readLogLines(t, filepath.Join(tmpDir, "system.log"))
+	require.Len(t, lines, 1)
+	assert.Equal(t, "trace-1", lines[0]["traceId"])
+	assert.Equal(t, "tenant-1", lines[0]["tenantId"])
+	assert.Equal(t, "req-1", lines[0]["requestId"])
+
+	tdrLines := readLogLines(t, filepath.Join(tmpDir, "tdr.log"))
+	require.Len(t, tdrLines, 1)
+	assert.Equal(t, "tenant-1", tdrLines[0]["tenantId"])
+	assert.Equal(t, "req-1", tdrLines[0]["requestId"])
+}
+
+func TestContextExtractorOverride(t *testing.T) {
+	ctx := WithTraceID(context.Background(), "trace-1")
+
+	fields := populateFieldFromContext(ctx, namedExtractor{
+		name: TraceIDKey.String(),
+		extract: func(ctx context.Context) (zap.Field, bool) {
+			return zap.String("traceId", "local"), true
+		},
+	})
+	require.Len(t, fields, 1)
+	assert.Equal(t, "local", fields[0].String)
+
+	builtin := extractors.Load()
+	defer extractors.Store(builtin)
+
+	UnregisterContextExtractor(TraceIDKey.String())
+	assert.Empty(t, populateFieldFromContext(ctx))
+}
+
 func TestMaskField(t *testing.T) {
 	// Test with sensitive fields
 	body := map[string]interface{}{
@@ -297,7 +504,7 @@ func TestMaskField(t *testing.T) {
 		"data":     map[string]interface{}{"nested": "value"},
 	}
 
-	masked := maskField(body)
+	masked := policyMasker(nil).maskField(body)
 	maskedMap, ok := masked.(map[string]interface{})
 	require.True(t, ok)
 
@@ -312,7 +519,7 @@ func TestRemoveAuth(t *testing.T) {
 	header["Authorization"] = []string{"Bearer token123"}
 	header["Content-Type"] = []string{"application/json"}
 
-	result := removeAuth(header)
+	result := policyMasker(nil).removeAuth(header)
 	resultHeader, ok := result.(http.Header)
 	require.True(t, ok)
 
@@ -360,3 +567,23 @@ func BenchmarkContextPopulation(b *testing.B) {
 		_ = populateFieldFromContext(ctx)
 	}
 }
+
+// readLogLines decodes every JSON line written to the log file at path.
+func readLogLines(t *testing.T, path string) []map[string]interface{} {
+	t.Helper()
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+	}
+	require.NoError(t, scanner.Err())
+	return lines
+}
@@ -0,0 +1,454 @@
+package golog
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SENSITIVE_HEADER lists the headers removed from TDR records by the
+// default masking policy.
+//
+// Deprecated: set Config.Masking instead. Changes to this variable only
+// affect loggers created afterwards without an explicit policy.
+var SENSITIVE_HEADER = []string{
+	"Authorization",
+	"Signature",
+	"Apikey",
+}
+
+// SENSITIVE_ATTR lists the body keys masked in TDR records by the default
+// masking policy.
+//
+// Deprecated: set Config.Masking instead. Changes to this variable only
+// affect loggers created afterwards without an explicit policy.
+var SENSITIVE_ATTR = map[string]bool{
+	"password":      true,
+	"license":       true,
+	"license_code":  true,
+	"token":         true,
+	"access_token":  true,
+	"refresh_token": true,
+}
+
+const maskedValue = "*****"
+
+// MaskingPolicy decides which parts of TDR headers and bodies are sensitive.
+type MaskingPolicy struct {
+	// Body keys masked at any depth, matched case-insensitively.
+	Keys []string `json:"keys"`
+
+	// Glob patterns (path.Match syntax) matched case-insensitively against
+	// body keys, e.g. "*_token" or "card_*".
+	KeyPatterns []string `json:"keyPatterns"`
+
+	// Regular expressions matched against body keys, e.g. "(?i)^x-.*-secret$".
+	KeyRegexps []string `json:"keyRegexps"`
+
+	// JSON-path rules selecting values to mask, e.g. "$.card.number".
+	// A segment may be "*" to match any key or "[*]" to match any array
+	// index. The selected value is masked whole, even if it is an object
+	// or an array.
+	Paths []string `json:"paths"`
+
+	// Sensitive header names, matched case-insensitively. They are removed
+	// from TDR records unless Strategies says otherwise.
+	Headers []string `json:"headers"`
+
+	// Cookie names whose values are masked in Cookie and Set-Cookie
+	// headers, matched case-insensitively. Cookies named like a sensitive
+	// body key are masked too. Strategies keyed by the cookie name apply;
+	// StrategyDrop removes the cookie from the header.
+	Cookies []string `json:"cookies"`
+
+	// Masking strategy per rule, keyed by the entry of Keys, KeyPatterns,
+	// KeyRegexps, Paths, Headers or Cookies it applies to (all but regexps
+	// and paths case-insensitively), e.g. {"card_number": KeepLast(4),
+	// "email": "hash"}.
+	// See StrategyRedact, StrategyHash, StrategyFormat, StrategyDrop,
+	// KeepFirst and KeepLast.
+	Strategies map[string]string `json:"strategies"`
+
+	// Strategy for body rules without an entry in Strategies.
+	// Defaults to StrategyRedact. Headers without an entry are dropped.
+	DefaultStrategy string `json:"defaultStrategy"`
+
+	// Salt prepended to values hashed with StrategyHash.
+	HashSalt string `json:"hashSalt"`
+
+	// Value scanners run on every string in the body, whatever its key,
+	// e.g. []string{ScanCardNumber, ScanEmail}. Disabled by default.
+	Scanners []string `json:"scanners"`
+
+	// Partially mask scanner matches (e.g. keep the last four digits of a
+	// card number) instead of replacing them with "*****".
+	PartialScan bool `json:"partialScan"`
+}
+
+// DefaultMaskingPolicy returns the policy used when Config.Masking is nil.
+func DefaultMaskingPolicy() MaskingPolicy {
+	keys := make([]string, 0, len(SENSITIVE_ATTR))
+	for key, sensitive := range SENSITIVE_ATTR {
+		if sensitive {
+			keys = append(keys, key)
+		}
+	}
+	return MaskingPolicy{
+		Keys:    keys,
+		Headers: append([]string(nil), SENSITIVE_HEADER...),
+	}
+}
+
+// Validate reports invalid glob patterns, regular expressions, JSON-path
+// rules, scanners and strategies in the policy.
+func (p MaskingPolicy) Validate() error {
+	_, err := newMasker(p)
+	return err
+}
+
+// masker is the compiled, immutable form of a MaskingPolicy. Each logger
+// owns one, so policies never leak between loggers.
+type masker struct {
+	keys     map[string]bool
+	patterns []string
+	regexps  []*regexp.Regexp
+	paths    []jsonPathRule
+	headers  map[string]bool
+	cookies  map[string]bool
+
+	strategies      map[string]maskStrategy
+	defaultStrategy maskStrategy
+	hashSalt        string
+
+	scanners    []*valueScanner
+	partialScan bool
+}
+
+type jsonPathRule struct {
+	rule     string
+	segments []string
+}
+
+// newMasker compiles p. Invalid patterns, regular expressions and paths are
+// skipped and reported together in the returned error, so the masker is
+// always usable.
+func newMasker(p MaskingPolicy) (*masker, error) {
+	m := &masker{
+		keys:            make(map[string]bool, len(p.Keys)),
+		headers:         make(map[string]bool, len(p.Headers)),
+		cookies:         make(map[string]bool, len(p.Cookies)),
+		strategies:      make(map[string]maskStrategy, len(p.Strategies)),
+		defaultStrategy: maskStrategy{kind: StrategyRedact},
+		hashSalt:        p.HashSalt,
+		partialScan:     p.PartialScan,
+	}
+
+	var errs []error
+	if p.DefaultStrategy != "" {
+		strategy, err := parseStrategy(p.DefaultStrategy)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			m.defaultStrategy = strategy
+		}
+	}
+	for rule, spec := range p.Strategies {
+		strategy, err := parseStrategy(spec)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		m.strategies[rule] = strategy
+		m.strategies[strings.ToLower(rule)] = strategy
+	}
+	scanners, err := compileScanners(p.Scanners)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	m.scanners = scanners
+	for _, key := range p.Keys {
+		m.keys[strings.ToLower(key)] = true
+	}
+	for _, name := range p.Headers {
+		m.headers[strings.ToLower(name)] = true
+	}
+	for _, name := range p.Cookies {
+		m.cookies[strings.ToLower(name)] = true
+	}
+	for _, pattern := range p.KeyPatterns {
+		pattern = strings.ToLower(pattern)
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("golog: invalid key pattern %q: %w", pattern, err))
+			continue
+		}
+		m.patterns = append(m.patterns, pattern)
+	}
+	for _, expr := range p.KeyRegexps {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("golog: invalid key regexp %q: %w", expr, err))
+			continue
+		}
+		m.regexps = append(m.regexps, re)
+	}
+	for _, rule := range p.Paths {
+		segments, err := parseJSONPath(rule)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		m.paths = append(m.paths, jsonPathRule{rule: rule, segments: segments})
+	}
+	return m, errors.Join(errs...)
+}
+
+// policyMasker compiles the policy configured for a logger, falling back to
+// DefaultMaskingPolicy when p is nil.
+func policyMasker(p *MaskingPolicy) *masker {
+	policy := DefaultMaskingPolicy()
+	if p != nil {
+		policy = *p
+	}
+	m, _ := newMasker(policy)
+	return m
+}
+
+// parseJSONPath splits a rule such as "$.items[*].card.number" into
+// segments ("items", "[*]", "card", "number").
+func parseJSONPath(rule string) ([]string, error) {
+	rest, ok := strings.CutPrefix(rule, "$")
+	if !ok || rest == "" {
+		return nil, fmt.Errorf("golog: invalid JSON path %q: must start with \"$.\"", rule)
+	}
+
+	var segments []string
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("golog: invalid JSON path %q: empty segment", rule)
+			}
+			segments = append(segments, rest[:end])
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("golog: invalid JSON path %q: unclosed bracket", rule)
+			}
+			index := rest[1:end]
+			if index != "*" {
+				if _, err := strconv.Atoi(index); err != nil {
+					return nil, fmt.Errorf("golog: invalid JSON path %q: bad index %q", rule, index)
+				}
+			}
+			segments = append(segments, rest[:end+1])
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("golog: invalid JSON path %q", rule)
+		}
+	}
+	return segments, nil
+}
+
+// bodyStrategy returns the strategy for the body rule that matched.
+func (m *masker) bodyStrategy(rule string) maskStrategy {
+	if strategy, ok := m.strategies[rule]; ok {
+		return strategy
+	}
+	return m.defaultStrategy
+}
+
+func (m *masker) maskField(body interface{}) interface{} {
+	return m.maskBody(body, "")
+}
+
+// maskBody masks a TDR body. Textual bodies are parsed according to
+// contentType, or sniffed when it is empty.
+func (m *masker) maskBody(body interface{}, contentType string) interface{} {
+	if body == nil {
+		return nil
+	}
+
+	// Handle []byte input
+	if bodyByte, ok := body.([]byte); ok {
+		if len(bodyByte) == 0 {
+			return bodyByte
+		}
+		return m.maskText(string(bodyByte), contentType)
+	}
+
+	// Handle string input the same way, so JSON strings are masked too
+	if bodyString, ok := body.(string); ok {
+		if bodyString == "" {
+			return bodyString
+		}
+		return m.maskText(bodyString, contentType)
+	}
+
+	// Handle map[string]interface{} directly (avoid re-marshaling)
+	if bodyMap, ok := body.(map[string]interface{}); ok {
+		return m.maskFieldMap(bodyMap, nil, nil)
+	}
+
+	// Convert structs, pointers, slices and typed maps so they go through
+	// the same rules as decoded JSON
+	if isTypedBody(body) {
+		plain, ok := m.plainValue(reflect.ValueOf(body), 0)
+		if !ok {
+			return nil
+		}
+		return m.maskValue(plain, nil, nil)
+	}
+
+	return body
+}
+
+// maskFieldMap returns a masked copy of bodyMap. strategy is non-nil when
+// bodyMap sits under a sensitive key and applies to every scalar in it.
+// jsonPath holds the segments leading to bodyMap and is only tracked when the
+// policy has path rules.
+func (m *masker) maskFieldMap(bodyMap map[string]interface{}, strategy *maskStrategy, jsonPath []string) map[string]interface{} {
+	if bodyMap == nil {
+		return nil
+	}
+
+	result := make(map[string]interface{}, len(bodyMap))
+	for key, value := range bodyMap {
+		if masked, ok := value.(maskedString); ok {
+			result[key] = string(masked)
+			continue
+		}
+
+		childPath := m.appendPath(jsonPath, key)
+		if rule, ok := m.matchPath(childPath); ok {
+			if strategy := m.bodyStrategy(rule); strategy.kind != StrategyDrop {
+				result[key] = strategy.apply(value, m.hashSalt)
+			}
+			continue
+		}
+
+		valueStrategy := strategy
+		if rule, ok := m.sensitiveRule(key); ok {
+			s := m.bodyStrategy(rule)
+			if s.kind == StrategyDrop {
+				continue
+			}
+			valueStrategy = &s
+		}
+		result[key] = m.maskValue(value, valueStrategy, childPath)
+	}
+	return result
+}
+
+// maskValue masks value, applying strategy to every scalar when the key
+// holding it is sensitive (strategy is non-nil).
+func (m *masker) maskValue(value interface{}, strategy *maskStrategy, jsonPath []string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		// Recursively mask nested maps without marshaling
+		return m.maskFieldMap(v, strategy, jsonPath)
+	case []interface{}:
+		maskedArray := make([]interface{}, 0, len(v))
+		for i, item := range v {
+			itemPath := m.appendPath(jsonPath, "["+strconv.Itoa(i)+"]")
+			if rule, ok := m.matchPath(itemPath); ok {
+				if s := m.bodyStrategy(rule); s.kind != StrategyDrop {
+					maskedArray = append(maskedArray, s.apply(item, m.hashSalt))
+				}
+				continue
+			}
+			maskedArray = append(maskedArray, m.maskValue(item, strategy, itemPath))
+		}
+		return maskedArray
+	case string:
+		if strategy != nil {
+			return strategy.apply(v, m.hashSalt)
+		}
+		if len(m.scanners) > 0 {
+			return m.scanValue(v)
+		}
+		return v
+	default:
+		if strategy != nil {
+			return strategy.apply(value, m.hashSalt)
+		}
+		return value
+	}
+}
+
+// scanText runs the value scanners over a body that is not JSON.
+func (m *masker) scanText(s string) string {
+	if len(m.scanners) == 0 {
+		return s
+	}
+	return m.scanValue(s)
+}
+
+func (m *masker) appendPath(jsonPath []string, segment string) []string {
+	if len(m.paths) == 0 {
+		return nil
+	}
+	return append(jsonPath[:len(jsonPath):len(jsonPath)], segment)
+}
+
+// matchPath returns the JSON-path rule matching jsonPath, if any.
+func (m *masker) matchPath(jsonPath []string) (string, bool) {
+	for _, rule := range m.paths {
+		if len(rule.segments) != len(jsonPath) {
+			continue
+		}
+		matched := true
+		for i, segment := range rule.segments {
+			if !matchSegment(segment, jsonPath[i]) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return rule.rule, true
+		}
+	}
+	return "", false
+}
+
+func matchSegment(rule, segment string) bool {
+	switch {
+	case rule == segment:
+		return true
+	case rule == "*":
+		return !strings.HasPrefix(segment, "[")
+	case rule == "[*]":
+		return strings.HasPrefix(segment, "[")
+	default:
+		return false
+	}
+}
+
+// sensitiveRule returns the key, pattern or regexp that marks key as
+// sensitive, if any.
+func (m *masker) sensitiveRule(key string) (string, bool) {
+	lower := strings.ToLower(key)
+	if m.keys[lower] {
+		return lower, true
+	}
+	for _, pattern := range m.patterns {
+		if ok, _ := path.Match(pattern, lower); ok {
+			return pattern, true
+		}
+	}
+	for _, re := range m.regexps {
+		if re.MatchString(key) {
+			return re.String(), true
+		}
+	}
+	return "", false
+}
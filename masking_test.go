@@ -0,0 +1,190 @@
+package golog
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaskingPolicyKeys(t *testing.T) {
+	m, err := newMasker(MaskingPolicy{
+		Keys:        []string{"PIN"},
+		KeyPatterns: []string{"*_secret"},
+		KeyRegexps:  []string{"^(?i)x-.*-key$"},
+	})
+	require.NoError(t, err)
+
+	masked := m.maskField(map[string]interface{}{
+		"pin":           "1234",
+		"client_SECRET": "s",
+		"X-Api-Key":     "k",
+		"password":      "not in this policy",
+		"list":          []interface{}{map[string]interface{}{"Pin": "5678"}},
+		"tokens":        map[string]interface{}{"pin": []interface{}{"1", "2"}},
+	})
+
+	assert.Equal(t, map[string]interface{}{
+		"pin":           "*****",
+		"client_SECRET": "*****",
+		"X-Api-Key":     "*****",
+		"password":      "not in this policy",
+		"list":          []interface{}{map[string]interface{}{"Pin": "*****"}},
+		"tokens":        map[string]interface{}{"pin": []interface{}{"*****", "*****"}},
+	}, masked)
+}
+
+func TestMaskingPolicyPaths(t *testing.T) {
+	m, err := newMasker(MaskingPolicy{
+		Paths: []string{"$.card.number", "$.items[*].cvv", "$.owner", "$.phones[0]", "$.*.iban"},
+	})
+	require.NoError(t, err)
+
+	masked := m.maskField([]byte(`{
+		"card": {"number": "4111111111111111", "brand": "visa"},
+		"items": [{"cvv": "123", "sku": "a"}, {"cvv": "456", "sku": "b"}],
+		"owner": {"name": "john", "address": "street"},
+		"phones": ["0811", "0812"],
+		"bank": {"iban": "NL00"},
+		"number": "not a match"
+	}`))
+
+	assert.Equal(t, map[string]interface{}{
+		"card":   map[string]interface{}{"number": "*****", "brand": "visa"},
+		"items":  []interface{}{map[string]interface{}{"cvv": "*****", "sku": "a"}, map[string]interface{}{"cvv": "*****", "sku": "b"}},
+		"owner":  "*****",
+		"phones": []interface{}{"*****", "0812"},
+		"bank":   map[string]interface{}{"iban": "*****"},
+		"number": "not a match",
+	}, masked)
+}
+
+func TestMaskingPolicyRootArrayPaths(t *testing.T) {
+	m, err := newMasker(MaskingPolicy{
+		Paths:      []string{"$[*].card", "$[1].name"},
+		Strategies: map[string]string{"$[*].card": KeepLast(2)},
+	})
+	require.NoError(t, err)
+
+	body := `[{"card": "4111", "name": "john"}, {"card": "4222", "name": "jane"}]`
+	want := []interface{}{
+		map[string]interface{}{"card": "**11", "name": "john"},
+		map[string]interface{}{"card": "**22", "name": "*****"},
+	}
+	assert.Equal(t, want, m.maskField([]byte(body)))
+
+	type item struct {
+		Card string `json:"card"`
+		Name string `json:"name"`
+	}
+	assert.Equal(t, want, m.maskField([]item{{"4111", "john"}, {"4222", "jane"}}))
+}
+
+func TestMaskingPolicyHeaders(t *testing.T) {
+	m, err := newMasker(MaskingPolicy{Headers: []string{"x-session"}})
+	require.NoError(t, err)
+
+	header := http.Header{}
+	header.Set("X-Session", "abc")
+	header.Set("Authorization", "Bearer token")
+
+	result := m.removeAuth(header).(http.Header)
+	assert.Empty(t, result.Get("X-Session"))
+	assert.Equal(t, "Bearer token", result.Get("Authorization"))
+}
+
+func TestMaskingPolicyValidate(t *testing.T) {
+	assert.NoError(t, DefaultMaskingPolicy().Validate())
+
+	err := MaskingPolicy{
+		KeyPatterns: []string{"[a-"},
+		KeyRegexps:  []string{"("},
+		Paths:       []string{"card.number", "$.items[x]", "$.a..b"},
+	}.Validate()
+	require.Error(t, err)
+	for _, bad := range []string{`"[a-"`, `"("`, `"card.number"`, `"$.items[x]"`, `"$.a..b"`} {
+		assert.Contains(t, err.Error(), bad)
+	}
+}
+
+func TestMaskingPolicyPerLogger(t *testing.T) {
+	tmpDir := t.TempDir()
+	strictDir := filepath.Join(tmpDir, "strict")
+
+	base := Config{
+		App:          "testapp",
+		AppVer:       "1.0.0",
+		Env:          "development",
+		FileLocation: tmpDir,
+		FileMaxSize:  10,
+	}
+	strict := base
+	strict.FileLocation = strictDir
+	strict.Masking = &MaskingPolicy{Keys: []string{"password", "username"}}
+
+	defaultLogger := NewLogger(base)
+	strictLogger := NewLogger(strict)
+
+	body := map[string]interface{}{"username": "john", "password": "secret"}
+	defaultLogger.TDR(LogModel{Request: body})
+	strictLogger.TDR(LogModel{Request: body})
+	require.NoError(t, defaultLogger.Sync())
+	require.NoError(t, strictLogger.Sync())
+
+	lines := readLogLines(t, filepath.Join(tmpDir, "tdr.log"))
+	require.Len(t, lines, 1)
+	assert.Equal(t, map[string]interface{}{"username": "john", "password": "*****"}, lines[0]["request"])
+
+	lines = readLogLines(t, filepath.Join(strictDir, "tdr.log"))
+	require.Len(t, lines, 1)
+	assert.Equal(t, map[string]interface{}{"username": "*****", "password": "*****"}, lines[0]["request"])
+}
+
+func TestDefaultMaskingPolicyFollowsSensitiveAttr(t *testing.T) {
+	SENSITIVE_ATTR["pin"] = true
+	defer delete(SENSITIVE_ATTR, "pin")
+
+	masked := policyMasker(nil).maskField(map[string]interface{}{"pin": "1234"})
+	assert.Equal(t, map[string]interface{}{"pin": "*****"}, masked)
+}
+
+func TestTDRMasksNestedSecret(t *testing.T) {
+	tmpDir := t.TempDir()
+	policy := DefaultMaskingPolicy()
+	policy.Keys = append(policy.Keys, "card")
+	policy.Strategies = map[string]string{"card": KeepLast(4)}
+	logger := NewLogger(Config{
+		App:          "testapp",
+		AppVer:       "1.0.0",
+		Env:          "development",
+		FileLocation: tmpDir,
+		FileMaxSize:  10,
+		Masking:      &policy,
+	})
+
+	type secret struct {
+		V string `json:"v"`
+	}
+	logger.TDR(LogModel{
+		Request: []byte(`{"password":{"v":"secret","list":["a",{"w":"b"}]},"card":{"number":"4111111111111111"}}`),
+		Response: struct {
+			Password secret `json:"password"`
+		}{Password: secret{V: "secret"}},
+	})
+	require.NoError(t, logger.Sync())
+
+	lines := readLogLines(t, filepath.Join(tmpDir, "tdr.log"))
+	require.Len(t, lines, 1)
+	assert.Equal(t, map[string]interface{}{
+		"password": map[string]interface{}{
+			"v":    "*****",
+			"list": []interface{}{"*****", map[string]interface{}{"w": "*****"}},
+		},
+		"card": map[string]interface{}{"number": "************1111"},
+	}, lines[0]["request"])
+	assert.Equal(t, map[string]interface{}{
+		"password": map[string]interface{}{"v": "*****"},
+	}, lines[0]["response"])
+}
@@ -1,6 +1,9 @@
 package golog
 
 import (
+	"errors"
+	"fmt"
+	"os"
 	"time"
 
 	"go.uber.org/zap/zapcore"
@@ -32,9 +35,11 @@ type Config struct {
 	AppVer string `json:"appVer"`
 
 	// Log environment (development or production)
+	// If empty, defaults to development
 	Env string `json:"env"`
 
 	// Location where the system log will be saved
+	// May be empty when Sinks is set, to write no system.log or tdr.log.
 	FileLocation string `json:"fileLocation"`
 
 	// Location where the tdr log will be saved
@@ -56,22 +61,137 @@ type Config struct {
 	Stdout bool `json:"stdout"`
 
 	// Log level (debug, info, warn, error). Defaults to info if not set.
+	// Written as a string such as "debug" in JSON and YAML.
 	LogLevel zapcore.Level `json:"logLevel"`
 
+	// Levels of the loggers returned by Named, keyed by component name,
+	// e.g. {"payment": "debug", "db": "warn"}. A name applies to its own
+	// component and to nested ones such as "payment.stripe"; the longest
+	// matching name wins. Other components use LogLevel.
+	ComponentLevels map[string]zapcore.Level `json:"componentLevels"`
+
 	// Path to version file. If empty, defaults to "version.txt".
 	// If set and file exists, will override AppVer.
 	VersionFilePath string `json:"versionFilePath"`
+
+	// Sensitive header and body fields masked in TDR records.
+	// If nil, DefaultMaskingPolicy is used.
+	Masking *MaskingPolicy `json:"masking"`
+
+	// Size limits and binary content types for TDR request and response
+	// bodies. If nil, the defaults described on BodyLimits are used.
+	BodyLimits *BodyLimits `json:"bodyLimits"`
+
+	// Additional outputs of the system and TDR streams, each with its own
+	// destination, encoding and level. See SinkConfig.
+	Sinks []SinkConfig `json:"sinks"`
+
+	// Write entries from background goroutines through a bounded buffer,
+	// so a slow disk does not add latency to logging calls. If nil, entries
+	// are written synchronously.
+	Async *AsyncConfig `json:"async"`
+
+	// Cap the rate of repeated system log entries. If nil, every entry is
+	// logged. See SamplingConfig.
+	Sampling *SamplingConfig `json:"sampling"`
+
+	// Record Error, Fatal and Panic entries as events on the active
+	// OpenTelemetry span, if any.
+	SpanEvents bool `json:"spanEvents"`
+
+	// Additional context extractors for this logger, keyed by name.
+	// They run after the ones added with RegisterContextExtractor and
+	// shadow a registered extractor with the same name.
+	ContextExtractors map[string]ContextExtractor `json:"-"`
 }
 
-// Validate validates the Config and sets defaults.
-func (c *Config) Validate() {
-	if c.LogLevel == 0 {
-		c.LogLevel = zapcore.InfoLevel
-	}
+// Validate sets the defaults of unset optional fields and reports invalid
+// settings: missing or non-directory log locations, negative file sizes,
+// unknown environments and levels, and invalid masking, async or sampling
+// settings.
+// NewLogger sets the same defaults but does not reject invalid configs, so
+// call Validate (or use LoadConfigFromFile or LoadConfigFromEnv) first.
+func (c *Config) Validate() error {
 	if c.VersionFilePath == "" {
 		c.VersionFilePath = "version.txt"
 	}
 	if c.FileTDRLocation == "" {
 		c.FileTDRLocation = c.FileLocation
 	}
+	if c.Env == "" {
+		c.Env = "development"
+	}
+
+	var errs []error
+	switch c.Env {
+	case "development", "production":
+	default:
+		errs = append(errs, fmt.Errorf("golog: unknown env %q, want \"development\" or \"production\"", c.Env))
+	}
+
+	if c.FileLocation == "" && len(c.Sinks) == 0 {
+		errs = append(errs, errors.New("golog: fileLocation is required without sinks"))
+	}
+	for _, dir := range []string{c.FileLocation, c.FileTDRLocation} {
+		if info, err := os.Stat(dir); dir != "" && err == nil && !info.IsDir() {
+			errs = append(errs, fmt.Errorf("golog: log location %s is not a directory", dir))
+		}
+	}
+	if info, err := os.Stat(c.VersionFilePath); err == nil && info.IsDir() {
+		errs = append(errs, fmt.Errorf("golog: version file %s is a directory", c.VersionFilePath))
+	}
+
+	if c.FileMaxSize < 0 {
+		errs = append(errs, fmt.Errorf("golog: negative fileMaxSize %d", c.FileMaxSize))
+	}
+	if c.FileMaxBackup < 0 {
+		errs = append(errs, fmt.Errorf("golog: negative fileMaxBackup %d", c.FileMaxBackup))
+	}
+	if c.FileMaxAge < 0 {
+		errs = append(errs, fmt.Errorf("golog: negative fileMaxAge %d", c.FileMaxAge))
+	}
+
+	if !validLevel(c.LogLevel) {
+		errs = append(errs, fmt.Errorf("golog: invalid logLevel %d", c.LogLevel))
+	}
+	for name, level := range c.ComponentLevels {
+		if name == "" {
+			errs = append(errs, errors.New("golog: empty component name in componentLevels"))
+		}
+		if !validLevel(level) {
+			errs = append(errs, fmt.Errorf("golog: invalid level %d for component %q", level, name))
+		}
+	}
+
+	names := make(map[string]bool, len(c.Sinks))
+	for _, sink := range c.Sinks {
+		if err := sink.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+		if names[sink.Name] {
+			errs = append(errs, fmt.Errorf("golog: duplicate sink name %q", sink.Name))
+		}
+		names[sink.Name] = true
+	}
+
+	if c.Masking != nil {
+		if err := c.Masking.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.Async != nil {
+		if err := c.Async.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.Sampling != nil {
+		if err := c.Sampling.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func validLevel(level zapcore.Level) bool {
+	return level >= zapcore.DebugLevel && level <= zapcore.FatalLevel
 }
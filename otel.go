@@ -0,0 +1,76 @@
+package golog
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// SpanIDField is the field name for the OpenTelemetry span ID
+	SpanIDField = "spanId"
+	// TraceFlagsField is the field name for the OpenTelemetry trace flags
+	TraceFlagsField = "traceFlags"
+)
+
+// traceIDExtractor emits the trace ID set with WithTraceID and falls back to
+// the trace ID of the active OpenTelemetry span.
+func traceIDExtractor(ctx context.Context) (zap.Field, bool) {
+	if v, ok := GetTraceID(ctx); ok {
+		return zap.String(TraceIDKey.String(), v), true
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		return zap.String(TraceIDKey.String(), sc.TraceID().String()), true
+	}
+	return zap.Field{}, false
+}
+
+// spanIDExtractor emits the span ID of the active OpenTelemetry span.
+func spanIDExtractor(ctx context.Context) (zap.Field, bool) {
+	if sc, ok := loggedSpanContext(ctx); ok && sc.HasSpanID() {
+		return zap.String(SpanIDField, sc.SpanID().String()), true
+	}
+	return zap.Field{}, false
+}
+
+// traceFlagsExtractor emits the W3C trace flags of the active OpenTelemetry
+// span as two hex digits, e.g. "01" for a sampled span.
+func traceFlagsExtractor(ctx context.Context) (zap.Field, bool) {
+	if sc, ok := loggedSpanContext(ctx); ok && sc.IsValid() {
+		return zap.String(TraceFlagsField, sc.TraceFlags().String()), true
+	}
+	return zap.Field{}, false
+}
+
+// loggedSpanContext returns the span context of the active OpenTelemetry
+// span, unless a trace ID set with WithTraceID belongs to another trace:
+// the span ID and flags of a span are only logged next to its own trace ID.
+func loggedSpanContext(ctx context.Context) (trace.SpanContext, bool) {
+	sc := trace.SpanContextFromContext(ctx)
+	if v, ok := GetTraceID(ctx); ok && v != sc.TraceID().String() {
+		return trace.SpanContext{}, false
+	}
+	return sc, true
+}
+
+// recordSpanEvent adds the log entry as an event on the span active in ctx.
+// A non-nil err is recorded as an exception event.
+func recordSpanEvent(ctx context.Context, level zapcore.Level, msg string, err error) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	attrs := trace.WithAttributes(
+		attribute.String("log.severity", level.CapitalString()),
+		attribute.String("log.message", msg),
+	)
+	if err != nil {
+		span.RecordError(err, attrs)
+		return
+	}
+	span.AddEvent("log", attrs)
+}
@@ -0,0 +1,106 @@
+package golog
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func newTestTracer(t *testing.T) (*tracetest.SpanRecorder, *sdktrace.TracerProvider) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+	return recorder, provider
+}
+
+func TestOTelSpanCorrelation(t *testing.T) {
+	_, provider := newTestTracer(t)
+	ctx, span := provider.Tracer("test").Start(context.Background(), "op")
+	defer span.End()
+
+	tmpDir := t.TempDir()
+	logger := NewLogger(Config{
+		App:          "testapp",
+		AppVer:       "1.0.0",
+		Env:          "development",
+		FileLocation: tmpDir,
+		FileMaxSize:  10,
+	})
+
+	logger.WithContext(ctx).Info("inside span")
+	logger.WithContext(ctx).TDR(LogModel{Method: "GET"})
+	require.NoError(t, logger.Sync())
+
+	sc := span.SpanContext()
+	for _, file := range []string{"system.log", "tdr.log"} {
+		lines := readLogLines(t, filepath.Join(tmpDir, file))
+		require.Len(t, lines, 1)
+		assert.Equal(t, sc.TraceID().String(), lines[0]["traceId"])
+		assert.Equal(t, sc.SpanID().String(), lines[0]["spanId"])
+		assert.Equal(t, "01", lines[0]["traceFlags"])
+	}
+}
+
+func TestOTelExplicitTraceIDWins(t *testing.T) {
+	_, provider := newTestTracer(t)
+	ctx, span := provider.Tracer("test").Start(context.Background(), "op")
+	defer span.End()
+
+	ctx = WithTraceID(ctx, "trace-123")
+	fields := populateFieldFromContext(ctx)
+
+	values := make(map[string]string, len(fields))
+	for _, f := range fields {
+		values[f.Key] = f.String
+	}
+	assert.Equal(t, "trace-123", values["traceId"])
+	assert.NotContains(t, values, "spanId", "the span belongs to another trace")
+	assert.NotContains(t, values, "traceFlags")
+
+	// the same trace ID set explicitly keeps the span
+	ctx = WithTraceID(ctx, span.SpanContext().TraceID().String())
+	values = make(map[string]string)
+	for _, f := range populateFieldFromContext(ctx) {
+		values[f.Key] = f.String
+	}
+	assert.Equal(t, span.SpanContext().SpanID().String(), values["spanId"])
+	assert.Equal(t, "01", values["traceFlags"])
+}
+
+func TestOTelSpanEvents(t *testing.T) {
+	recorder, provider := newTestTracer(t)
+	ctx, span := provider.Tracer("test").Start(context.Background(), "op")
+
+	logger := NewLogger(Config{
+		App:          "testapp",
+		AppVer:       "1.0.0",
+		Env:          "development",
+		FileLocation: t.TempDir(),
+		FileMaxSize:  10,
+		SpanEvents:   true,
+	})
+
+	logger.WithContext(ctx).Info("not recorded")
+	logger.WithContext(ctx).Error("query failed", errors.New("timeout"))
+	span.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	events := spans[0].Events()
+	require.Len(t, events, 1)
+	assert.Equal(t, "exception", events[0].Name)
+
+	attrs := make(map[string]string)
+	for _, kv := range events[0].Attributes {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	assert.Equal(t, "ERROR", attrs["log.severity"])
+	assert.Equal(t, "query failed", attrs["log.message"])
+	assert.Equal(t, "timeout", attrs["exception.message"])
+}
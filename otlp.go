@@ -0,0 +1,558 @@
+package golog
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/protobuf/proto"
+)
+
+// SinkOTLP exports entries as OpenTelemetry log records. See OTLPConfig.
+const SinkOTLP = "otlp"
+
+// Protocols for OTLPConfig.Protocol.
+const (
+	// OTLPGRPC calls the LogsService of the collector over gRPC.
+	OTLPGRPC = "grpc"
+	// OTLPHTTP posts protobuf requests to the /v1/logs endpoint.
+	OTLPHTTP = "http/protobuf"
+)
+
+// Defaults for OTLPConfig, those of the OpenTelemetry SDK.
+const (
+	DefaultOTLPTimeout              = 10000
+	DefaultOTLPMaxQueueSize         = 2048
+	DefaultOTLPMaxExportBatchSize   = 512
+	DefaultOTLPExportInterval       = 1000
+	DefaultOTLPRetryInitialInterval = 5000
+	DefaultOTLPRetryMaxInterval     = 30000
+	DefaultOTLPRetryMaxElapsedTime  = 60000
+)
+
+// OTLPConfig configures a SinkOTLP sink. SinkConfig.Address is the
+// collector: host:port for OTLPGRPC, e.g. "otel-collector:4317", and the
+// URL for OTLPHTTP, e.g. "http://otel-collector:4318", to which /v1/logs is
+// added if it has no path.
+//
+// Every entry becomes a log record of the resource described by its app,
+// appVer and env fields, as service.name, service.version and
+// deployment.environment.name. The message is the body, the level the
+// severity, and the traceId, spanId and traceFlags fields taken from the
+// context fill the trace context of the record; the other fields, and the
+// stream, are attributes.
+//
+// The sink works like the batch processor and the OTLP exporters of the
+// OpenTelemetry SDK. Records are queued in memory, up to MaxQueueSize,
+// dropping the oldest when the queue is full, and exported from a
+// background goroutine every ExportInterval, or as soon as MaxExportBatchSize
+// records are queued, in requests of at most MaxExportBatchSize records.
+// Requests that fail with a retryable gRPC code, or a 429, 502, 503 or 504
+// response, are retried with exponential backoff and jitter, waiting at
+// least as long as the collector asks to, until RetryMaxElapsedTime or
+// Timeout has passed. Sync exports the queue and returns the failures, the
+// records rejected by the collector and the records dropped since the
+// previous Sync.
+type OTLPConfig struct {
+	// Protocol: OTLPGRPC (default) or OTLPHTTP.
+	Protocol string `json:"protocol"`
+
+	// Headers added to every request, as gRPC metadata for OTLPGRPC, e.g.
+	// Authorization.
+	Headers map[string]string `json:"headers"`
+
+	// Compress requests with gzip.
+	Gzip bool `json:"gzip"`
+
+	// Connect to an OTLPGRPC collector without TLS. An http:// address
+	// implies it; OTLPHTTP uses TLS for https URLs only.
+	Insecure bool `json:"insecure"`
+
+	// TLS settings of the connection. Defaults to the system roots.
+	TLSConfig *tls.Config `json:"-"`
+
+	// Milliseconds an export may take, retries included. Defaults to
+	// DefaultOTLPTimeout.
+	Timeout int `json:"timeout"`
+
+	// Records queued in memory. Defaults to DefaultOTLPMaxQueueSize.
+	MaxQueueSize int `json:"maxQueueSize"`
+
+	// Records per request, at most MaxQueueSize. Defaults to
+	// DefaultOTLPMaxExportBatchSize.
+	MaxExportBatchSize int `json:"maxExportBatchSize"`
+
+	// Milliseconds between exports of the queue. Defaults to
+	// DefaultOTLPExportInterval.
+	ExportInterval int `json:"exportInterval"`
+
+	// Milliseconds before the first retry, raised by half on every retry up
+	// to RetryMaxInterval, and randomized by half. Default to
+	// DefaultOTLPRetryInitialInterval and DefaultOTLPRetryMaxInterval.
+	RetryInitialInterval int `json:"retryInitialInterval"`
+	RetryMaxInterval     int `json:"retryMaxInterval"`
+
+	// Milliseconds after which a failed request is no longer retried.
+	// Defaults to DefaultOTLPRetryMaxElapsedTime; a negative value
+	// disables retries.
+	RetryMaxElapsedTime int `json:"retryMaxElapsedTime"`
+}
+
+// withDefaults returns c with the defaults of unset fields.
+func (c OTLPConfig) withDefaults() OTLPConfig {
+	if c.Protocol == "" {
+		c.Protocol = OTLPGRPC
+	}
+	if c.Timeout == 0 {
+		c.Timeout = DefaultOTLPTimeout
+	}
+	if c.MaxQueueSize == 0 {
+		c.MaxQueueSize = DefaultOTLPMaxQueueSize
+	}
+	if c.MaxExportBatchSize == 0 {
+		c.MaxExportBatchSize = DefaultOTLPMaxExportBatchSize
+	}
+	if c.MaxExportBatchSize > c.MaxQueueSize {
+		c.MaxExportBatchSize = c.MaxQueueSize
+	}
+	if c.ExportInterval == 0 {
+		c.ExportInterval = DefaultOTLPExportInterval
+	}
+	if c.RetryInitialInterval == 0 {
+		c.RetryInitialInterval = DefaultOTLPRetryInitialInterval
+	}
+	if c.RetryMaxInterval == 0 {
+		c.RetryMaxInterval = DefaultOTLPRetryMaxInterval
+	}
+	if c.RetryMaxInterval < c.RetryInitialInterval {
+		c.RetryMaxInterval = c.RetryInitialInterval
+	}
+	if c.RetryMaxElapsedTime == 0 {
+		c.RetryMaxElapsedTime = DefaultOTLPRetryMaxElapsedTime
+	}
+	return c
+}
+
+func validateOTLPSink(s SinkConfig) error {
+	var c OTLPConfig
+	if s.OTLP != nil {
+		c = *s.OTLP
+	}
+
+	var errs []error
+	if s.Encoding == EncodingConsole {
+		errs = append(errs, fmt.Errorf("golog: sink %q: otlp records take no console encoding", s.Name))
+	}
+	switch c.Protocol {
+	case "", OTLPGRPC:
+		if _, _, err := otlpGRPCTarget(s.Address); err != nil {
+			errs = append(errs, fmt.Errorf("golog: sink %q: %w", s.Name, err))
+		}
+	case OTLPHTTP:
+		if u, err := url.Parse(s.Address); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("golog: sink %q: %q is not an http or https URL", s.Name, s.Address))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("golog: sink %q: unknown otlp protocol %q", s.Name, c.Protocol))
+	}
+	for name, value := range map[string]int{
+		"timeout": c.Timeout, "maxQueueSize": c.MaxQueueSize, "maxExportBatchSize": c.MaxExportBatchSize,
+		"exportInterval": c.ExportInterval, "retryInitialInterval": c.RetryInitialInterval,
+		"retryMaxInterval": c.RetryMaxInterval,
+	} {
+		if value < 0 {
+			errs = append(errs, fmt.Errorf("golog: sink %q: negative %s %d", s.Name, name, value))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func openOTLPSink(s SinkConfig, _ Config) sinkOutput {
+	var c OTLPConfig
+	if s.OTLP != nil {
+		c = *s.OTLP
+	}
+	c = c.withDefaults()
+
+	stream := s.Stream
+	if stream == "" {
+		stream = StreamSystem
+	}
+	var exporter otlpExporter
+	if c.Protocol == OTLPHTTP {
+		exporter = newOTLPHTTPExporter(s.Address, c)
+	} else {
+		exporter = newOTLPGRPCExporter(s.Address, c)
+	}
+	w := newOTLPWriter(exporter, c)
+	return sinkOutput{
+		writer: w,
+		encoder: func(encoderConfig zapcore.EncoderConfig) zapcore.Encoder {
+			// the time, level and message are fields of the record itself
+			encoderConfig.TimeKey = zapcore.OmitKey
+			encoderConfig.LevelKey = zapcore.OmitKey
+			encoderConfig.MessageKey = zapcore.OmitKey
+			encoderConfig.CallerKey = zapcore.OmitKey
+			encoderConfig.FunctionKey = zapcore.OmitKey
+			return &otlpEncoder{Encoder: zapcore.NewJSONEncoder(encoderConfig), stream: stream}
+		},
+		closer: w,
+	}
+}
+
+// otlpSeverity maps a level to a severity number, as the OpenTelemetry zap
+// bridge does.
+func otlpSeverity(level zapcore.Level) logspb.SeverityNumber {
+	switch level {
+	case zapcore.DebugLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG
+	case zapcore.InfoLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_INFO
+	case zapcore.WarnLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_WARN
+	case zapcore.ErrorLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_ERROR
+	case zapcore.DPanicLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_FATAL
+	case zapcore.PanicLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_FATAL2
+	case zapcore.FatalLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_FATAL3
+	default:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED
+	}
+}
+
+var otlpPool = buffer.NewPool()
+
+// otlpEncoder encodes each entry as a ResourceLogs holding its resource and
+// its log record. The embedded encoder encodes the fields only, which
+// become the resource and the attributes.
+type otlpEncoder struct {
+	zapcore.Encoder
+	stream string
+}
+
+func (e *otlpEncoder) Clone() zapcore.Encoder {
+	return &otlpEncoder{Encoder: e.Encoder.Clone(), stream: e.stream}
+}
+
+func (e *otlpEncoder) EncodeEntry(entry zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	msg, err := e.Encoder.EncodeEntry(entry, fields)
+	if err != nil {
+		return nil, err
+	}
+	defer msg.Free()
+
+	var values map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(msg.Bytes()))
+	dec.UseNumber()
+	if err := dec.Decode(&values); err != nil {
+		return nil, err
+	}
+
+	resource := &resourcepb.Resource{}
+	for _, attr := range [][2]string{
+		{"app", "service.name"}, {"appVer", "service.version"}, {"env", "deployment.environment.name"},
+	} {
+		if v, ok := values[attr[0]].(string); ok {
+			if v != "" {
+				resource.Attributes = append(resource.Attributes, otlpKeyValue(attr[1], v))
+			}
+			delete(values, attr[0])
+		}
+	}
+
+	record := &logspb.LogRecord{
+		TimeUnixNano:         uint64(entry.Time.UnixNano()),
+		ObservedTimeUnixNano: uint64(time.Now().UnixNano()),
+		SeverityNumber:       otlpSeverity(entry.Level),
+		SeverityText:         entry.Level.CapitalString(),
+		Body:                 otlpAnyValue(entry.Message),
+	}
+
+	// IDs that are not OpenTelemetry ones, as set with WithTraceID, stay
+	// attributes
+	if id, ok := otlpID(values[TraceIDKey.String()], 16); ok {
+		record.TraceId = id
+		delete(values, TraceIDKey.String())
+	}
+	if id, ok := otlpID(values[SpanIDField], 8); ok {
+		record.SpanId = id
+		delete(values, SpanIDField)
+	}
+	if flags, ok := otlpID(values[TraceFlagsField], 1); ok {
+		record.Flags = uint32(flags[0])
+		delete(values, TraceFlagsField)
+	}
+
+	if _, ok := values["stream"]; !ok {
+		values["stream"] = e.stream
+	}
+	record.Attributes = otlpKeyValues(values)
+
+	b, err := proto.Marshal(&logspb.ResourceLogs{
+		Resource:  resource,
+		ScopeLogs: []*logspb.ScopeLogs{{LogRecords: []*logspb.LogRecord{record}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	buf := otlpPool.Get()
+	_, _ = buf.Write(b)
+	return buf, nil
+}
+
+// otlpID decodes a hex field of size bytes, reporting false for other
+// values and for IDs of zeros, which are invalid.
+func otlpID(value interface{}, size int) ([]byte, bool) {
+	s, ok := value.(string)
+	if !ok || len(s) != 2*size {
+		return nil, false
+	}
+	id, err := hex.DecodeString(s)
+	if err != nil || (size > 1 && bytes.Count(id, []byte{0}) == size) {
+		return nil, false
+	}
+	return id, true
+}
+
+// otlpRecord is a log record and its resource.
+type otlpRecord struct {
+	resource *resourcepb.Resource
+	record   *logspb.LogRecord
+}
+
+// parseOTLPEntry decodes an entry written by an otlpEncoder.
+func parseOTLPEntry(p []byte) (otlpRecord, error) {
+	var logs logspb.ResourceLogs
+	if err := proto.Unmarshal(p, &logs); err != nil ||
+		len(logs.ScopeLogs) != 1 || len(logs.ScopeLogs[0].LogRecords) != 1 {
+		return otlpRecord{}, errors.New("golog: malformed otlp sink entry")
+	}
+	return otlpRecord{resource: logs.Resource, record: logs.ScopeLogs[0].LogRecords[0]}, nil
+}
+
+// otlpWriter queues records, exported by a background goroutine.
+type otlpWriter struct {
+	exporter otlpExporter
+	conf     OTLPConfig
+
+	mu    sync.Mutex
+	queue []otlpRecord
+	// queued and handled count records, so Sync can wait for the records
+	// queued before it was called; dropped records count as handled
+	queued      uint64
+	handled     uint64
+	handledCond *sync.Cond
+	dropped     int
+	err         error
+	closed      bool
+
+	// kick asks for the full batches to be exported, flush for every
+	// queued record
+	kick  chan struct{}
+	flush chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+func newOTLPWriter(exporter otlpExporter, conf OTLPConfig) *otlpWriter {
+	w := &otlpWriter{
+		exporter: exporter,
+		conf:     conf,
+		kick:     make(chan struct{}, 1),
+		flush:    make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	w.handledCond = sync.NewCond(&w.mu)
+	go w.run()
+	return w
+}
+
+// Write queues a record, dropping the oldest one if the queue is full.
+func (w *otlpWriter) Write(p []byte) (int, error) {
+	r, err := parseOTLPEntry(p)
+	if err != nil {
+		return 0, err
+	}
+
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return 0, errors.New("golog: write to closed otlp sink")
+	}
+	if len(w.queue) >= w.conf.MaxQueueSize {
+		w.queue[0] = otlpRecord{}
+		w.queue = w.queue[1:]
+		w.dropped++
+		w.handled++
+		w.handledCond.Broadcast()
+	}
+	w.queue = append(w.queue, r)
+	w.queued++
+	full := len(w.queue) >= w.conf.MaxExportBatchSize
+	w.mu.Unlock()
+
+	if full {
+		select {
+		case w.kick <- struct{}{}:
+		default:
+		}
+	}
+	return len(p), nil
+}
+
+// run exports the queue until the writer is closed.
+func (w *otlpWriter) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(time.Duration(w.conf.ExportInterval) * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		all := true
+		select {
+		case <-w.kick:
+			all = false
+		case <-w.flush:
+		case <-ticker.C:
+		case <-w.stop:
+			w.export(true)
+			return
+		}
+		w.export(all)
+	}
+}
+
+// export sends the full batches of the queue, and the partial one too if
+// all is set.
+func (w *otlpWriter) export(all bool) {
+	for {
+		w.mu.Lock()
+		n := min(len(w.queue), w.conf.MaxExportBatchSize)
+		if n == 0 || (n < w.conf.MaxExportBatchSize && !all) {
+			w.mu.Unlock()
+			return
+		}
+		batch := w.queue[:n:n]
+		w.queue = w.queue[n:]
+		w.mu.Unlock()
+
+		err := w.send(otlpRequest(batch))
+
+		w.mu.Lock()
+		w.handled += uint64(n)
+		if err != nil {
+			w.err = errors.Join(w.err, fmt.Errorf("golog: otlp sink dropped %d records: %w", n, err))
+		}
+		w.handledCond.Broadcast()
+		w.mu.Unlock()
+	}
+}
+
+// send exports one request, retrying it like the OTLP exporters of the
+// OpenTelemetry SDK.
+func (w *otlpWriter) send(request *collogspb.ExportLogsServiceRequest) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(w.conf.Timeout)*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	interval := time.Duration(w.conf.RetryInitialInterval) * time.Millisecond
+	maxInterval := time.Duration(w.conf.RetryMaxInterval) * time.Millisecond
+	maxElapsed := time.Duration(w.conf.RetryMaxElapsedTime) * time.Millisecond
+	for {
+		err := w.exporter.export(ctx, request)
+		var exportErr *otlpError
+		if err == nil || w.conf.RetryMaxElapsedTime < 0 || !errors.As(err, &exportErr) || !exportErr.retry {
+			return err
+		}
+
+		delay := time.Duration((0.5 + rand.Float64()) * float64(interval))
+		interval = min(time.Duration(float64(interval)*1.5), maxInterval)
+		delay = max(delay, exportErr.throttle)
+		if time.Since(start)+delay > maxElapsed {
+			return err
+		}
+		t := time.NewTimer(delay)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-w.stop:
+			t.Stop()
+			return err
+		}
+	}
+}
+
+// Sync exports the queued records and waits until every record queued
+// before the call is exported or dropped. It returns the failures seen
+// since the previous Sync.
+func (w *otlpWriter) Sync() error {
+	select {
+	case w.flush <- struct{}{}:
+	default:
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	target := w.queued
+	for w.handled < target && !w.closed {
+		w.handledCond.Wait()
+	}
+	return w.takeErr()
+}
+
+// takeErr returns and resets the failures and drops seen so far. w.mu must
+// be held.
+func (w *otlpWriter) takeErr() error {
+	err := w.err
+	if w.dropped > 0 {
+		err = errors.Join(err, fmt.Errorf("golog: otlp sink queue full, dropped %d records", w.dropped))
+	}
+	w.err = nil
+	w.dropped = 0
+	return err
+}
+
+// Close exports the queued records without waiting for retries and stops
+// the background goroutine.
+func (w *otlpWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.handledCond.Broadcast()
+	w.mu.Unlock()
+
+	close(w.stop)
+	<-w.done
+
+	w.mu.Lock()
+	err := w.takeErr()
+	w.mu.Unlock()
+	return errors.Join(err, w.exporter.close())
+}
+
+// writesEntries keeps async writes from merging entries, which are each
+// one record.
+func (w *otlpWriter) writesEntries() bool {
+	return true
+}
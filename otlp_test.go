@@ -0,0 +1,512 @@
+package golog
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/hex"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// logsCollector is an in-process LogsService. respond, if set, answers the
+// nth request, counted from 1.
+type logsCollector struct {
+	collogspb.UnimplementedLogsServiceServer
+	addr    string
+	respond func(n int) (*collogspb.ExportLogsServiceResponse, error)
+
+	mu       sync.Mutex
+	requests []*collogspb.ExportLogsServiceRequest
+	metadata []metadata.MD
+	attempts int
+}
+
+func newLogsCollector(t *testing.T) *logsCollector {
+	t.Helper()
+	c := &logsCollector{}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	c.addr = lis.Addr().String()
+
+	server := grpc.NewServer()
+	collogspb.RegisterLogsServiceServer(server, c)
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+	return c
+}
+
+func (c *logsCollector) Export(ctx context.Context, request *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	c.mu.Lock()
+	c.attempts++
+	n := c.attempts
+	c.mu.Unlock()
+	response := &collogspb.ExportLogsServiceResponse{}
+	if c.respond != nil {
+		var err error
+		if response, err = c.respond(n); err != nil {
+			return nil, err
+		}
+	}
+	c.record(request, md)
+	return response, nil
+}
+
+func (c *logsCollector) record(request *collogspb.ExportLogsServiceRequest, md metadata.MD) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests = append(c.requests, request)
+	c.metadata = append(c.metadata, md)
+}
+
+func (c *logsCollector) attemptCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.attempts
+}
+
+func (c *logsCollector) received(t *testing.T) [][]otlpTestRecord {
+	t.Helper()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var requests [][]otlpTestRecord
+	for _, request := range c.requests {
+		requests = append(requests, decodeOTLPRequest(t, request))
+	}
+	return requests
+}
+
+// otlpTestRecord is a decoded LogRecord, with its resource and scope.
+type otlpTestRecord struct {
+	resource     map[string]interface{}
+	scope        string
+	time         time.Time
+	observed     time.Time
+	severity     uint64
+	severityText string
+	body         interface{}
+	attributes   map[string]interface{}
+	flags        uint64
+	traceID      string
+	spanID       string
+}
+
+// unmarshalOTLPRequest decodes the body of an OTLP/HTTP request.
+func unmarshalOTLPRequest(t *testing.T, data []byte) *collogspb.ExportLogsServiceRequest {
+	t.Helper()
+	var request collogspb.ExportLogsServiceRequest
+	require.NoError(t, proto.Unmarshal(data, &request))
+	return &request
+}
+
+func decodeOTLPRequest(t *testing.T, request *collogspb.ExportLogsServiceRequest) []otlpTestRecord {
+	t.Helper()
+	var records []otlpTestRecord
+	for _, resourceLogs := range request.GetResourceLogs() {
+		resource := decodeOTLPKeyValues(resourceLogs.GetResource().GetAttributes())
+		for _, scopeLogs := range resourceLogs.GetScopeLogs() {
+			for _, record := range scopeLogs.GetLogRecords() {
+				r := decodeOTLPRecord(record)
+				r.resource, r.scope = resource, scopeLogs.GetScope().GetName()
+				records = append(records, r)
+			}
+		}
+	}
+	return records
+}
+
+func decodeOTLPRecord(record *logspb.LogRecord) otlpTestRecord {
+	r := otlpTestRecord{
+		time:         time.Unix(0, int64(record.GetTimeUnixNano())),
+		observed:     time.Unix(0, int64(record.GetObservedTimeUnixNano())),
+		severity:     uint64(record.GetSeverityNumber()),
+		severityText: record.GetSeverityText(),
+		body:         decodeOTLPAnyValue(record.GetBody()),
+		attributes:   decodeOTLPKeyValues(record.GetAttributes()),
+		flags:        uint64(record.GetFlags()),
+	}
+	if id := record.GetTraceId(); len(id) > 0 {
+		r.traceID = hex.EncodeToString(id)
+	}
+	if id := record.GetSpanId(); len(id) > 0 {
+		r.spanID = hex.EncodeToString(id)
+	}
+	return r
+}
+
+func decodeOTLPKeyValues(kvs []*commonpb.KeyValue) map[string]interface{} {
+	values := make(map[string]interface{}, len(kvs))
+	for _, kv := range kvs {
+		values[kv.GetKey()] = decodeOTLPAnyValue(kv.GetValue())
+	}
+	return values
+}
+
+func decodeOTLPAnyValue(value *commonpb.AnyValue) interface{} {
+	switch v := value.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return v.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return v.BoolValue
+	case *commonpb.AnyValue_IntValue:
+		return v.IntValue
+	case *commonpb.AnyValue_DoubleValue:
+		return v.DoubleValue
+	case *commonpb.AnyValue_ArrayValue:
+		items := []interface{}{}
+		for _, item := range v.ArrayValue.GetValues() {
+			items = append(items, decodeOTLPAnyValue(item))
+		}
+		return items
+	case *commonpb.AnyValue_KvlistValue:
+		return decodeOTLPKeyValues(v.KvlistValue.GetValues())
+	default:
+		return nil
+	}
+}
+
+func newOTLPSinkLogger(t *testing.T, address string, stream string, conf *OTLPConfig) *Log {
+	t.Helper()
+	logger := NewLogger(Config{
+		App:    "testapp",
+		AppVer: "1.2.3",
+		Env:    "production",
+		Sinks: []SinkConfig{
+			{Name: "otlp", Stream: stream, Type: SinkOTLP, Address: address, OTLP: conf},
+		},
+	}).(*Log)
+	t.Cleanup(func() { _ = logger.Close() })
+	return logger
+}
+
+func TestOTLPSinkGRPC(t *testing.T) {
+	collector := newLogsCollector(t)
+	logger := newOTLPSinkLogger(t, collector.addr, "", &OTLPConfig{
+		Insecure: true,
+		Gzip:     true,
+		Headers:  map[string]string{"Authorization": "Bearer token"},
+	})
+	_, provider := newTestTracer(t)
+	ctx, span := provider.Tracer("test").Start(context.Background(), "op")
+	defer span.End()
+
+	before := time.Now()
+	logger.WithContext(ctx).Info("hello",
+		zap.Int("count", 3),
+		zap.Bool("ok", true),
+		zap.Float64("ratio", 0.5),
+		zap.Strings("tags", []string{"a", "b"}),
+		zap.Any("user", map[string]string{"id": "u1"}),
+	)
+	logger.Named("db").Warn("careful")
+	require.NoError(t, logger.Sync())
+
+	requests := collector.received(t)
+	require.Len(t, requests, 1)
+	records := requests[0]
+	require.Len(t, records, 2)
+
+	hello := records[0]
+	assert.Equal(t, map[string]interface{}{
+		"service.name":                "testapp",
+		"service.version":             "1.2.3",
+		"deployment.environment.name": "production",
+	}, hello.resource)
+	assert.Equal(t, "github.com/tommynurwantoro/golog", hello.scope)
+	assert.False(t, hello.time.Before(before.Truncate(time.Microsecond)))
+	assert.False(t, hello.observed.Before(hello.time))
+	assert.Equal(t, uint64(9), hello.severity)
+	assert.Equal(t, "INFO", hello.severityText)
+	assert.Equal(t, "hello", hello.body)
+	assert.Equal(t, map[string]interface{}{
+		"count":  int64(3),
+		"ok":     true,
+		"ratio":  0.5,
+		"tags":   []interface{}{"a", "b"},
+		"user":   map[string]interface{}{"id": "u1"},
+		"stream": "system",
+	}, hello.attributes)
+	sc := span.SpanContext()
+	assert.Equal(t, sc.TraceID().String(), hello.traceID)
+	assert.Equal(t, sc.SpanID().String(), hello.spanID)
+	assert.Equal(t, uint64(1), hello.flags)
+
+	careful := records[1]
+	assert.Equal(t, uint64(13), careful.severity)
+	assert.Equal(t, "WARN", careful.severityText)
+	assert.Equal(t, map[string]interface{}{"component": "db", "stream": "system"}, careful.attributes)
+	assert.Empty(t, careful.traceID)
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	assert.Equal(t, []string{"Bearer token"}, collector.metadata[0].Get("authorization"))
+}
+
+func TestOTLPSinkHTTP(t *testing.T) {
+	var mu sync.Mutex
+	var requests []*http.Request
+	var bodies [][]byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			body = zr
+		}
+		data, _ := io.ReadAll(body)
+		mu.Lock()
+		requests = append(requests, r)
+		bodies = append(bodies, data)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/x-protobuf")
+	}))
+	defer server.Close()
+	logger := newOTLPSinkLogger(t, server.URL, StreamTDR, &OTLPConfig{Protocol: OTLPHTTP, Gzip: true})
+
+	ctx := WithTraceID(context.Background(), "trace-123")
+	logger.WithContext(ctx).TDR(LogModel{CorrelationID: "c-1", Method: "GET", HttpStatus: 200})
+	require.NoError(t, logger.Sync())
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "/v1/logs", requests[0].URL.Path)
+	assert.Equal(t, "application/x-protobuf", requests[0].Header.Get("Content-Type"))
+	assert.Equal(t, "gzip", requests[0].Header.Get("Content-Encoding"))
+
+	records := decodeOTLPRequest(t, unmarshalOTLPRequest(t, bodies[0]))
+	require.Len(t, records, 1)
+	assert.Equal(t, "testapp", records[0].resource["service.name"])
+	assert.Equal(t, uint64(9), records[0].severity)
+	assert.Equal(t, ":", records[0].body)
+	assert.Empty(t, records[0].traceID, "not an OpenTelemetry trace ID")
+	assert.Equal(t, "trace-123", records[0].attributes["traceId"])
+	assert.Equal(t, "c-1", records[0].attributes["correlationId"])
+	assert.Equal(t, "GET", records[0].attributes["method"])
+	assert.Equal(t, int64(200), records[0].attributes["httpStatus"])
+	assert.Equal(t, "tdr", records[0].attributes["stream"])
+}
+
+func TestOTLPSinkBatches(t *testing.T) {
+	collector := newLogsCollector(t)
+	logger := newOTLPSinkLogger(t, "http://"+collector.addr, "", &OTLPConfig{
+		MaxExportBatchSize: 2,
+		ExportInterval:     60000,
+	})
+
+	for i := 1; i <= 5; i++ {
+		logger.Info(strconv.Itoa(i))
+	}
+	require.NoError(t, logger.Sync())
+
+	var sizes []int
+	var bodies []interface{}
+	for _, records := range collector.received(t) {
+		sizes = append(sizes, len(records))
+		for _, r := range records {
+			bodies = append(bodies, r.body)
+		}
+	}
+	assert.Equal(t, []int{2, 2, 1}, sizes, "full batches, then the rest on Sync")
+	assert.Equal(t, []interface{}{"1", "2", "3", "4", "5"}, bodies)
+}
+
+func TestOTLPSinkExportInterval(t *testing.T) {
+	collector := newLogsCollector(t)
+	logger := newOTLPSinkLogger(t, collector.addr, "", &OTLPConfig{Insecure: true, ExportInterval: 20})
+
+	logger.Info("waiting")
+	assert.Eventually(t, func() bool {
+		return len(collector.received(t)) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestOTLPSinkRetries(t *testing.T) {
+	collector := newLogsCollector(t)
+	collector.respond = func(n int) (*collogspb.ExportLogsServiceResponse, error) {
+		if n < 3 {
+			s, _ := status.New(codes.Unavailable, "starting").
+				WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(time.Millisecond)})
+			return nil, s.Err()
+		}
+		return &collogspb.ExportLogsServiceResponse{}, nil
+	}
+	logger := newOTLPSinkLogger(t, collector.addr, "", &OTLPConfig{Insecure: true, RetryInitialInterval: 1})
+
+	logger.Info("retried")
+	require.NoError(t, logger.Sync())
+	assert.Equal(t, 3, collector.attemptCount())
+	assert.Len(t, collector.received(t), 1)
+}
+
+func TestOTLPSinkDoesNotRetryPermanentFailures(t *testing.T) {
+	collector := newLogsCollector(t)
+	collector.respond = func(int) (*collogspb.ExportLogsServiceResponse, error) {
+		// retryable only with a RetryInfo
+		return nil, status.Error(codes.ResourceExhausted, "too large")
+	}
+	logger := newOTLPSinkLogger(t, collector.addr, "", &OTLPConfig{Insecure: true, RetryInitialInterval: 1})
+
+	logger.Info("rejected")
+	err := logger.Sync()
+	assert.ErrorContains(t, err, "otlp sink dropped 1 records")
+	assert.ErrorContains(t, err, "too large")
+	assert.Equal(t, 1, collector.attemptCount())
+}
+
+func TestOTLPSinkHTTPRetries(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch attempts.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.WriteHeader(http.StatusOK)
+		default:
+			body, _ := proto.Marshal(&rpcstatus.Status{Code: int32(codes.InvalidArgument), Message: "bad record"})
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write(body)
+		}
+	}))
+	defer server.Close()
+	logger := newOTLPSinkLogger(t, server.URL, "", &OTLPConfig{Protocol: OTLPHTTP, RetryInitialInterval: 1})
+
+	logger.Info("retried")
+	require.NoError(t, logger.Sync())
+	assert.Equal(t, int32(2), attempts.Load())
+
+	logger.Info("rejected")
+	assert.ErrorContains(t, logger.Sync(), "400 Bad Request: bad record")
+	assert.Equal(t, int32(3), attempts.Load(), "400 is not retried")
+}
+
+func TestOTLPSinkPartialSuccess(t *testing.T) {
+	collector := newLogsCollector(t)
+	collector.respond = func(int) (*collogspb.ExportLogsServiceResponse, error) {
+		return &collogspb.ExportLogsServiceResponse{PartialSuccess: &collogspb.ExportLogsPartialSuccess{
+			RejectedLogRecords: 1,
+			ErrorMessage:       "timestamp too old",
+		}}, nil
+	}
+	logger := newOTLPSinkLogger(t, collector.addr, "", &OTLPConfig{Insecure: true})
+
+	logger.Info("old")
+	assert.ErrorContains(t, logger.Sync(), "otlp collector rejected 1 records: timestamp too old")
+	assert.NoError(t, logger.Sync(), "reported once")
+}
+
+// blockingExporter records the requests it is given, the first one blocking
+// until release is closed.
+type blockingExporter struct {
+	started chan struct{}
+	release chan struct{}
+
+	mu       sync.Mutex
+	requests []*collogspb.ExportLogsServiceRequest
+}
+
+func (e *blockingExporter) export(_ context.Context, request *collogspb.ExportLogsServiceRequest) error {
+	e.mu.Lock()
+	e.requests = append(e.requests, request)
+	first := len(e.requests) == 1
+	e.mu.Unlock()
+	if first {
+		close(e.started)
+		<-e.release
+	}
+	return nil
+}
+
+func (e *blockingExporter) close() error {
+	return nil
+}
+
+func TestOTLPWriterDropsOldestWhenQueueFull(t *testing.T) {
+	exporter := &blockingExporter{started: make(chan struct{}), release: make(chan struct{})}
+	w := newOTLPWriter(exporter, OTLPConfig{MaxQueueSize: 2, ExportInterval: 60000}.withDefaults())
+	defer w.Close()
+	write := func(body string) {
+		entry, err := proto.Marshal(&logspb.ResourceLogs{ScopeLogs: []*logspb.ScopeLogs{{
+			LogRecords: []*logspb.LogRecord{{Body: otlpAnyValue(body)}},
+		}}})
+		require.NoError(t, err)
+		_, err = w.Write(entry)
+		require.NoError(t, err)
+	}
+
+	write("1")
+	write("2")
+	<-exporter.started
+	write("3")
+	write("4")
+	write("5")
+	close(exporter.release)
+	assert.ErrorContains(t, w.Sync(), "queue full, dropped 1 records")
+
+	exporter.mu.Lock()
+	defer exporter.mu.Unlock()
+	var bodies []interface{}
+	for _, request := range exporter.requests {
+		for _, r := range decodeOTLPRequest(t, request) {
+			bodies = append(bodies, r.body)
+		}
+	}
+	assert.Equal(t, []interface{}{"1", "2", "4", "5"}, bodies)
+}
+
+func TestOTLPSeverity(t *testing.T) {
+	for level, want := range map[zapcore.Level]uint64{
+		zapcore.DebugLevel:  5,
+		zapcore.InfoLevel:   9,
+		zapcore.WarnLevel:   13,
+		zapcore.ErrorLevel:  17,
+		zapcore.DPanicLevel: 21,
+		zapcore.PanicLevel:  22,
+		zapcore.FatalLevel:  23,
+	} {
+		assert.Equal(t, want, uint64(otlpSeverity(level)), level.String())
+	}
+}
+
+func TestOTLPSinkValidate(t *testing.T) {
+	assert.NoError(t, SinkConfig{Name: "x", Type: SinkOTLP, Address: "localhost:4317"}.Validate())
+	assert.NoError(t, SinkConfig{Name: "x", Type: SinkOTLP, Address: "https://collector:4317"}.Validate())
+	assert.NoError(t, SinkConfig{Name: "x", Type: SinkOTLP, Address: "http://localhost:4318",
+		OTLP: &OTLPConfig{Protocol: OTLPHTTP}}.Validate())
+	assert.Error(t, SinkConfig{Name: "x", Type: SinkOTLP}.Validate())
+	assert.Error(t, SinkConfig{Name: "x", Type: SinkOTLP, Address: "localhost"}.Validate())
+	assert.Error(t, SinkConfig{Name: "x", Type: SinkOTLP, Address: "localhost:4318",
+		OTLP: &OTLPConfig{Protocol: OTLPHTTP}}.Validate())
+	assert.Error(t, SinkConfig{Name: "x", Type: SinkOTLP, Address: "localhost:4317",
+		OTLP: &OTLPConfig{Protocol: "http/json"}}.Validate())
+	assert.Error(t, SinkConfig{Name: "x", Type: SinkOTLP, Address: "localhost:4317",
+		Encoding: EncodingConsole}.Validate())
+	assert.Error(t, SinkConfig{Name: "x", Type: SinkOTLP, Address: "localhost:4317",
+		OTLP: &OTLPConfig{MaxQueueSize: -1}}.Validate())
+}
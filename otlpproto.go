@@ -0,0 +1,335 @@
+package golog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	grpcgzip "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// otlpScope is the InstrumentationScope of the records.
+var otlpScope = &commonpb.InstrumentationScope{Name: "github.com/tommynurwantoro/golog"}
+
+// otlpKeyValue returns a KeyValue holding a value decoded from JSON.
+func otlpKeyValue(key string, value interface{}) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: otlpAnyValue(value)}
+}
+
+// otlpAnyValue converts a value decoded from JSON. Integers are int_value,
+// other numbers double_value, and null an empty AnyValue.
+func otlpAnyValue(value interface{}) *commonpb.AnyValue {
+	switch v := value.(type) {
+	case string:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v}}
+	case bool:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v}}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: i}}
+		}
+		if f, err := v.Float64(); err == nil {
+			return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: f}}
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v.String()}}
+	case []interface{}:
+		array := &commonpb.ArrayValue{Values: make([]*commonpb.AnyValue, len(v))}
+		for i, item := range v {
+			array.Values[i] = otlpAnyValue(item)
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: array}}
+	case map[string]interface{}:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_KvlistValue{
+			KvlistValue: &commonpb.KeyValueList{Values: otlpKeyValues(v)},
+		}}
+	default:
+		return &commonpb.AnyValue{}
+	}
+}
+
+// otlpKeyValues converts the values of a JSON object, sorted by key.
+func otlpKeyValues(values map[string]interface{}) []*commonpb.KeyValue {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	kvs := make([]*commonpb.KeyValue, len(keys))
+	for i, k := range keys {
+		kvs[i] = otlpKeyValue(k, values[k])
+	}
+	return kvs
+}
+
+// otlpRequest returns an ExportLogsServiceRequest of records, with one
+// ResourceLogs per run of records of the same resource.
+func otlpRequest(records []otlpRecord) *collogspb.ExportLogsServiceRequest {
+	request := &collogspb.ExportLogsServiceRequest{}
+	for i := 0; i < len(records); {
+		j := i + 1
+		for j < len(records) && proto.Equal(records[j].resource, records[i].resource) {
+			j++
+		}
+		scopeLogs := &logspb.ScopeLogs{Scope: otlpScope}
+		for _, r := range records[i:j] {
+			scopeLogs.LogRecords = append(scopeLogs.LogRecords, r.record)
+		}
+		request.ResourceLogs = append(request.ResourceLogs, &logspb.ResourceLogs{
+			Resource:  records[i].resource,
+			ScopeLogs: []*logspb.ScopeLogs{scopeLogs},
+		})
+		i = j
+	}
+	return request
+}
+
+// otlpPartialSuccess reports the records rejected according to an
+// ExportLogsServiceResponse.
+func otlpPartialSuccess(response *collogspb.ExportLogsServiceResponse) error {
+	partial := response.GetPartialSuccess()
+	if partial.GetRejectedLogRecords() == 0 && partial.GetErrorMessage() == "" {
+		return nil
+	}
+	return fmt.Errorf("golog: otlp collector rejected %d records: %s",
+		partial.GetRejectedLogRecords(), partial.GetErrorMessage())
+}
+
+// otlpError is a failed export. throttle is the delay the collector asked
+// for before a retry.
+type otlpError struct {
+	err      error
+	retry    bool
+	throttle time.Duration
+}
+
+func (e *otlpError) Error() string {
+	return e.err.Error()
+}
+
+func (e *otlpError) Unwrap() error {
+	return e.err
+}
+
+// otlpExporter sends ExportLogsServiceRequests to a collector.
+type otlpExporter interface {
+	export(ctx context.Context, request *collogspb.ExportLogsServiceRequest) error
+	close() error
+}
+
+// otlpGRPCTarget returns the gRPC target of a host:port or URL address, and
+// whether an http:// URL asks for a connection without TLS.
+func otlpGRPCTarget(address string) (target string, plaintext bool, err error) {
+	if !strings.Contains(address, "://") {
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			return "", false, fmt.Errorf("%q is not a host:port or URL", address)
+		}
+		return address, false, nil
+	}
+	u, err := url.Parse(address)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", false, fmt.Errorf("%q is not a host:port or URL", address)
+	}
+	return u.Host, u.Scheme == "http", nil
+}
+
+// otlpGRPCExporter calls LogsService.Export over gRPC.
+type otlpGRPCExporter struct {
+	conn   *grpc.ClientConn
+	client collogspb.LogsServiceClient
+	// err is the failure to set the connection up, returned by every export
+	err      error
+	metadata metadata.MD
+	opts     []grpc.CallOption
+}
+
+func newOTLPGRPCExporter(address string, c OTLPConfig) *otlpGRPCExporter {
+	e := &otlpGRPCExporter{metadata: metadata.New(c.Headers)}
+	if c.Gzip {
+		e.opts = append(e.opts, grpc.UseCompressor(grpcgzip.Name))
+	}
+
+	target, plaintext, err := otlpGRPCTarget(address)
+	if err != nil {
+		e.err = fmt.Errorf("golog: otlp export: %w", err)
+		return e
+	}
+	tlsConfig := c.TLSConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+	creds := credentials.NewTLS(tlsConfig)
+	if plaintext || c.Insecure {
+		creds = insecure.NewCredentials()
+	}
+	if e.conn, err = grpc.NewClient(target, grpc.WithTransportCredentials(creds)); err != nil {
+		e.err = fmt.Errorf("golog: otlp export: %w", err)
+		return e
+	}
+	e.client = collogspb.NewLogsServiceClient(e.conn)
+	return e
+}
+
+func (e *otlpGRPCExporter) export(ctx context.Context, request *collogspb.ExportLogsServiceRequest) error {
+	if e.err != nil {
+		return e.err
+	}
+	ctx = metadata.NewOutgoingContext(ctx, e.metadata)
+	response, err := e.client.Export(ctx, request, e.opts...)
+	if err != nil {
+		return otlpGRPCError(err)
+	}
+	return otlpPartialSuccess(response)
+}
+
+// otlpGRPCError classifies a failed call like the OTLP exporters of the
+// OpenTelemetry SDK: ResourceExhausted is retried only if the collector
+// sent a RetryInfo, which also sets the delay before the retry.
+func otlpGRPCError(err error) error {
+	s := status.Convert(err)
+	exportErr := &otlpError{err: fmt.Errorf("golog: otlp export: %w", err)}
+	var retryInfo bool
+	for _, detail := range s.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			retryInfo = true
+			exportErr.throttle = info.GetRetryDelay().AsDuration()
+		}
+	}
+	switch s.Code() {
+	case codes.Canceled, codes.DeadlineExceeded, codes.Aborted, codes.OutOfRange, codes.Unavailable,
+		codes.DataLoss:
+		exportErr.retry = true
+	case codes.ResourceExhausted:
+		exportErr.retry = retryInfo
+	}
+	return exportErr
+}
+
+func (e *otlpGRPCExporter) close() error {
+	if e.conn == nil {
+		return nil
+	}
+	return e.conn.Close()
+}
+
+// otlpHTTPExporter posts ExportLogsServiceRequests in binary protobuf.
+type otlpHTTPExporter struct {
+	url    string
+	conf   OTLPConfig
+	client *http.Client
+}
+
+func newOTLPHTTPExporter(address string, c OTLPConfig) *otlpHTTPExporter {
+	endpoint := address
+	if u, err := url.Parse(address); err == nil && (u.Path == "" || u.Path == "/") {
+		u.Path = "/v1/logs"
+		endpoint = u.String()
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if c.TLSConfig != nil {
+		transport.TLSClientConfig = c.TLSConfig
+	}
+	return &otlpHTTPExporter{url: endpoint, conf: c, client: &http.Client{Transport: transport}}
+}
+
+func (e *otlpHTTPExporter) export(ctx context.Context, request *collogspb.ExportLogsServiceRequest) error {
+	data, err := proto.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("golog: otlp export: %w", err)
+	}
+	var body io.Reader = bytes.NewReader(data)
+	if e.conf.Gzip {
+		var b bytes.Buffer
+		zw := gzip.NewWriter(&b)
+		_, _ = zw.Write(data)
+		_ = zw.Close()
+		body = &b
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	if e.conf.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for k, v := range e.conf.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return &otlpError{err: fmt.Errorf("golog: otlp export: %w", err), retry: true}
+	}
+	defer resp.Body.Close()
+	response, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	switch {
+	case resp.StatusCode < 300 && err != nil:
+		return fmt.Errorf("golog: otlp export: %w", err)
+	case resp.StatusCode < 300:
+		var success collogspb.ExportLogsServiceResponse
+		if err := proto.Unmarshal(response, &success); err != nil {
+			return fmt.Errorf("golog: otlp export: malformed response: %w", err)
+		}
+		return otlpPartialSuccess(&success)
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode == http.StatusBadGateway,
+		resp.StatusCode == http.StatusServiceUnavailable, resp.StatusCode == http.StatusGatewayTimeout:
+		return &otlpError{
+			err:      fmt.Errorf("golog: POST %s: %s", e.url, resp.Status),
+			retry:    true,
+			throttle: otlpRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	default:
+		return errors.New("golog: POST " + e.url + ": " + resp.Status + otlpStatusMessage(response))
+	}
+}
+
+// otlpRetryAfter returns the delay of a Retry-After header, in seconds or
+// as a date.
+func otlpRetryAfter(value string) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// otlpStatusMessage returns ": " and the message of the google.rpc.Status
+// in the body of a failed response, or nothing if it has none.
+func otlpStatusMessage(body []byte) string {
+	var s rpcstatus.Status
+	if err := proto.Unmarshal(body, &s); err != nil || s.GetMessage() == "" {
+		return ""
+	}
+	return ": " + s.GetMessage()
+}
+
+func (e *otlpHTTPExporter) close() error {
+	e.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,217 @@
+package golog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// logState is shared by a logger and every logger derived from it. Reload
+// replaces its current generation in one step; outputs and levels outlive
+// generations, so entries in flight during a reload are never lost.
+type logState struct {
+	current  atomic.Pointer[generation]
+	outputs  *outputs
+	levels   *componentLevels
+	levelTDR zap.AtomicLevel
+
+	// reloadMu serializes reloads
+	reloadMu sync.Mutex
+}
+
+// generation is everything a reload can change, built from one Config.
+type generation struct {
+	conf       Config
+	system     zapcore.Core
+	tdr        zapcore.Core
+	masker     *masker
+	limits     bodyLimits
+	spanEvents bool
+}
+
+func (s *logState) newGeneration(conf Config) *generation {
+	system, tdr := s.newCores(conf)
+	return &generation{
+		conf:       conf,
+		system:     system,
+		tdr:        tdr,
+		masker:     policyMasker(conf.Masking),
+		limits:     newBodyLimits(conf.BodyLimits),
+		spanEvents: conf.SpanEvents,
+	}
+}
+
+// outputs are the writers of a logger. The system and TDR files are nil
+// without a location, and the console writers are created the first time
+// a generation mirrors to stdout.
+type outputs struct {
+	async  *asyncOutputs
+	conf   *AsyncConfig
+	system zapcore.WriteSyncer
+	tdr    zapcore.WriteSyncer
+	sinks  []*sink
+
+	// files are the rotators of the system and TDR files
+	files []io.Closer
+
+	stdoutSystem zapcore.WriteSyncer
+	stdoutTDR    zapcore.WriteSyncer
+}
+
+// stdout returns the console writers. Callers hold logState.reloadMu or
+// own the state exclusively.
+func (o *outputs) stdout() (system, tdr zapcore.WriteSyncer) {
+	if o.stdoutSystem == nil {
+		o.stdoutSystem = o.async.wrap(zapcore.AddSync(os.Stdout), o.conf, &o.async.system)
+		o.stdoutTDR = o.async.wrap(zapcore.AddSync(os.Stdout), o.conf, &o.async.tdr)
+	}
+	return o.stdoutSystem, o.stdoutTDR
+}
+
+// close drains the async writers, then closes the files and sinks they
+// write to.
+func (o *outputs) close() error {
+	errs := []error{o.async.close()}
+	for _, f := range o.files {
+		errs = append(errs, f.Close())
+	}
+	for _, s := range o.sinks {
+		if s.output.closer != nil {
+			errs = append(errs, s.output.closer.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// swapCore is the core of the zap loggers of a Log. It forwards to the
+// cores of the current generation, so a reload applies to every logger
+// derived from the Log, including those holding fields added with With.
+type swapCore struct {
+	state  *logState
+	tdr    bool
+	fields []zapcore.Field
+
+	// derived caches the current core with fields added, per generation
+	derived atomic.Pointer[derivedCore]
+}
+
+type derivedCore struct {
+	gen  *generation
+	core zapcore.Core
+}
+
+func (c *swapCore) root(gen *generation) zapcore.Core {
+	if c.tdr {
+		return gen.tdr
+	}
+	return gen.system
+}
+
+func (c *swapCore) current() zapcore.Core {
+	gen := c.state.current.Load()
+	if len(c.fields) == 0 {
+		return c.root(gen)
+	}
+	if d := c.derived.Load(); d != nil && d.gen == gen {
+		return d.core
+	}
+	d := &derivedCore{gen: gen, core: c.root(gen).With(c.fields)}
+	c.derived.Store(d)
+	return d.core
+}
+
+func (c *swapCore) Enabled(level zapcore.Level) bool {
+	return c.root(c.state.current.Load()).Enabled(level)
+}
+
+func (c *swapCore) With(fields []zapcore.Field) zapcore.Core {
+	return &swapCore{
+		state:  c.state,
+		tdr:    c.tdr,
+		fields: append(c.fields[:len(c.fields):len(c.fields)], fields...),
+	}
+}
+
+func (c *swapCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return c.current().Check(entry, ce)
+}
+
+func (c *swapCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.current().Write(entry, fields)
+}
+
+func (c *swapCore) Sync() error {
+	return c.root(c.state.current.Load()).Sync()
+}
+
+// Reload applies conf to l and to every logger derived from it, without
+// losing entries being written. Only the log level, component levels,
+// Stdout, Masking, BodyLimits, Sampling, SpanEvents and the overflow policy
+// and sample rate of Async can change; a conf that changes anything else, or
+// that fails Validate, is rejected and l is left as it was.
+//
+// Levels are only reset when conf changes them, so levels set at runtime
+// with SetLevel survive a reload that leaves LogLevel alone.
+func (l *Log) Reload(conf Config) error {
+	if err := conf.Validate(); err != nil {
+		return err
+	}
+
+	s := l.state
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	old := s.current.Load().conf
+	if err := checkReloadable(old, conf); err != nil {
+		return err
+	}
+	conf.ContextExtractors = old.ContextExtractors
+
+	gen := s.newGeneration(conf)
+	if conf.Async != nil {
+		s.outputs.async.setPolicy(*conf.Async)
+	}
+	if conf.LogLevel != old.LogLevel {
+		s.levels.global.SetLevel(conf.LogLevel)
+		s.levelTDR.SetLevel(conf.LogLevel)
+	}
+	if !maps.Equal(conf.ComponentLevels, old.ComponentLevels) {
+		s.levels.set(conf.ComponentLevels)
+	}
+	s.current.Store(gen)
+	return nil
+}
+
+// checkReloadable reports the settings that differ between old and conf
+// and need a new logger to take effect.
+func checkReloadable(old, conf Config) error {
+	var errs []error
+	fixed := func(name string, changed bool) {
+		if changed {
+			errs = append(errs, fmt.Errorf("golog: %s cannot change without a restart", name))
+		}
+	}
+	fixed("app", conf.App != old.App)
+	fixed("appVer", conf.AppVer != old.AppVer)
+	fixed("env", conf.Env != old.Env)
+	fixed("versionFilePath", conf.VersionFilePath != old.VersionFilePath)
+	fixed("fileLocation", conf.FileLocation != old.FileLocation)
+	fixed("fileTDRLocation", conf.FileTDRLocation != old.FileTDRLocation)
+	fixed("fileMaxSize", conf.FileMaxSize != old.FileMaxSize)
+	fixed("fileMaxBackup", conf.FileMaxBackup != old.FileMaxBackup)
+	fixed("fileMaxAge", conf.FileMaxAge != old.FileMaxAge)
+	fixed("sinks", !reflect.DeepEqual(conf.Sinks, old.Sinks))
+	fixed("async", (conf.Async == nil) != (old.Async == nil))
+	if conf.Async != nil && old.Async != nil {
+		fixed("async.bufferSize", conf.Async.BufferSize != old.Async.BufferSize)
+	}
+	return errors.Join(errs...)
+}
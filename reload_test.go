@@ -0,0 +1,165 @@
+package golog
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func newReloadTestConfig(t *testing.T) Config {
+	t.Helper()
+	return Config{
+		App:          "testapp",
+		AppVer:       "1.0.0",
+		Env:          "production",
+		FileLocation: t.TempDir(),
+		FileMaxSize:  10,
+	}
+}
+
+func TestReload(t *testing.T) {
+	conf := newReloadTestConfig(t)
+	logger := NewLogger(conf)
+	l := logger.(*Log)
+
+	// children created before the reload follow it
+	child := logger.With(zap.String("userId", "u-1")).Named("payment").WithContext(context.Background())
+	child.Debug("hidden")
+	child.TDR(LogModel{Request: `{"password":"p","pin":"1234"}`})
+
+	conf.LogLevel = zapcore.DebugLevel
+	conf.Masking = &MaskingPolicy{Keys: []string{"pin"}}
+	require.NoError(t, l.Reload(conf))
+
+	child.Debug("shown")
+	child.TDR(LogModel{Request: `{"password":"p","pin":"1234"}`})
+	require.NoError(t, logger.Sync())
+
+	lines := readLogLines(t, filepath.Join(conf.FileLocation, "system.log"))
+	require.Len(t, lines, 1)
+	assert.Equal(t, "shown", lines[0]["message"])
+	assert.Equal(t, "u-1", lines[0]["userId"])
+	assert.Equal(t, "payment", lines[0][ComponentKey])
+	assert.Equal(t, "testapp", lines[0]["app"])
+
+	lines = readLogLines(t, filepath.Join(conf.FileLocation, "tdr.log"))
+	require.Len(t, lines, 2)
+	assert.Equal(t, map[string]interface{}{"password": "*****", "pin": "1234"}, lines[0]["request"])
+	assert.Equal(t, map[string]interface{}{"password": "p", "pin": "*****"}, lines[1]["request"])
+	assert.Equal(t, zapcore.DebugLevel, l.TDRLevel())
+}
+
+func TestReloadKeepsRuntimeLevels(t *testing.T) {
+	conf := newReloadTestConfig(t)
+	l := NewLogger(conf).(*Log)
+
+	l.SetLevel(zapcore.WarnLevel)
+	conf.SpanEvents = true
+	require.NoError(t, l.Reload(conf))
+	assert.Equal(t, zapcore.WarnLevel, l.Level(), "LogLevel did not change")
+
+	conf.ComponentLevels = map[string]zapcore.Level{"db": zapcore.ErrorLevel}
+	require.NoError(t, l.Reload(conf))
+	assert.Equal(t, map[string]zapcore.Level{"db": zapcore.ErrorLevel}, l.ComponentLevels())
+}
+
+func TestReloadRejected(t *testing.T) {
+	conf := newReloadTestConfig(t)
+	l := NewLogger(conf).(*Log)
+
+	invalid := conf
+	invalid.LogLevel = zapcore.DebugLevel
+	invalid.Masking = &MaskingPolicy{KeyRegexps: []string{"("}}
+	assert.Error(t, l.Reload(invalid))
+
+	moved := conf
+	moved.LogLevel = zapcore.DebugLevel
+	moved.FileLocation = t.TempDir()
+	moved.Async = &AsyncConfig{}
+	err := l.Reload(moved)
+	assert.ErrorContains(t, err, "fileLocation cannot change without a restart")
+	assert.ErrorContains(t, err, "async cannot change without a restart")
+
+	assert.Equal(t, zapcore.InfoLevel, l.Level(), "a rejected update changes nothing")
+}
+
+func TestReloadAsyncPolicy(t *testing.T) {
+	conf := newReloadTestConfig(t)
+	conf.Async = &AsyncConfig{BufferSize: 8}
+	l := NewLogger(conf).(*Log)
+	defer l.Close()
+
+	conf.Async = &AsyncConfig{BufferSize: 8, OverflowPolicy: OverflowDropOldest, SampleRate: 3}
+	require.NoError(t, l.Reload(conf))
+	for _, w := range l.state.outputs.async.writers() {
+		policy, rate := w.policy, w.sampleRate
+		assert.Equal(t, OverflowDropOldest, policy)
+		assert.Equal(t, 3, rate)
+	}
+
+	conf.Async = &AsyncConfig{BufferSize: 16}
+	assert.ErrorContains(t, l.Reload(conf), "async.bufferSize")
+}
+
+func TestReloadSampling(t *testing.T) {
+	conf := newReloadTestConfig(t)
+	logger := NewLogger(conf)
+	l := logger.(*Log)
+	child := logger.With(zap.String("userId", "u-1"))
+	logRepeated := func() {
+		for i := 0; i < 8; i++ {
+			child.Info("repeated")
+		}
+		logger.TDR(LogModel{CorrelationID: "c-1"})
+	}
+
+	logRepeated()
+	conf.Sampling = &SamplingConfig{Initial: 2, Thereafter: 3, Tick: 60000}
+	require.NoError(t, l.Reload(conf))
+	logRepeated()
+	conf.Sampling = nil
+	require.NoError(t, l.Reload(conf))
+	logRepeated()
+	require.NoError(t, logger.Sync())
+
+	// the first two entries, then the 5th and the 8th
+	assert.Len(t, readLogLines(t, filepath.Join(conf.FileLocation, "system.log")), 8+4+8)
+	assert.Len(t, readLogLines(t, filepath.Join(conf.FileLocation, "tdr.log")), 3, "TDR records are never sampled")
+}
+
+func TestReloadConcurrentLogging(t *testing.T) {
+	conf := newReloadTestConfig(t)
+	conf.Async = &AsyncConfig{BufferSize: 16}
+	logger := NewLogger(conf)
+	l := logger.(*Log)
+	defer l.Close()
+
+	const writers, entries = 4, 200
+	var wg sync.WaitGroup
+	for g := 0; g < writers; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			child := logger.With(zap.Int("writer", g))
+			for i := 0; i < entries; i++ {
+				child.Info("entry " + strconv.Itoa(i))
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		conf.Stdout = false
+		conf.SpanEvents = i%2 == 0
+		require.NoError(t, l.Reload(conf))
+	}
+	wg.Wait()
+	require.NoError(t, logger.Sync())
+
+	assert.Len(t, readLogLines(t, filepath.Join(conf.FileLocation, "system.log")), writers*entries, "no entry is lost")
+}
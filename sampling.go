@@ -0,0 +1,60 @@
+package golog
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Defaults for SamplingConfig.
+const (
+	DefaultSamplingInitial    = 100
+	DefaultSamplingThereafter = 100
+	DefaultSamplingTick       = 1000
+)
+
+// SamplingConfig caps the rate of repeated system log entries, as zap's
+// sampler does: entries are counted by level and message, and once Initial
+// of them have been logged within a tick only one in Thereafter is kept
+// until the next tick. TDR records are never sampled.
+type SamplingConfig struct {
+	// Entries with the same level and message logged per tick before
+	// sampling starts. Defaults to DefaultSamplingInitial.
+	Initial int `json:"initial"`
+
+	// One in Thereafter entries is logged after the first Initial.
+	// Defaults to DefaultSamplingThereafter.
+	Thereafter int `json:"thereafter"`
+
+	// Length of a tick in milliseconds. Defaults to DefaultSamplingTick.
+	Tick int `json:"tick"`
+}
+
+// Validate reports negative settings.
+func (c SamplingConfig) Validate() error {
+	var errs []error
+	if c.Initial < 0 {
+		errs = append(errs, fmt.Errorf("golog: negative sampling initial %d", c.Initial))
+	}
+	if c.Thereafter < 0 {
+		errs = append(errs, fmt.Errorf("golog: negative sampling thereafter %d", c.Thereafter))
+	}
+	if c.Tick < 0 {
+		errs = append(errs, fmt.Errorf("golog: negative sampling tick %d", c.Tick))
+	}
+	return errors.Join(errs...)
+}
+
+// sample wraps core in a sampler configured by conf, or returns it as is
+// when conf is nil.
+func sample(core zapcore.Core, conf *SamplingConfig) zapcore.Core {
+	if conf == nil {
+		return core
+	}
+	initial := limitOrDefault(conf.Initial, DefaultSamplingInitial)
+	thereafter := limitOrDefault(conf.Thereafter, DefaultSamplingThereafter)
+	tick := limitOrDefault(conf.Tick, DefaultSamplingTick)
+	return zapcore.NewSamplerWithOptions(core, time.Duration(tick)*time.Millisecond, initial, thereafter)
+}
@@ -0,0 +1,194 @@
+package golog
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Built-in value scanners for MaskingPolicy.Scanners.
+const (
+	// ScanCardNumber detects payment card numbers (13-19 digits, optionally
+	// separated by spaces or dashes) that pass the Luhn check.
+	ScanCardNumber = "card"
+	// ScanEmail detects email addresses.
+	ScanEmail = "email"
+	// ScanPhone detects phone numbers in international (+62 812 ...) or
+	// national (0812-...) format.
+	ScanPhone = "phone"
+	// ScanNationalID detects US social security numbers (123-45-6789) and
+	// Indonesian NIK numbers (16 digits).
+	ScanNationalID = "nationalId"
+	// ScanJWT detects JSON Web Tokens.
+	ScanJWT = "jwt"
+)
+
+// valueScanner finds one kind of PII inside string values.
+type valueScanner struct {
+	// precheck cheaply rules out values that cannot contain a match,
+	// so most strings never reach the regular expression.
+	precheck func(s string) bool
+	re       *regexp.Regexp
+	// validate rejects regular expression matches that are not PII.
+	validate func(match string) bool
+	// partial returns the partially masked form of a match.
+	partial func(match string) string
+}
+
+var valueScanners = map[string]*valueScanner{
+	ScanCardNumber: {
+		precheck: minDigits(13),
+		re:       regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+		validate: func(match string) bool { return luhnValid(digitsOf(match)) },
+		partial:  keepLastDigits(4),
+	},
+	ScanEmail: {
+		precheck: func(s string) bool { return strings.IndexByte(s, '@') > 0 },
+		re:       regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+		partial:  partialEmail,
+	},
+	ScanPhone: {
+		precheck: minDigits(8),
+		re:       regexp.MustCompile(`(?:\+|\b0)\d{1,3}[ .-]?\(?\d{1,4}\)?(?:[ .-]?\d{2,4}){2,3}\b`),
+		validate: func(match string) bool {
+			n := len(digitsOf(match))
+			return n >= 8 && n <= 15
+		},
+		partial: keepLastDigits(4),
+	},
+	ScanNationalID: {
+		precheck: minDigits(9),
+		re:       regexp.MustCompile(`\b(?:\d{3}-\d{2}-\d{4}|\d{16})\b`),
+		partial:  keepLastDigits(4),
+	},
+	ScanJWT: {
+		precheck: func(s string) bool { return strings.Contains(s, "eyJ") },
+		re:       regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+		partial: func(match string) string {
+			header, _, _ := strings.Cut(match, ".")
+			return header + "." + maskedValue
+		},
+	},
+}
+
+// scannerOrder fixes the order scanners run in. Cards run before national
+// IDs so a 16-digit card number is reported as a card.
+var scannerOrder = []string{ScanJWT, ScanEmail, ScanCardNumber, ScanNationalID, ScanPhone}
+
+// compileScanners returns the named scanners in scannerOrder. Unknown
+// names are skipped and reported in the returned error.
+func compileScanners(names []string) ([]*valueScanner, error) {
+	var errs []error
+	enabled := make(map[string]bool, len(names))
+	for _, name := range names {
+		if _, ok := valueScanners[name]; !ok {
+			errs = append(errs, fmt.Errorf("golog: unknown value scanner %q", name))
+			continue
+		}
+		enabled[name] = true
+	}
+
+	var scanners []*valueScanner
+	for _, name := range scannerOrder {
+		if enabled[name] {
+			scanners = append(scanners, valueScanners[name])
+		}
+	}
+	return scanners, errors.Join(errs...)
+}
+
+// scanValue masks every PII match found in s.
+func (m *masker) scanValue(s string) string {
+	for _, sc := range m.scanners {
+		if !sc.precheck(s) {
+			continue
+		}
+		s = sc.re.ReplaceAllStringFunc(s, func(match string) string {
+			if sc.validate != nil && !sc.validate(match) {
+				return match
+			}
+			if m.partialScan {
+				return sc.partial(match)
+			}
+			return maskedValue
+		})
+	}
+	return s
+}
+
+func minDigits(n int) func(string) bool {
+	return func(s string) bool {
+		count := 0
+		for i := 0; i < len(s); i++ {
+			if s[i] >= '0' && s[i] <= '9' {
+				count++
+				if count >= n {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+func digitsOf(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] >= '0' && s[i] <= '9' {
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+func luhnValid(digits string) bool {
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// keepLastDigits masks every digit except the last n, leaving separators
+// in place, e.g. "4111 1111 1111 1111" becomes "**** **** **** 1111".
+func keepLastDigits(n int) func(string) string {
+	return func(match string) string {
+		keep := n
+		b := []byte(match)
+		for i := len(b) - 1; i >= 0; i-- {
+			if b[i] < '0' || b[i] > '9' {
+				continue
+			}
+			if keep > 0 {
+				keep--
+				continue
+			}
+			b[i] = '*'
+		}
+		return string(b)
+	}
+}
+
+// partialEmail keeps the first character of the local part and the domain,
+// e.g. "john.doe@example.com" becomes "j*******@example.com".
+func partialEmail(match string) string {
+	local, domain, _ := strings.Cut(match, "@")
+	if len(local) <= 1 {
+		return strings.Repeat("*", len(local)) + "@" + domain
+	}
+	return local[:1] + strings.Repeat("*", len(local)-1) + "@" + domain
+}
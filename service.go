@@ -8,6 +8,8 @@ import (
 
 type LoggerInterface interface {
 	WithContext(ctx context.Context) LoggerInterface
+	With(fields ...zap.Field) LoggerInterface
+	Named(name string) LoggerInterface
 	Debug(message string, fields ...zap.Field)
 	Info(message string, fields ...zap.Field)
 	Warn(message string, fields ...zap.Field)
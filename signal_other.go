@@ -0,0 +1,8 @@
+//go:build !unix
+
+package golog
+
+// WatchLevelSignals is a no-op on platforms without SIGUSR1 and SIGUSR2.
+func WatchLevelSignals(l LevelController) (stop func()) {
+	return func() {}
+}
@@ -0,0 +1,55 @@
+//go:build unix
+
+package golog
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// WatchLevelSignals switches the system level of l to DebugLevel on SIGUSR1
+// and back to the level it had before on SIGUSR2, so verbose logging can be
+// toggled on a running process with kill -USR1 <pid>. Call the returned
+// function to stop watching.
+func WatchLevelSignals(l LevelController) (stop func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+	done := make(chan struct{})
+
+	go func() {
+		previous := l.Level()
+		debug := false
+		for {
+			select {
+			case sig := <-signals:
+				switch sig {
+				case syscall.SIGUSR1:
+					if !debug {
+						previous = l.Level()
+						debug = true
+					}
+					l.SetLevel(zapcore.DebugLevel)
+				case syscall.SIGUSR2:
+					if debug {
+						l.SetLevel(previous)
+						debug = false
+					}
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(signals)
+			close(done)
+		})
+	}
+}
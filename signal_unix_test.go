@@ -0,0 +1,26 @@
+//go:build unix
+
+package golog
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestWatchLevelSignals(t *testing.T) {
+	l, _ := newLevelTestLogger(t)
+	l.SetLevel(zapcore.WarnLevel)
+
+	stop := WatchLevelSignals(l)
+	defer stop()
+
+	assert.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR1))
+	assert.Eventually(t, func() bool { return l.Level() == zapcore.DebugLevel }, time.Second, 5*time.Millisecond)
+
+	assert.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR2))
+	assert.Eventually(t, func() bool { return l.Level() == zapcore.WarnLevel }, time.Second, 5*time.Millisecond)
+}
@@ -0,0 +1,382 @@
+package golog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Streams a sink can receive.
+const (
+	StreamSystem = "system"
+	StreamTDR    = "tdr"
+)
+
+// Sink types for SinkConfig.Type.
+const (
+	// SinkFile writes to a file rotated like system.log and tdr.log.
+	SinkFile = "file"
+	// SinkStdout writes to the standard output.
+	SinkStdout = "stdout"
+	// SinkStderr writes to the standard error.
+	SinkStderr = "stderr"
+	// SinkUnix writes to a Unix stream socket.
+	SinkUnix = "unix"
+	// SinkTCP writes to a TCP endpoint.
+	SinkTCP = "tcp"
+	// SinkUDP sends one datagram per entry to a UDP endpoint.
+	SinkUDP = "udp"
+	// SinkWriter writes to SinkConfig.Writer.
+	SinkWriter = "writer"
+)
+
+// Encodings for SinkConfig.Encoding.
+const (
+	EncodingJSON    = "json"
+	EncodingConsole = "console"
+)
+
+// netWriteTimeout bounds dialling a socket sink and writing to it, so a
+// stuck endpoint cannot hold up logging calls indefinitely.
+const netWriteTimeout = 5 * time.Second
+
+// Bounds of the delay before a socket sink dials again after a failed dial.
+// Writes in between fail at once, so an endpoint that is down costs one
+// dial timeout per delay rather than one per entry.
+const (
+	netRedialMin = 100 * time.Millisecond
+	netRedialMax = 30 * time.Second
+)
+
+// SinkConfig is an output of the system or TDR stream, in addition to the
+// system.log and tdr.log files and the console output of Config.Stdout.
+type SinkConfig struct {
+	// Name identifies the sink in errors. Names must be unique.
+	Name string `json:"name"`
+
+	// Stream written to the sink: StreamSystem (default) or StreamTDR.
+	Stream string `json:"stream"`
+
+	// Destination: SinkFile, SinkStdout, SinkStderr, SinkUnix, SinkTCP,
+	// SinkUDP, SinkSyslog, SinkJournald, SinkHTTP, SinkKafka, SinkOTLP or
+	// SinkWriter.
+	Type string `json:"type"`
+
+	// File path for SinkFile, socket path for SinkUnix and SinkJournald,
+	// host:port for SinkTCP, SinkUDP and SinkSyslog, URL for SinkHTTP,
+	// comma-separated host:port list for SinkKafka and collector for
+	// SinkOTLP. Files are rotated with the FileMaxSize, FileMaxBackup and
+	// FileMaxAge of the Config.
+	Address string `json:"address"`
+
+	// Destination of SinkWriter. Writes are serialized; Sync is called if
+	// the writer has a Sync() error method.
+	Writer io.Writer `json:"-"`
+
+	// Minimum level of the entries written, on top of the level of the
+	// logger, e.g. ErrorLevel to ship only errors. If nil, every entry the
+	// logger lets through is written.
+	Level *zapcore.Level `json:"level"`
+
+	// Entry encoding: EncodingJSON (default) or EncodingConsole.
+	Encoding string `json:"encoding"`
+
+	// Settings of SinkSyslog.
+	Syslog *SyslogConfig `json:"syslog"`
+
+	// Settings of SinkJournald.
+	Journald *JournaldConfig `json:"journald"`
+
+	// Settings of SinkHTTP.
+	HTTP *HTTPConfig `json:"http"`
+
+	// Settings of SinkKafka.
+	Kafka *KafkaConfig `json:"kafka"`
+
+	// Settings of SinkOTLP.
+	OTLP *OTLPConfig `json:"otlp"`
+}
+
+// sinkType opens the destination of a sink type.
+type sinkType struct {
+	// address reports whether SinkConfig.Address is required
+	address bool
+	open    func(s SinkConfig, conf Config) sinkOutput
+
+	// validate, if not nil, reports invalid settings specific to the type
+	validate func(s SinkConfig) error
+}
+
+// sinkOutput is an opened sink destination.
+type sinkOutput struct {
+	writer zapcore.WriteSyncer
+
+	// encoder replaces the encoder chosen by SinkConfig.Encoding, for
+	// destinations with their own entry format
+	encoder func(zapcore.EncoderConfig) zapcore.Encoder
+
+	// closer, if not nil, releases the destination on Log.Close
+	closer io.Closer
+}
+
+var sinkTypes = map[string]sinkType{
+	SinkFile: {address: true, open: func(s SinkConfig, conf Config) sinkOutput {
+		rotator := &lumberjack.Logger{
+			Filename:   s.Address,
+			MaxSize:    conf.FileMaxSize, // megabytes
+			MaxBackups: conf.FileMaxBackup,
+			MaxAge:     conf.FileMaxAge, // days
+		}
+		return sinkOutput{writer: zapcore.AddSync(rotator), closer: rotator}
+	}},
+	SinkStdout: {open: func(SinkConfig, Config) sinkOutput {
+		return sinkOutput{writer: zapcore.AddSync(os.Stdout)}
+	}},
+	SinkStderr: {open: func(SinkConfig, Config) sinkOutput {
+		return sinkOutput{writer: zapcore.AddSync(os.Stderr)}
+	}},
+	SinkUnix:     {address: true, open: openNetSink},
+	SinkTCP:      {address: true, open: openNetSink},
+	SinkUDP:      {address: true, open: openNetSink},
+	SinkSyslog:   {open: openSyslogSink, validate: validateSyslogSink},
+	SinkJournald: {open: openJournaldSink, validate: validateJournaldSink},
+	SinkHTTP:     {address: true, open: openHTTPSink, validate: validateHTTPSink},
+	SinkKafka:    {address: true, open: openKafkaSink, validate: validateKafkaSink},
+	SinkOTLP:     {address: true, open: openOTLPSink, validate: validateOTLPSink},
+	SinkWriter: {open: func(s SinkConfig, _ Config) sinkOutput {
+		return sinkOutput{writer: zapcore.Lock(zapcore.AddSync(s.Writer))}
+	}},
+}
+
+func openNetSink(s SinkConfig, _ Config) sinkOutput {
+	w := newNetWriter(s.Type, s.Address)
+	return sinkOutput{writer: w, closer: w}
+}
+
+// Validate reports a sink without a name, with an unknown stream, type or
+// encoding, or without the address or writer its type needs.
+func (s SinkConfig) Validate() error {
+	var errs []error
+	if s.Name == "" {
+		errs = append(errs, errors.New("golog: sink without a name"))
+	}
+	switch s.Stream {
+	case "", StreamSystem, StreamTDR:
+	default:
+		errs = append(errs, fmt.Errorf("golog: sink %q: unknown stream %q", s.Name, s.Stream))
+	}
+	switch s.Encoding {
+	case "", EncodingJSON, EncodingConsole:
+	default:
+		errs = append(errs, fmt.Errorf("golog: sink %q: unknown encoding %q", s.Name, s.Encoding))
+	}
+	if s.Level != nil && !validLevel(*s.Level) {
+		errs = append(errs, fmt.Errorf("golog: sink %q: invalid level %d", s.Name, *s.Level))
+	}
+
+	t, ok := sinkTypes[s.Type]
+	switch {
+	case !ok:
+		errs = append(errs, fmt.Errorf("golog: sink %q: unknown type %q", s.Name, s.Type))
+	case t.address && s.Address == "":
+		errs = append(errs, fmt.Errorf("golog: sink %q: %s sink without an address", s.Name, s.Type))
+	case s.Type == SinkWriter && s.Writer == nil:
+		errs = append(errs, fmt.Errorf("golog: sink %q: writer sink without a writer", s.Name))
+	case t.validate != nil:
+		errs = append(errs, t.validate(s))
+	}
+	return errors.Join(errs...)
+}
+
+// encoder returns the encoder chosen by s.Encoding.
+func (s SinkConfig) encoder(encoderConfig zapcore.EncoderConfig) zapcore.Encoder {
+	if s.Encoding == EncodingConsole {
+		return zapcore.NewConsoleEncoder(encoderConfig)
+	}
+	return zapcore.NewJSONEncoder(encoderConfig)
+}
+
+// sink is an opened SinkConfig. Sinks outlive reloads, like the log files.
+type sink struct {
+	conf   SinkConfig
+	output sinkOutput
+}
+
+// openSinks opens the valid sinks of conf, wrapping their writers for
+// asynchronous writes if configured. Invalid sinks are reported by
+// Config.Validate and skipped here.
+func openSinks(conf Config, async *asyncOutputs) []*sink {
+	var sinks []*sink
+	for _, s := range conf.Sinks {
+		if s.Validate() != nil {
+			continue
+		}
+		output := sinkTypes[s.Type].open(s, conf)
+		stream := &async.system
+		if s.Stream == StreamTDR {
+			stream = &async.tdr
+		}
+		output.writer = async.wrap(output.writer, conf.Async, stream)
+		sinks = append(sinks, &sink{conf: s, output: output})
+	}
+	return sinks
+}
+
+// core returns the core writing the entries of the sink that stream lets
+// through.
+func (s *sink) core(encoderConfig zapcore.EncoderConfig, stream zapcore.LevelEnabler) zapcore.Core {
+	var encoder zapcore.Encoder
+	if s.output.encoder != nil {
+		encoder = s.output.encoder(encoderConfig)
+	} else {
+		encoder = s.conf.encoder(encoderConfig)
+	}
+
+	enabler := stream
+	if s.conf.Level != nil {
+		min := *s.conf.Level
+		enabler = zap.LevelEnablerFunc(func(level zapcore.Level) bool {
+			return level >= min && stream.Enabled(level)
+		})
+	}
+	return zapcore.NewCore(encoder, s.output.writer, enabler)
+}
+
+// recordFields are the fields that destinations with their own record
+// format carry next to the encoded entry, e.g. as syslog structured data.
+var recordFields = []string{"app", "appVer", "env", TraceIDKey.String()}
+
+// fieldsEncoder records the recordFields added to the embedded encoder with
+// With, so they can be looked up when an entry is encoded.
+type fieldsEncoder struct {
+	zapcore.Encoder
+	fields map[string]string
+}
+
+func (e *fieldsEncoder) AddString(key, value string) {
+	for _, field := range recordFields {
+		if key == field {
+			// copied, as clones share the map
+			fields := make(map[string]string, len(e.fields)+1)
+			for k, v := range e.fields {
+				fields[k] = v
+			}
+			fields[key] = value
+			e.fields = fields
+			break
+		}
+	}
+	e.Encoder.AddString(key, value)
+}
+
+func (e *fieldsEncoder) clone() fieldsEncoder {
+	return fieldsEncoder{Encoder: e.Encoder.Clone(), fields: e.fields}
+}
+
+// lookup returns the value of the string field key of an entry, preferring
+// the fields of the entry to those added with With.
+func (e *fieldsEncoder) lookup(key string, fields []zapcore.Field) string {
+	value := e.fields[key]
+	for _, f := range fields {
+		if f.Key == key && f.Type == zapcore.StringType {
+			value = f.String
+		}
+	}
+	return value
+}
+
+// netWriter writes to a socket. It dials on first use and again after a
+// failed write, so logging survives a restart of the endpoint. Failed dials
+// are retried with an exponential backoff.
+type netWriter struct {
+	network string
+	address string
+	dial    func() (net.Conn, error)
+
+	mu   sync.Mutex
+	conn net.Conn
+
+	// dialErr is the error of the last dial, returned by writes until
+	// redialAt; backoff is the delay before the next attempt
+	dialErr  error
+	redialAt time.Time
+	backoff  time.Duration
+}
+
+func newNetWriter(network, address string) *netWriter {
+	w := &netWriter{network: network, address: address}
+	w.dial = func() (net.Conn, error) {
+		return net.DialTimeout(network, address, netWriteTimeout)
+	}
+	return w
+}
+
+// Write sends p, redialling once if the connection turns out to be broken
+// before any byte is written.
+func (w *netWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for attempt := 0; ; attempt++ {
+		if w.conn == nil {
+			if err := w.redial(); err != nil {
+				return 0, err
+			}
+		}
+		_ = w.conn.SetWriteDeadline(time.Now().Add(netWriteTimeout))
+		n, err := w.conn.Write(p)
+		if err == nil {
+			return n, nil
+		}
+		_ = w.conn.Close()
+		w.conn = nil
+		if attempt > 0 || n > 0 {
+			return n, err
+		}
+	}
+}
+
+// redial connects w, unless the last dial failed less than the backoff ago.
+func (w *netWriter) redial() error {
+	if w.dialErr != nil && time.Now().Before(w.redialAt) {
+		return w.dialErr
+	}
+	conn, err := w.dial()
+	if err != nil {
+		w.backoff = min(max(2*w.backoff, netRedialMin), netRedialMax)
+		w.redialAt = time.Now().Add(w.backoff)
+		w.dialErr = fmt.Errorf("golog: dial %s %s: %w", w.network, w.address, err)
+		return w.dialErr
+	}
+	w.conn, w.dialErr, w.backoff = conn, nil, 0
+	return nil
+}
+
+func (w *netWriter) Sync() error {
+	return nil
+}
+
+func (w *netWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+// writesEntries reports whether every write must carry exactly one entry,
+// as datagrams do.
+func (w *netWriter) writesEntries() bool {
+	return w.network == "udp" || w.network == "unixgram"
+}
@@ -0,0 +1,281 @@
+package golog
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+)
+
+// syncBuffer is an io.Writer safe for concurrent use in tests.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) lines() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return strings.Split(strings.TrimSuffix(b.buf.String(), "\n"), "\n")
+}
+
+func levelPtr(level zapcore.Level) *zapcore.Level {
+	return &level
+}
+
+func TestWriterSinks(t *testing.T) {
+	tmpDir := t.TempDir()
+	errorsOnly, console, tdr := &syncBuffer{}, &syncBuffer{}, &syncBuffer{}
+	logger := NewLogger(Config{
+		App:          "testapp",
+		AppVer:       "1.0.0",
+		Env:          "production",
+		FileLocation: tmpDir,
+		FileMaxSize:  10,
+		Sinks: []SinkConfig{
+			{Name: "errors", Type: SinkWriter, Writer: errorsOnly, Level: levelPtr(zapcore.ErrorLevel)},
+			{Name: "console", Type: SinkWriter, Writer: console, Encoding: EncodingConsole},
+			{Name: "tdr", Stream: StreamTDR, Type: SinkWriter, Writer: tdr},
+		},
+	})
+
+	logger.Debug("debug")
+	logger.Info("info")
+	logger.Error("error", nil)
+	logger.TDR(LogModel{CorrelationID: "c-1"})
+	require.NoError(t, logger.Sync())
+
+	lines := errorsOnly.lines()
+	require.Len(t, lines, 1)
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, "error", entry["message"])
+	assert.Equal(t, "testapp", entry["app"])
+
+	text := strings.Join(console.lines(), "\n")
+	assert.NotContains(t, text, "DEBUG", "the sink follows the logger level")
+	assert.Contains(t, text, "\tINFO\tinfo\t")
+	assert.Contains(t, text, "\tERROR\terror\t")
+
+	lines = tdr.lines()
+	require.Len(t, lines, 1)
+	assert.Contains(t, lines[0], `"correlationId":"c-1"`)
+
+	assert.Len(t, readLogLines(t, filepath.Join(tmpDir, "system.log")), 2, "files are still written")
+}
+
+func TestFileSinkWithoutDefaultFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "sink", "errors.log")
+	conf := Config{
+		App:   "testapp",
+		Env:   "production",
+		Sinks: []SinkConfig{{Name: "errors", Type: SinkFile, Address: path, Level: levelPtr(zapcore.WarnLevel)}},
+	}
+	require.NoError(t, conf.Validate(), "no fileLocation is needed with sinks")
+
+	logger := NewLogger(conf)
+	logger.Info("info")
+	logger.Warn("warn")
+	require.NoError(t, logger.(*Log).Close())
+
+	lines := readLogLines(t, path)
+	require.Len(t, lines, 1)
+	assert.Equal(t, "warn", lines[0]["message"])
+
+	_, err := os.Stat(filepath.Join(tmpDir, "system.log"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+// acceptLines accepts connections on ln and sends every line received.
+func acceptLines(t *testing.T, ln net.Listener) <-chan string {
+	t.Helper()
+	lines := make(chan string, 100)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					lines <- scanner.Text()
+				}
+			}()
+		}
+	}()
+	return lines
+}
+
+func receive(t *testing.T, lines <-chan string) string {
+	t.Helper()
+	select {
+	case line := <-lines:
+		return line
+	case <-time.After(5 * time.Second):
+		t.Fatal("no entry received")
+		return ""
+	}
+}
+
+func TestSocketSinks(t *testing.T) {
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer tcp.Close()
+	tcpLines := acceptLines(t, tcp)
+
+	socket := filepath.Join(t.TempDir(), "golog.sock")
+	unix, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	defer unix.Close()
+	unixLines := acceptLines(t, unix)
+
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer udp.Close()
+
+	logger := NewLogger(Config{
+		App:   "testapp",
+		Env:   "production",
+		Async: &AsyncConfig{},
+		Sinks: []SinkConfig{
+			{Name: "tcp", Type: SinkTCP, Address: tcp.Addr().String()},
+			{Name: "unix", Type: SinkUnix, Address: socket, Level: levelPtr(zapcore.ErrorLevel)},
+			{Name: "udp", Type: SinkUDP, Address: udp.LocalAddr().String()},
+		},
+	})
+	defer logger.(*Log).Close()
+
+	logger.Info("first")
+	logger.Error("second", nil)
+	require.NoError(t, logger.Sync())
+
+	assert.Contains(t, receive(t, tcpLines), `"message":"first"`)
+	assert.Contains(t, receive(t, tcpLines), `"message":"second"`)
+	assert.Contains(t, receive(t, unixLines), `"message":"second"`)
+
+	// async writes are not chunked into one datagram
+	buf := make([]byte, 64*1024)
+	for _, want := range []string{"first", "second"} {
+		require.NoError(t, udp.SetReadDeadline(time.Now().Add(5*time.Second)))
+		n, _, err := udp.ReadFrom(buf)
+		require.NoError(t, err)
+		assert.Equal(t, 1, strings.Count(string(buf[:n]), "\n"))
+		assert.Contains(t, string(buf[:n]), `"message":"`+want+`"`)
+	}
+}
+
+func TestNetWriterRedials(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	lines := acceptLines(t, ln)
+
+	w := newNetWriter("tcp", addr)
+	defer w.Close()
+	_, err = w.Write([]byte("one\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "one", receive(t, lines))
+
+	// the endpoint restarts
+	ln.Close()
+	w.mu.Lock()
+	w.conn.Close()
+	w.mu.Unlock()
+	ln, err = net.Listen("tcp", addr)
+	require.NoError(t, err)
+	defer ln.Close()
+	lines = acceptLines(t, ln)
+
+	_, err = w.Write([]byte("two\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "two", receive(t, lines))
+}
+
+func TestNetWriterBacksOff(t *testing.T) {
+	w := newNetWriter("tcp", "127.0.0.1:1")
+	var dials int
+	w.dial = func() (net.Conn, error) {
+		dials++
+		return nil, errors.New("connection refused")
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err := w.Write([]byte("lost\n"))
+		assert.ErrorContains(t, err, "golog: dial tcp 127.0.0.1:1: connection refused")
+	}
+	assert.Equal(t, 1, dials, "writes fail fast until the backoff expires")
+	assert.Equal(t, netRedialMin, w.backoff)
+
+	w.redialAt = time.Now()
+	_, _ = w.Write([]byte("lost\n"))
+	assert.Equal(t, 2, dials)
+	assert.Equal(t, 2*netRedialMin, w.backoff)
+
+	client, server := net.Pipe()
+	defer server.Close()
+	go func() { _, _ = io.Copy(io.Discard, server) }()
+	w.dial = func() (net.Conn, error) { return client, nil }
+	w.redialAt = time.Now()
+	_, err := w.Write([]byte("sent\n"))
+	require.NoError(t, err)
+	assert.Zero(t, w.backoff, "a successful dial resets the backoff")
+	require.NoError(t, w.Close())
+}
+
+func TestSinkConfigValidate(t *testing.T) {
+	assert.NoError(t, SinkConfig{Name: "out", Type: SinkStdout}.Validate())
+	assert.Error(t, SinkConfig{Type: SinkStdout}.Validate())
+	assert.Error(t, SinkConfig{Name: "x", Type: "kinesis"}.Validate())
+	assert.Error(t, SinkConfig{Name: "x", Type: SinkTCP}.Validate())
+	assert.Error(t, SinkConfig{Name: "x", Type: SinkWriter}.Validate())
+	assert.Error(t, SinkConfig{Name: "x", Type: SinkStderr, Stream: "audit"}.Validate())
+	assert.Error(t, SinkConfig{Name: "x", Type: SinkStderr, Encoding: "logfmt"}.Validate())
+
+	conf := Config{Env: "production", Sinks: []SinkConfig{
+		{Name: "out", Type: SinkStdout},
+		{Name: "out", Type: SinkStderr},
+	}}
+	assert.ErrorContains(t, conf.Validate(), `duplicate sink name "out"`)
+}
+
+func TestSinksFromFile(t *testing.T) {
+	path := writeConfigFile(t, "golog.yaml", `
+env: production
+sinks:
+  - name: errors
+    type: tcp
+    address: 127.0.0.1:5170
+    level: error
+  - name: audit
+    stream: tdr
+    type: file
+    address: /var/log/app/audit.log
+`)
+	conf, err := LoadConfigFromFile(path)
+	require.NoError(t, err)
+	require.Len(t, conf.Sinks, 2)
+	assert.Equal(t, zapcore.ErrorLevel, *conf.Sinks[0].Level)
+	assert.Equal(t, StreamTDR, conf.Sinks[1].Stream)
+	assert.Nil(t, conf.Sinks[1].Level)
+}
@@ -0,0 +1,109 @@
+package golog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/goccy/go-json"
+)
+
+// Masking strategies for MaskingPolicy.Strategies and DefaultStrategy.
+// Use KeepFirst and KeepLast to build the partial strategies.
+const (
+	// StrategyRedact replaces the value with "*****".
+	StrategyRedact = "redact"
+	// StrategyHash replaces the value with the hex SHA-256 of
+	// MaskingPolicy.HashSalt followed by the value, so equal values can be
+	// correlated without being revealed.
+	StrategyHash = "hash"
+	// StrategyFormat replaces every letter and digit with '*' and keeps
+	// punctuation and length, e.g. "4111-1111" becomes "****-****".
+	StrategyFormat = "format"
+	// StrategyDrop removes the key (or header) from the record entirely.
+	StrategyDrop = "drop"
+)
+
+// KeepFirst returns a strategy that keeps the first n characters of the
+// value and replaces the rest with '*'.
+func KeepFirst(n int) string {
+	return "first:" + strconv.Itoa(n)
+}
+
+// KeepLast returns a strategy that keeps the last n characters of the
+// value and replaces the rest with '*'.
+func KeepLast(n int) string {
+	return "last:" + strconv.Itoa(n)
+}
+
+type maskStrategy struct {
+	kind string
+	n    int
+}
+
+func parseStrategy(spec string) (maskStrategy, error) {
+	kind, arg, hasArg := strings.Cut(spec, ":")
+	switch kind {
+	case StrategyRedact, StrategyHash, StrategyFormat, StrategyDrop:
+		if !hasArg {
+			return maskStrategy{kind: kind}, nil
+		}
+	case "first", "last":
+		n, err := strconv.Atoi(arg)
+		if hasArg && err == nil && n >= 0 {
+			return maskStrategy{kind: kind, n: n}, nil
+		}
+	}
+	return maskStrategy{}, fmt.Errorf("golog: invalid masking strategy %q", spec)
+}
+
+// apply masks value according to s. StrategyDrop is handled by the caller,
+// which omits the key instead.
+func (s maskStrategy) apply(value interface{}, salt string) interface{} {
+	switch s.kind {
+	case StrategyHash:
+		sum := sha256.Sum256([]byte(salt + stringOf(value)))
+		return hex.EncodeToString(sum[:])
+	case StrategyFormat:
+		return strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return '*'
+			}
+			return r
+		}, stringOf(value))
+	case "first":
+		runes := []rune(stringOf(value))
+		for i := s.n; i < len(runes); i++ {
+			runes[i] = '*'
+		}
+		return string(runes)
+	case "last":
+		runes := []rune(stringOf(value))
+		for i := 0; i < len(runes)-s.n; i++ {
+			runes[i] = '*'
+		}
+		return string(runes)
+	default:
+		return maskedValue
+	}
+}
+
+// stringOf renders a body value as text for the partial strategies.
+// Objects and arrays are rendered as JSON.
+func stringOf(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case map[string]interface{}, []interface{}:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	default:
+		return fmt.Sprint(v)
+	}
+}
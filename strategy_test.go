@@ -0,0 +1,117 @@
+package golog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestMaskingStrategies(t *testing.T) {
+	m, err := newMasker(MaskingPolicy{
+		Keys:        []string{"password", "card_number", "email", "phone", "pin", "cvv"},
+		KeyPatterns: []string{"*_token"},
+		Paths:       []string{"$.owner.ssn"},
+		Strategies: map[string]string{
+			"card_number": KeepLast(4),
+			"email":       StrategyHash,
+			"phone":       KeepFirst(3),
+			"pin":         StrategyFormat,
+			"cvv":         StrategyDrop,
+			"*_token":     StrategyDrop,
+			"$.owner.ssn": KeepLast(4),
+		},
+		HashSalt: "pepper",
+	})
+	require.NoError(t, err)
+
+	masked := m.maskField(map[string]interface{}{
+		"password":      "secret",
+		"card_number":   "4111111111111111",
+		"Email":         "john@example.com",
+		"phone":         "+628123456789",
+		"pin":           "12-34",
+		"cvv":           "123",
+		"refresh_token": "abc",
+		"owner":         map[string]interface{}{"ssn": "123-45-6789"},
+	})
+
+	sum := sha256.Sum256([]byte("pepperjohn@example.com"))
+	assert.Equal(t, map[string]interface{}{
+		"password":    "*****",
+		"card_number": "************1111",
+		"Email":       hex.EncodeToString(sum[:]),
+		"phone":       "+62**********",
+		"pin":         "**-**",
+		"owner":       map[string]interface{}{"ssn": "*******6789"},
+	}, masked)
+}
+
+func TestMaskingStrategyHashCorrelates(t *testing.T) {
+	m, err := newMasker(MaskingPolicy{Keys: []string{"userid"}, DefaultStrategy: StrategyHash})
+	require.NoError(t, err)
+
+	first := m.maskField(map[string]interface{}{"userId": "u-1"}).(map[string]interface{})
+	second := m.maskField(map[string]interface{}{"userId": "u-1"}).(map[string]interface{})
+	other := m.maskField(map[string]interface{}{"userId": "u-2"}).(map[string]interface{})
+
+	assert.Equal(t, first["userId"], second["userId"])
+	assert.NotEqual(t, first["userId"], other["userId"])
+	assert.NotEqual(t, "u-1", first["userId"])
+}
+
+func TestMaskingStrategyArrays(t *testing.T) {
+	m, err := newMasker(MaskingPolicy{
+		Keys:       []string{"cards"},
+		Paths:      []string{"$.items[*].secret"},
+		Strategies: map[string]string{"cards": KeepLast(2), "$.items[*].secret": StrategyDrop},
+	})
+	require.NoError(t, err)
+
+	masked := m.maskField(map[string]interface{}{
+		"cards": []interface{}{"1234", "5678"},
+		"items": []interface{}{map[string]interface{}{"secret": "x", "id": float64(1)}},
+	})
+	assert.Equal(t, map[string]interface{}{
+		"cards": []interface{}{"**34", "**78"},
+		"items": []interface{}{map[string]interface{}{"id": float64(1)}},
+	}, masked)
+}
+
+func TestMaskingStrategyHeaders(t *testing.T) {
+	m, err := newMasker(MaskingPolicy{
+		Headers:    []string{"Authorization", "X-Api-Key"},
+		Strategies: map[string]string{"authorization": KeepLast(4)},
+	})
+	require.NoError(t, err)
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer abcdef")
+	header.Set("X-Api-Key", "key")
+
+	result := m.removeAuth(header).(http.Header)
+	assert.Equal(t, "*********cdef", result.Get("Authorization"))
+	assert.Empty(t, result.Values("X-Api-Key"))
+	assert.Equal(t, "Bearer abcdef", header.Get("Authorization"), "live header must keep its value")
+
+	var fastHeader fasthttp.RequestHeader
+	fastHeader.Set("Authorization", "Bearer abcdef")
+	fastResult := m.removeAuth(&fastHeader).(http.Header)
+	assert.Equal(t, "*********cdef", fastResult.Get("Authorization"))
+	assert.Equal(t, "Bearer abcdef", string(fastHeader.Peek("Authorization")))
+}
+
+func TestMaskingStrategyInvalid(t *testing.T) {
+	err := MaskingPolicy{
+		DefaultStrategy: "last",
+		Strategies:      map[string]string{"pin": "first:-1", "card": "blur"},
+	}.Validate()
+	require.Error(t, err)
+	for _, bad := range []string{`"last"`, `"first:-1"`, `"blur"`} {
+		assert.Contains(t, err.Error(), bad)
+	}
+}
@@ -0,0 +1,302 @@
+package golog
+
+import (
+	"encoding"
+	"encoding/base64"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/goccy/go-json"
+)
+
+// Struct tag values understood by the masker, e.g.
+//
+//	type LoginRequest struct {
+//		Username string `json:"username"`
+//		Password string `json:"password" golog:"mask"`
+//		Captcha  []byte `json:"captcha" golog:"omit"`
+//	}
+const (
+	// TagMask masks the field whatever its name. The strategy configured
+	// for the field's JSON name is used, or MaskingPolicy.DefaultStrategy.
+	TagMask = "mask"
+	// TagOmit removes the field from the record.
+	TagOmit = "omit"
+)
+
+// maxReflectDepth bounds the nesting followed when converting typed bodies,
+// so cyclic pointers cannot loop forever.
+const maxReflectDepth = 32
+
+// maskedString is a value already masked because of a golog:"mask" tag.
+// maskFieldMap keeps it as is instead of masking it a second time.
+type maskedString string
+
+type structField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+	tag       string
+}
+
+var structFieldsCache sync.Map // map[reflect.Type][]structField
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// isTypedBody reports whether body is a typed Go value that has to be
+// converted before it can be masked.
+func isTypedBody(body interface{}) bool {
+	switch reflect.TypeOf(body).Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array, reflect.Pointer, reflect.Interface:
+		return true
+	default:
+		return false
+	}
+}
+
+// plainValue converts a typed value into the maps, slices and scalars a
+// decoded JSON body is made of, following encoding/json naming rules.
+// Fields tagged golog:"omit" are left out and fields tagged golog:"mask"
+// are masked on the way. ok is false for values JSON cannot represent.
+func (m *masker) plainValue(v reflect.Value, depth int) (value interface{}, ok bool) {
+	if !v.IsValid() {
+		return nil, true
+	}
+	if depth > maxReflectDepth {
+		return nil, true
+	}
+
+	if v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, true
+		}
+	}
+	if v.Type().Implements(jsonMarshalerType) && v.CanInterface() {
+		b, err := v.Interface().(json.Marshaler).MarshalJSON()
+		if err != nil {
+			return nil, false
+		}
+		var decoded interface{}
+		if err := json.Unmarshal(b, &decoded); err != nil {
+			return nil, false
+		}
+		return decoded, true
+	}
+	if v.Type().Implements(textMarshalerType) && v.CanInterface() {
+		b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return nil, false
+		}
+		return string(b), true
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return m.plainValue(v.Elem(), depth+1)
+	case reflect.Struct:
+		return m.plainStruct(v, depth), true
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, true
+		}
+		result := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key, ok := mapKey(iter.Key())
+			if !ok {
+				return nil, false
+			}
+			if item, ok := m.plainValue(iter.Value(), depth+1); ok {
+				result[key] = item
+			}
+		}
+		return result, true
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, true
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 && v.Kind() == reflect.Slice {
+			return base64.StdEncoding.EncodeToString(v.Bytes()), true
+		}
+		result := make([]interface{}, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			item, _ := m.plainValue(v.Index(i), depth+1)
+			result = append(result, item)
+		}
+		return result, true
+	case reflect.String:
+		return v.String(), true
+	case reflect.Bool:
+		return v.Bool(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint(), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	default:
+		// channels, functions and complex numbers have no JSON form
+		return nil, false
+	}
+}
+
+func (m *masker) plainStruct(v reflect.Value, depth int) map[string]interface{} {
+	fields := cachedStructFields(v.Type())
+	result := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok || (f.omitEmpty && isEmptyValue(fv)) {
+			continue
+		}
+		value, ok := m.plainValue(fv, depth+1)
+		if !ok {
+			continue
+		}
+		if f.tag == TagMask {
+			strategy := m.tagStrategy(f.name)
+			if strategy.kind == StrategyDrop {
+				continue
+			}
+			value = maskedString(stringOf(strategy.apply(value, m.hashSalt)))
+		}
+		result[f.name] = value
+	}
+	return result
+}
+
+// tagStrategy returns the strategy for a field tagged golog:"mask".
+func (m *masker) tagStrategy(name string) maskStrategy {
+	if rule, ok := m.sensitiveRule(name); ok {
+		return m.bodyStrategy(rule)
+	}
+	return m.bodyStrategy(strings.ToLower(name))
+}
+
+// fieldByIndex is reflect.Value.FieldByIndex without the panic on nil
+// embedded pointers.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func mapKey(k reflect.Value) (string, bool) {
+	if k.Kind() == reflect.String {
+		return k.String(), true
+	}
+	if k.Type().Implements(textMarshalerType) && k.CanInterface() {
+		b, err := k.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(b), err == nil
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), true
+	default:
+		return "", false
+	}
+}
+
+func cachedStructFields(t reflect.Type) []structField {
+	if fields, ok := structFieldsCache.Load(t); ok {
+		return fields.([]structField)
+	}
+	fields, _ := structFieldsCache.LoadOrStore(t, typeFields(t, nil, map[reflect.Type]bool{}))
+	return fields.([]structField)
+}
+
+// typeFields lists the JSON fields of t. Fields of embedded structs are
+// promoted unless a shallower field already uses the name.
+func typeFields(t reflect.Type, parent []int, visited map[reflect.Type]bool) []structField {
+	if visited[t] {
+		return nil
+	}
+	visited[t] = true
+	defer delete(visited, t)
+
+	var fields, embedded []structField
+	names := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		index := append(parent[:len(parent):len(parent)], i)
+
+		jsonTag := sf.Tag.Get("json")
+		if jsonTag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(jsonTag, ",")
+		tag := sf.Tag.Get("golog")
+		if tag == TagOmit {
+			continue
+		}
+
+		ft := sf.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			embedded = append(embedded, typeFields(ft, index, visited)...)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+		names[name] = true
+		fields = append(fields, structField{
+			name:      name,
+			index:     index,
+			omitEmpty: hasOption(opts, "omitempty"),
+			tag:       tag,
+		})
+	}
+
+	for _, f := range embedded {
+		if !names[f.name] {
+			names[f.name] = true
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// isEmptyValue matches the omitempty rules of encoding/json.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	default:
+		return false
+	}
+}
+
+func hasOption(opts, option string) bool {
+	for opts != "" {
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+		if opt == option {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,163 @@
+package golog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testAudit struct {
+	CreatedBy string `json:"createdBy"`
+	Token     string `json:"token"`
+}
+
+type testCard struct {
+	Number string `json:"number" golog:"mask"`
+	Expiry string `json:"expiry,omitempty"`
+}
+
+type testLoginRequest struct {
+	*testAudit
+	Username string            `json:"username"`
+	Password string            `json:"password"`
+	PIN      string            `json:"pin" golog:"mask"`
+	Captcha  []byte            `json:"captcha" golog:"omit"`
+	Note     string            `json:"note,omitempty"`
+	Ignored  string            `json:"-"`
+	Cards    []testCard        `json:"cards"`
+	Meta     map[string]string `json:"meta"`
+	At       time.Time         `json:"at"`
+	Retries  int
+	internal string
+}
+
+func TestMaskStruct(t *testing.T) {
+	m := policyMasker(nil)
+
+	req := testLoginRequest{
+		testAudit: &testAudit{CreatedBy: "admin", Token: "abc"},
+		Username:  "john",
+		Password:  "secret",
+		PIN:       "1234",
+		Captcha:   []byte("png"),
+		Ignored:   "x",
+		Cards:     []testCard{{Number: "4111111111111111", Expiry: "12/30"}, {Number: "5500000000000004"}},
+		Meta:      map[string]string{"access_token": "t", "device": "ios"},
+		At:        time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Retries:   2,
+		internal:  "x",
+	}
+
+	want := map[string]interface{}{
+		"createdBy": "admin",
+		"token":     "*****",
+		"username":  "john",
+		"password":  "*****",
+		"pin":       "*****",
+		"cards": []interface{}{
+			map[string]interface{}{"number": "*****", "expiry": "12/30"},
+			map[string]interface{}{"number": "*****"},
+		},
+		"meta":    map[string]interface{}{"access_token": "*****", "device": "ios"},
+		"at":      "2024-01-02T03:04:05Z",
+		"Retries": int64(2),
+	}
+
+	assert.Equal(t, want, m.maskField(req))
+	assert.Equal(t, want, m.maskField(&req))
+	assert.Equal(t, []interface{}{want}, m.maskField([]*testLoginRequest{&req}))
+	assert.Equal(t, "secret", req.Password, "the original value is not modified")
+}
+
+func TestMaskStructTagStrategy(t *testing.T) {
+	m, err := newMasker(MaskingPolicy{
+		Keys:       []string{"password"},
+		Strategies: map[string]string{"number": KeepLast(4), "password": StrategyHash, "pin": StrategyDrop},
+		HashSalt:   "salt",
+	})
+	require.NoError(t, err)
+
+	masked := m.maskField(testLoginRequest{
+		Password: "secret",
+		PIN:      "1234",
+		Cards:    []testCard{{Number: "4111111111111111"}},
+	})
+
+	body, ok := masked.(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, []interface{}{map[string]interface{}{"number": "************1111"}}, body["cards"])
+	sum := sha256.Sum256([]byte("saltsecret"))
+	assert.Equal(t, hex.EncodeToString(sum[:]), body["password"], "key strategies apply to struct fields")
+	assert.NotContains(t, body, "pin")
+}
+
+func TestMaskStructCycle(t *testing.T) {
+	type node struct {
+		Name     string `json:"name"`
+		Password string `json:"password"`
+		Next     *node  `json:"next"`
+	}
+	n := &node{Name: "a", Password: "secret"}
+	n.Next = n
+
+	masked := policyMasker(nil).maskField(n)
+
+	body, ok := masked.(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, "*****", body["password"])
+	assert.Equal(t, "*****", body["next"].(map[string]interface{})["password"])
+}
+
+func TestMaskTypedMapsAndScalars(t *testing.T) {
+	m := policyMasker(nil)
+
+	assert.Equal(t, map[string]interface{}{"1": "a", "2": "b"}, m.maskField(map[int]string{1: "a", 2: "b"}))
+	assert.Equal(t, map[string]interface{}{"password": "*****"}, m.maskField(map[string]string{"password": "x"}))
+	assert.Equal(t, 42, m.maskField(42))
+	assert.Nil(t, m.maskField((*testLoginRequest)(nil)))
+}
+
+func TestTDRStructBody(t *testing.T) {
+	tmpDir := t.TempDir()
+	logger := NewLogger(Config{
+		App:          "testapp",
+		AppVer:       "1.0.0",
+		Env:          "development",
+		FileLocation: tmpDir,
+		FileMaxSize:  10,
+	})
+
+	logger.TDR(LogModel{
+		Request:  testLoginRequest{Username: "john", Password: "secret", PIN: "1234"},
+		Response: &testCard{Number: "4111111111111111"},
+	})
+	require.NoError(t, logger.Sync())
+
+	lines := readLogLines(t, filepath.Join(tmpDir, "tdr.log"))
+	require.Len(t, lines, 1)
+	request := lines[0]["request"].(map[string]interface{})
+	assert.Equal(t, "john", request["username"])
+	assert.Equal(t, "*****", request["password"])
+	assert.Equal(t, "*****", request["pin"])
+	assert.Equal(t, map[string]interface{}{"number": "*****"}, lines[0]["response"])
+}
+
+func BenchmarkMaskStruct(b *testing.B) {
+	m := policyMasker(nil)
+	req := testLoginRequest{
+		Username: "john",
+		Password: "secret",
+		Cards:    []testCard{{Number: "4111111111111111", Expiry: "12/30"}},
+		Meta:     map[string]string{"device": "ios"},
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m.maskField(req)
+	}
+}
@@ -0,0 +1,368 @@
+package golog
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// SinkSyslog sends entries to a syslog server. See SyslogConfig.
+const SinkSyslog = "syslog"
+
+// Syslog message formats for SyslogConfig.Format.
+const (
+	SyslogRFC5424 = "rfc5424"
+	SyslogRFC3164 = "rfc3164"
+)
+
+// Syslog transports for SyslogConfig.Network.
+const (
+	SyslogUDP  = "udp"
+	SyslogTCP  = "tcp"
+	SyslogTLS  = "tls"
+	SyslogUnix = "unix"
+)
+
+// DefaultSyslogSocket is the local syslog socket used by SyslogUnix when
+// SinkConfig.Address is empty.
+const DefaultSyslogSocket = "/dev/log"
+
+// syslogSDID is the SD-ID of the RFC 5424 structured data element, under
+// the private enterprise number reserved for documentation.
+const syslogSDID = "golog@32473"
+
+// SyslogConfig configures a SinkSyslog sink. SinkConfig.Address is the
+// host:port of the server, or the socket path for SyslogUnix.
+//
+// Entries are written as the message of a syslog record, encoded as by
+// SinkConfig.Encoding, with the severity mapped from their level:
+//
+//	debug → 7 (debug)   info → 6 (info)    warn → 4 (warning)
+//	error → 3 (err)     dpanic → 2 (crit)  panic → 1 (alert)
+//	fatal → 0 (emerg)
+//
+// RFC 5424 records carry the app, appVer, env and traceId fields of the
+// entry as structured data, e.g.
+//
+//	<14>1 2024-01-02T15:04:05.000000Z host myapp 4242 system [golog@32473 app="myapp" env="production" traceId="abc"] {...}
+type SyslogConfig struct {
+	// Transport: SyslogUDP (default), SyslogTCP, SyslogTLS or SyslogUnix.
+	// TCP and TLS records are framed by octet counting (RFC 6587).
+	Network string `json:"network"`
+
+	// Record format: SyslogRFC5424 (default) or SyslogRFC3164.
+	Format string `json:"format"`
+
+	// Facility name, e.g. "user" (default), "daemon" or "local0".
+	Facility string `json:"facility"`
+
+	// APP-NAME (RFC 5424) or TAG (RFC 3164). Defaults to Config.App.
+	Tag string `json:"tag"`
+
+	// PEM file of the CA that signed the server certificate, for SyslogTLS.
+	// The system roots are used if empty.
+	CAFile string `json:"caFile"`
+
+	// TLS settings for SyslogTLS, overriding CAFile.
+	TLSConfig *tls.Config `json:"-"`
+}
+
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+func syslogSeverity(level zapcore.Level) int {
+	switch level {
+	case zapcore.DebugLevel:
+		return 7
+	case zapcore.InfoLevel:
+		return 6
+	case zapcore.WarnLevel:
+		return 4
+	case zapcore.ErrorLevel:
+		return 3
+	case zapcore.DPanicLevel:
+		return 2
+	case zapcore.PanicLevel:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// withDefaults returns c with the defaults of unset fields.
+func (c SyslogConfig) withDefaults() SyslogConfig {
+	if c.Network == "" {
+		c.Network = SyslogUDP
+	}
+	if c.Format == "" {
+		c.Format = SyslogRFC5424
+	}
+	if c.Facility == "" {
+		c.Facility = "user"
+	}
+	return c
+}
+
+func validateSyslogSink(s SinkConfig) error {
+	if s.Syslog == nil {
+		s.Syslog = &SyslogConfig{}
+	}
+	c := s.Syslog.withDefaults()
+
+	var errs []error
+	switch c.Network {
+	case SyslogUDP, SyslogTCP, SyslogTLS:
+		if s.Address == "" {
+			errs = append(errs, fmt.Errorf("golog: sink %q: syslog %s sink without an address", s.Name, c.Network))
+		}
+	case SyslogUnix:
+	default:
+		errs = append(errs, fmt.Errorf("golog: sink %q: unknown syslog network %q", s.Name, c.Network))
+	}
+	switch c.Format {
+	case SyslogRFC5424, SyslogRFC3164:
+	default:
+		errs = append(errs, fmt.Errorf("golog: sink %q: unknown syslog format %q", s.Name, c.Format))
+	}
+	if _, ok := syslogFacilities[c.Facility]; !ok {
+		errs = append(errs, fmt.Errorf("golog: sink %q: unknown syslog facility %q", s.Name, c.Facility))
+	}
+	if c.CAFile != "" && c.TLSConfig == nil {
+		if _, err := os.Stat(c.CAFile); err != nil {
+			errs = append(errs, fmt.Errorf("golog: sink %q: %w", s.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func openSyslogSink(s SinkConfig, conf Config) sinkOutput {
+	var c SyslogConfig
+	if s.Syslog != nil {
+		c = *s.Syslog
+	}
+	c = c.withDefaults()
+
+	tag := c.Tag
+	if tag == "" {
+		tag = conf.App
+	}
+	hostname, _ := os.Hostname()
+	stream := s.Stream
+	if stream == "" {
+		stream = StreamSystem
+	}
+	header := syslogHeader{
+		format:   c.Format,
+		facility: syslogFacilities[c.Facility],
+		hostname: syslogToken(hostname, 255),
+		tag:      syslogToken(tag, 48),
+		pid:      strconv.Itoa(os.Getpid()),
+		msgID:    stream,
+	}
+
+	w := newSyslogWriter(c, s.Address)
+	return sinkOutput{
+		writer: w,
+		encoder: func(encoderConfig zapcore.EncoderConfig) zapcore.Encoder {
+			return &syslogEncoder{fieldsEncoder: fieldsEncoder{Encoder: s.encoder(encoderConfig)}, header: header}
+		},
+		closer: w,
+	}
+}
+
+// syslogToken returns s with the characters not allowed in a header field
+// replaced, cut to max bytes, or "-" if s is empty.
+func syslogToken(s string, max int) string {
+	if s == "" {
+		return "-"
+	}
+	s = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return '_'
+		}
+		return r
+	}, s)
+	if len(s) > max {
+		s = s[:max]
+	}
+	return s
+}
+
+type syslogHeader struct {
+	format   string
+	facility int
+	hostname string
+	tag      string
+	pid      string
+	msgID    string
+}
+
+var syslogPool = buffer.NewPool()
+
+// syslogEncoder prepends a syslog header to the entries encoded by the
+// embedded encoder.
+type syslogEncoder struct {
+	fieldsEncoder
+	header syslogHeader
+}
+
+func (e *syslogEncoder) Clone() zapcore.Encoder {
+	return &syslogEncoder{fieldsEncoder: e.clone(), header: e.header}
+}
+
+func (e *syslogEncoder) EncodeEntry(entry zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	msg, err := e.Encoder.EncodeEntry(entry, fields)
+	if err != nil {
+		return nil, err
+	}
+	defer msg.Free()
+
+	buf := syslogPool.Get()
+	h := e.header
+	buf.AppendByte('<')
+	buf.AppendInt(int64(h.facility*8 + syslogSeverity(entry.Level)))
+	buf.AppendByte('>')
+
+	if h.format == SyslogRFC3164 {
+		buf.AppendString(entry.Time.Format("Jan _2 15:04:05"))
+		buf.AppendByte(' ')
+		buf.AppendString(h.hostname)
+		buf.AppendByte(' ')
+		buf.AppendString(h.tag)
+		buf.AppendString("[" + h.pid + "]: ")
+	} else {
+		buf.AppendString("1 ")
+		buf.AppendString(entry.Time.Format("2006-01-02T15:04:05.000000Z07:00"))
+		buf.AppendString(" " + h.hostname + " " + h.tag + " " + h.pid + " " + h.msgID + " ")
+		e.appendStructuredData(buf, fields)
+		buf.AppendByte(' ')
+	}
+
+	buf.AppendString(strings.TrimRight(msg.String(), "\n"))
+	return buf, nil
+}
+
+func (e *syslogEncoder) appendStructuredData(buf *buffer.Buffer, fields []zapcore.Field) {
+	var written bool
+	for _, key := range recordFields {
+		value := e.lookup(key, fields)
+		if value == "" {
+			continue
+		}
+		if !written {
+			buf.AppendString("[" + syslogSDID)
+			written = true
+		}
+		buf.AppendString(" " + key + `="`)
+		buf.AppendString(syslogParamEscaper.Replace(value))
+		buf.AppendByte('"')
+	}
+	if written {
+		buf.AppendByte(']')
+	} else {
+		buf.AppendByte('-')
+	}
+}
+
+var syslogParamEscaper = strings.NewReplacer(`"`, `\"`, `\`, `\\`, `]`, `\]`)
+
+// syslogWriter frames each record for its transport.
+type syslogWriter struct {
+	network string
+	conn    *netWriter
+}
+
+func newSyslogWriter(c SyslogConfig, address string) *syslogWriter {
+	w := &syslogWriter{network: c.Network}
+	switch c.Network {
+	case SyslogTLS:
+		w.conn = newNetWriter("tcp", address)
+		w.conn.dial = func() (net.Conn, error) {
+			config, err := syslogTLSConfig(c, address)
+			if err != nil {
+				return nil, err
+			}
+			return tls.DialWithDialer(&net.Dialer{Timeout: netWriteTimeout}, "tcp", address, config)
+		}
+	case SyslogUnix:
+		if address == "" {
+			address = DefaultSyslogSocket
+		}
+		// the local socket is usually a datagram socket
+		w.conn = newNetWriter("unixgram", address)
+		w.conn.dial = func() (net.Conn, error) {
+			conn, err := net.DialTimeout("unixgram", address, netWriteTimeout)
+			if err != nil {
+				conn, err = net.DialTimeout("unix", address, netWriteTimeout)
+			}
+			return conn, err
+		}
+	default:
+		w.conn = newNetWriter(c.Network, address)
+	}
+	return w
+}
+
+func syslogTLSConfig(c SyslogConfig, address string) (*tls.Config, error) {
+	if c.TLSConfig != nil {
+		return c.TLSConfig, nil
+	}
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		config.ServerName = host
+	}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("golog: no certificate in %s", c.CAFile)
+		}
+	}
+	return config, nil
+}
+
+// Write sends one record: octet-counted over TCP and TLS, newline
+// terminated over a local socket, and as is in a UDP datagram.
+func (w *syslogWriter) Write(p []byte) (int, error) {
+	var err error
+	switch w.network {
+	case SyslogTCP, SyslogTLS:
+		_, err = w.conn.Write(append(strconv.AppendInt(nil, int64(len(p)), 10), append([]byte{' '}, p...)...))
+	case SyslogUnix:
+		_, err = w.conn.Write(append(p[:len(p):len(p)], '\n'))
+	default:
+		_, err = w.conn.Write(p)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *syslogWriter) Sync() error {
+	return nil
+}
+
+func (w *syslogWriter) Close() error {
+	return w.conn.Close()
+}
+
+// writesEntries keeps async writes from merging records.
+func (w *syslogWriter) writesEntries() bool {
+	return true
+}
@@ -0,0 +1,238 @@
+package golog
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+)
+
+// acceptOctetCounted accepts connections on ln and sends every record
+// framed by octet counting.
+func acceptOctetCounted(t *testing.T, ln net.Listener) <-chan string {
+	t.Helper()
+	records := make(chan string, 100)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					length, err := r.ReadString(' ')
+					if err != nil {
+						return
+					}
+					n, err := strconv.Atoi(strings.TrimSuffix(length, " "))
+					if err != nil {
+						return
+					}
+					record := make([]byte, n)
+					if _, err := io.ReadFull(r, record); err != nil {
+						return
+					}
+					records <- string(record)
+				}
+			}()
+		}
+	}()
+	return records
+}
+
+func readDatagram(t *testing.T, conn net.PacketConn) string {
+	t.Helper()
+	buf := make([]byte, 64*1024)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	return string(buf[:n])
+}
+
+func TestSyslogSinkUDP(t *testing.T) {
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer udp.Close()
+
+	logger := NewLogger(Config{
+		App:    "testapp",
+		AppVer: "1.0.0",
+		Env:    "production",
+		Async:  &AsyncConfig{},
+		Sinks: []SinkConfig{
+			{Name: "syslog", Type: SinkSyslog, Address: udp.LocalAddr().String(),
+				Syslog: &SyslogConfig{Facility: "local0"}},
+		},
+	})
+	defer logger.(*Log).Close()
+
+	logger.WithContext(WithTraceID(context.Background(), `t"1]`)).Info("first")
+	logger.Error("second", nil)
+	require.NoError(t, logger.Sync())
+
+	hostname, _ := os.Hostname()
+	record := readDatagram(t, udp)
+	// local0 (16) * 8 + info (6)
+	assert.True(t, strings.HasPrefix(record, "<134>1 "), record)
+	assert.Contains(t, record, " "+syslogToken(hostname, 255)+" testapp "+strconv.Itoa(os.Getpid())+" system ")
+	assert.Contains(t, record, `[golog@32473 app="testapp" appVer="1.0.0" env="production" traceId="t\"1\]"] {`)
+	assert.Contains(t, record, `"message":"first"`)
+	assert.False(t, strings.HasSuffix(record, "\n"))
+
+	record = readDatagram(t, udp)
+	assert.True(t, strings.HasPrefix(record, "<131>1 "), record)
+	assert.Contains(t, record, `[golog@32473 app="testapp" appVer="1.0.0" env="production"] {`)
+}
+
+func TestSyslogSinkTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	records := acceptOctetCounted(t, ln)
+
+	logger := NewLogger(Config{
+		App: "testapp",
+		Env: "production",
+		Sinks: []SinkConfig{
+			{Name: "tdr", Stream: StreamTDR, Type: SinkSyslog, Address: ln.Addr().String(),
+				Syslog: &SyslogConfig{Network: SyslogTCP, Tag: "audit"}},
+			{Name: "bsd", Type: SinkSyslog, Address: ln.Addr().String(), Encoding: EncodingConsole,
+				Syslog: &SyslogConfig{Network: SyslogTCP, Format: SyslogRFC3164}},
+		},
+	})
+	defer logger.(*Log).Close()
+
+	logger.TDR(LogModel{CorrelationID: "c-1"})
+	record := receive(t, records)
+	assert.True(t, strings.HasPrefix(record, "<14>1 "), record)
+	assert.Contains(t, record, " audit "+strconv.Itoa(os.Getpid())+" tdr ")
+	assert.Contains(t, record, `"correlationId":"c-1"`)
+
+	logger.Warn("careful\nnow")
+	record = receive(t, records)
+	assert.Regexp(t, `^<12>[A-Z][a-z]{2} [ 0-9]\d \d\d:\d\d:\d\d \S+ testapp\[\d+\]: `, record)
+	assert.Contains(t, record, "WARN\tcareful\nnow", "octet counting keeps newlines in the record")
+}
+
+func TestSyslogSinkTLS(t *testing.T) {
+	dir := t.TempDir()
+	cert := selfSignedCert(t, dir)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	require.NoError(t, err)
+	defer ln.Close()
+	records := acceptOctetCounted(t, ln)
+
+	logger := NewLogger(Config{
+		App: "testapp",
+		Env: "production",
+		Sinks: []SinkConfig{
+			{Name: "syslog", Type: SinkSyslog, Address: ln.Addr().String(),
+				Syslog: &SyslogConfig{Network: SyslogTLS, CAFile: filepath.Join(dir, "ca.pem")}},
+		},
+	})
+	defer logger.(*Log).Close()
+
+	logger.Info("secret")
+	assert.Contains(t, receive(t, records), `"message":"secret"`)
+}
+
+// selfSignedCert creates a certificate for 127.0.0.1 and writes it to
+// ca.pem in dir.
+func selfSignedCert(t *testing.T, dir string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "golog test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ca.pem"), certPEM, 0o600))
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	require.NoError(t, err)
+	return cert
+}
+
+func TestSyslogSinkUnix(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "log.sock")
+	conn, err := net.ListenPacket("unixgram", socket)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	logger := NewLogger(Config{
+		App: "testapp",
+		Env: "production",
+		Sinks: []SinkConfig{
+			{Name: "syslog", Type: SinkSyslog, Address: socket,
+				Syslog: &SyslogConfig{Network: SyslogUnix, Facility: "daemon"}},
+		},
+	})
+	defer logger.(*Log).Close()
+
+	logger.Debug("hidden")
+	logger.Info("local")
+	record := readDatagram(t, conn)
+	assert.True(t, strings.HasPrefix(record, "<30>1 "), record)
+	assert.True(t, strings.HasSuffix(record, "}\n"), record)
+}
+
+func TestSyslogSeverity(t *testing.T) {
+	for level, want := range map[zapcore.Level]int{
+		zapcore.DebugLevel:  7,
+		zapcore.InfoLevel:   6,
+		zapcore.WarnLevel:   4,
+		zapcore.ErrorLevel:  3,
+		zapcore.DPanicLevel: 2,
+		zapcore.PanicLevel:  1,
+		zapcore.FatalLevel:  0,
+	} {
+		assert.Equal(t, want, syslogSeverity(level), level.String())
+	}
+}
+
+func TestSyslogSinkValidate(t *testing.T) {
+	assert.NoError(t, SinkConfig{Name: "x", Type: SinkSyslog, Address: "localhost:514"}.Validate())
+	assert.NoError(t, SinkConfig{Name: "x", Type: SinkSyslog, Syslog: &SyslogConfig{Network: SyslogUnix}}.Validate())
+	assert.Error(t, SinkConfig{Name: "x", Type: SinkSyslog}.Validate())
+	assert.Error(t, SinkConfig{Name: "x", Type: SinkSyslog, Address: "localhost:514",
+		Syslog: &SyslogConfig{Network: "sctp"}}.Validate())
+	assert.Error(t, SinkConfig{Name: "x", Type: SinkSyslog, Address: "localhost:514",
+		Syslog: &SyslogConfig{Format: "cef"}}.Validate())
+	assert.Error(t, SinkConfig{Name: "x", Type: SinkSyslog, Address: "localhost:514",
+		Syslog: &SyslogConfig{Facility: "local9"}}.Validate())
+	assert.Error(t, SinkConfig{Name: "x", Type: SinkSyslog, Address: "localhost:514",
+		Syslog: &SyslogConfig{Network: SyslogTLS, CAFile: "/nonexistent/ca.pem"}}.Validate())
+}
@@ -0,0 +1,178 @@
+package golog
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tommynurwantoro/golog/internal/capture"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultTransportMaxBodySize is the number of request and response body
+// bytes captured for outbound TDR records when Transport.MaxBodySize is zero.
+const DefaultTransportMaxBodySize = 64 * 1024
+
+// Transport is an http.RoundTripper that logs every outbound request and its
+// response as a TDR record and propagates the trace ID to the downstream
+// service.
+type Transport struct {
+	// Base performs the actual request. Defaults to http.DefaultTransport.
+	Base http.RoundTripper
+
+	// Logger used to write TDR records.
+	// If nil, the singleton logger loaded with Load is used.
+	Logger LoggerInterface
+
+	// Header carrying the trace ID. Defaults to TraceIDHeader.
+	TraceHeader string
+
+	// Maximum number of body bytes captured per direction. Longer bodies
+	// are logged as truncated, subject to the logger's BodyLimits.
+	// Defaults to DefaultTransportMaxBodySize. A negative value disables
+	// body capture.
+	MaxBodySize int
+}
+
+// NewTransport returns a Transport wrapping base that logs through logger.
+func NewTransport(base http.RoundTripper, logger LoggerInterface) *Transport {
+	return &Transport{
+		Base:   base,
+		Logger: logger,
+	}
+}
+
+// RoundTrip implements http.RoundTripper. The request is cloned before the
+// trace header is set, so the caller's request is never modified. The TDR
+// record is written once the response body is closed, or immediately if the
+// round trip fails or switches protocols; the body of a 101 response is
+// returned untouched.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	ctx := req.Context()
+
+	traceID, ok := GetTraceID(ctx)
+	if !ok {
+		if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+			traceID = sc.TraceID().String()
+		} else {
+			traceID = NewTraceID()
+			ctx = WithTraceID(ctx, traceID)
+		}
+	}
+
+	outReq := req.Clone(ctx)
+	outReq.Header.Set(t.traceHeader(), traceID)
+
+	limit := t.maxBodySize()
+	reqBody := capture.NewReader(req.Body, limit)
+	if req.Body != nil && req.Body != http.NoBody {
+		outReq.Body = reqBody
+	}
+
+	otherData := map[string]interface{}{"direction": "outbound"}
+	model := LogModel{
+		TraceID:   traceID,
+		IP:        req.URL.Hostname(),
+		Port:      req.URL.Port(),
+		Path:      req.URL.Redacted(),
+		Method:    req.Method,
+		Header:    outReq.Header.Clone(),
+		OtherData: otherData,
+	}
+
+	resp, err := t.base().RoundTrip(outReq)
+	if err != nil {
+		model.Request = capturedBody(reqBody, outReq.Header.Get("Content-Type"))
+		model.Error = err.Error()
+		model.ResponseTime = time.Since(start)
+		t.log(outReq, model)
+		return nil, err
+	}
+
+	model.HttpStatus = uint64(resp.StatusCode)
+	model.StatusCode = strconv.Itoa(resp.StatusCode)
+
+	// the body of a protocol switch is the connection itself, an
+	// io.ReadWriteCloser the caller needs as is
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		model.Request = capturedBody(reqBody, outReq.Header.Get("Content-Type"))
+		model.ResponseTime = time.Since(start)
+		otherData["upgrade"] = resp.Header.Get("Upgrade")
+		t.log(outReq, model)
+		return resp, nil
+	}
+
+	respBody := capture.NewReader(resp.Body, limit)
+	resp.Body = &loggingBody{
+		Reader: respBody,
+		onClose: func() {
+			model.Request = capturedBody(reqBody, outReq.Header.Get("Content-Type"))
+			model.Response = capturedBody(respBody, resp.Header.Get("Content-Type"))
+			model.ResponseTime = time.Since(start)
+			t.log(outReq, model)
+		},
+	}
+	return resp, nil
+}
+
+func (t *Transport) log(req *http.Request, model LogModel) {
+	if t.Logger != nil {
+		t.Logger.WithContext(req.Context()).TDR(model)
+		return
+	}
+	if l := WithContext(req.Context()); l != nil {
+		l.TDR(model)
+	}
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+func (t *Transport) traceHeader() string {
+	if t.TraceHeader != "" {
+		return t.TraceHeader
+	}
+	return TraceIDHeader
+}
+
+func (t *Transport) maxBodySize() int {
+	switch {
+	case t.MaxBodySize == 0:
+		return DefaultTransportMaxBodySize
+	case t.MaxBodySize < 0:
+		return 0
+	default:
+		return t.MaxBodySize
+	}
+}
+
+// capturedBody returns the body captured by c for a TDR record, or nil when
+// there is none or capture is disabled.
+func capturedBody(c interface {
+	Snapshot() ([]byte, int64, string, bool)
+}, contentType string) interface{} {
+	data, size, sum, ok := c.Snapshot()
+	if !ok {
+		return nil
+	}
+	return Body{Data: data, Size: size, ContentType: contentType, SHA256: sum}
+}
+
+// loggingBody calls onClose exactly once when the response body is closed.
+type loggingBody struct {
+	*capture.Reader
+	once    sync.Once
+	onClose func()
+}
+
+func (b *loggingBody) Close() error {
+	err := b.Reader.Close()
+	b.once.Do(b.onClose)
+	return err
+}
@@ -0,0 +1,167 @@
+package golog
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransport(t *testing.T) {
+	var downstreamTraceID string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downstreamTraceID = r.Header.Get(TraceIDHeader)
+		body, _ := io.ReadAll(r.Body)
+		assert.JSONEq(t, `{"user":"john","password":"secret"}`, string(body))
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(`{"access_token":"abc","ok":true}`))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	logger := NewLogger(Config{
+		App:          "testapp",
+		AppVer:       "1.0.0",
+		Env:          "development",
+		FileLocation: tmpDir,
+		FileMaxSize:  10,
+	})
+
+	client := &http.Client{Transport: NewTransport(nil, logger)}
+
+	ctx := WithTraceID(context.Background(), "trace-123")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/login?x=1", strings.NewReader(`{"user":"john","password":"secret"}`))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer secret")
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	require.NoError(t, resp.Body.Close())
+
+	assert.Equal(t, `{"access_token":"abc","ok":true}`, string(body))
+	assert.Equal(t, "trace-123", downstreamTraceID)
+	assert.Empty(t, req.Header.Get(TraceIDHeader), "caller's request must not be modified")
+	assert.Equal(t, "Bearer secret", req.Header.Get("Authorization"))
+
+	require.NoError(t, logger.Sync())
+	lines := readLogLines(t, filepath.Join(tmpDir, "tdr.log"))
+	require.Len(t, lines, 1)
+	line := lines[0]
+	assert.Equal(t, "trace-123", line["traceId"])
+	assert.Equal(t, "POST", line["method"])
+	assert.Equal(t, "202", line["statusCode"])
+	assert.Equal(t, map[string]interface{}{"user": "john", "password": "*****"}, line["request"])
+	assert.Equal(t, map[string]interface{}{"access_token": "*****", "ok": true}, line["response"])
+	assert.Equal(t, map[string]interface{}{"direction": "outbound"}, line["otherData"])
+
+	header, ok := line["header"].(map[string]interface{})
+	require.True(t, ok)
+	assert.NotContains(t, header, "Authorization")
+	assert.Equal(t, []interface{}{"trace-123"}, header[TraceIDHeader])
+}
+
+func TestTransportGeneratesTraceID(t *testing.T) {
+	var downstreamTraceID string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downstreamTraceID = r.Header.Get(TraceIDHeader)
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	logger := NewLogger(Config{
+		App:          "testapp",
+		AppVer:       "1.0.0",
+		Env:          "development",
+		FileLocation: tmpDir,
+		FileMaxSize:  10,
+	})
+
+	client := &http.Client{Transport: NewTransport(http.DefaultTransport, logger)}
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	assert.Len(t, downstreamTraceID, 32)
+
+	require.NoError(t, logger.Sync())
+	lines := readLogLines(t, filepath.Join(tmpDir, "tdr.log"))
+	require.Len(t, lines, 1)
+	assert.Equal(t, downstreamTraceID, lines[0]["traceId"])
+}
+
+func TestTransportError(t *testing.T) {
+	tmpDir := t.TempDir()
+	logger := NewLogger(Config{
+		App:          "testapp",
+		AppVer:       "1.0.0",
+		Env:          "development",
+		FileLocation: tmpDir,
+		FileMaxSize:  10,
+	})
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	client := &http.Client{Transport: NewTransport(nil, logger)}
+	_, err := client.Get(url)
+	require.Error(t, err)
+
+	require.NoError(t, logger.Sync())
+	lines := readLogLines(t, filepath.Join(tmpDir, "tdr.log"))
+	require.Len(t, lines, 1)
+	assert.NotEmpty(t, lines[0]["error"])
+	assert.Equal(t, float64(0), lines[0]["httpStatus"])
+}
+
+func TestTransportSwitchingProtocols(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: echo\r\nConnection: Upgrade\r\n\r\n")
+		_ = rw.Flush()
+		line, _ := rw.ReadString('\n')
+		_, _ = rw.WriteString(line)
+		_ = rw.Flush()
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	logger := NewLogger(Config{App: "testapp", Env: "development", FileLocation: tmpDir, FileMaxSize: 10})
+	client := &http.Client{Transport: NewTransport(nil, logger)}
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/echo", nil)
+	require.NoError(t, err)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "echo")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+	// the record is written without waiting for the connection to close
+	require.NoError(t, logger.Sync())
+	lines := readLogLines(t, filepath.Join(tmpDir, "tdr.log"))
+	require.Len(t, lines, 1)
+	assert.Equal(t, "101", lines[0]["statusCode"])
+	assert.Equal(t, "echo", lines[0]["otherData"].(map[string]interface{})["upgrade"])
+
+	conn, ok := resp.Body.(io.ReadWriteCloser)
+	require.True(t, ok, "the upgraded connection is writable")
+	_, err = conn.Write([]byte("ping\n"))
+	require.NoError(t, err)
+	echo := make([]byte, 5)
+	_, err = io.ReadFull(conn, echo)
+	require.NoError(t, err)
+	assert.Equal(t, "ping\n", string(echo))
+}
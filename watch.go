@@ -0,0 +1,184 @@
+package golog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// configPollInterval is how often a watched config file is read when file
+// notifications are unavailable.
+var configPollInterval = 2 * time.Second
+
+// configSettleDelay lets a burst of notifications for one edit settle
+// before the file is read.
+const configSettleDelay = 50 * time.Millisecond
+
+// WatchConfig loads the singleton logger from the JSON or YAML file at path,
+// or reloads it if it is already loaded, then reloads it whenever the file
+// changes. Changes are noticed with inotify on Linux, which also follows
+// Kubernetes ConfigMap updates, and by polling elsewhere.
+//
+// An update that cannot be read, fails Validate or changes settings that
+// Reload cannot apply is rejected: the logger keeps its configuration and
+// the error is logged by the singleton. The file is checked and an error
+// returned only when WatchConfig is called. Call stop to stop watching.
+func WatchConfig(path string) (stop func(), err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("golog: read config: %w", err)
+	}
+	conf, err := parseConfig(path, data)
+	if err != nil {
+		return nil, err
+	}
+	if err := reloadSingleton(conf); err != nil {
+		return nil, err
+	}
+
+	w := &configWatcher{
+		path:   path,
+		last:   contentKey(data),
+		apply:  reloadSingleton,
+		report: reportConfigReload,
+	}
+	return w.start(notifyDir(filepath.Dir(path))), nil
+}
+
+// reloadSingleton loads the singleton logger with conf, or reloads it.
+func reloadSingleton(conf Config) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if singleton == nil {
+		singleton = NewLogger(conf)
+		return nil
+	}
+	if r, ok := singleton.(interface{ Reload(Config) error }); ok {
+		return r.Reload(conf)
+	}
+	return nil
+}
+
+func reportConfigReload(path string, err error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if singleton == nil {
+		return
+	}
+	if err != nil {
+		singleton.Error("golog: config reload rejected", err, zap.String("path", path))
+		return
+	}
+	singleton.Info("golog: config reloaded", zap.String("path", path))
+}
+
+// configWatcher applies the config file at path each time its content
+// changes.
+type configWatcher struct {
+	path   string
+	apply  func(Config) error
+	report func(path string, err error)
+
+	// last identifies the content or read error seen last, so each update
+	// is applied and reported once
+	last string
+}
+
+// start watches with the notifications of events, or polls when events is
+// nil, until the returned function is called.
+func (w *configWatcher) start(events <-chan struct{}, closeEvents func() error) (stop func()) {
+	done := make(chan struct{})
+	exited := make(chan struct{})
+
+	go func() {
+		defer close(exited)
+
+		var poll <-chan time.Time
+		var ticker *time.Ticker
+		defer func() {
+			if ticker != nil {
+				ticker.Stop()
+			}
+		}()
+		startPolling := func() {
+			ticker = time.NewTicker(configPollInterval)
+			poll = ticker.C
+		}
+		if events == nil {
+			startPolling()
+		}
+
+		for {
+			select {
+			case <-done:
+				return
+			case _, ok := <-events:
+				if !ok {
+					events = nil
+					startPolling()
+					continue
+				}
+				select {
+				case <-done:
+					return
+				case <-time.After(configSettleDelay):
+				}
+				select {
+				case <-events:
+				default:
+				}
+				w.check()
+			case <-poll:
+				w.check()
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			if closeEvents != nil {
+				_ = closeEvents()
+			}
+			<-exited
+		})
+	}
+}
+
+// check applies the file if its content changed since the last check.
+func (w *configWatcher) check() {
+	data, err := os.ReadFile(w.path)
+	key := contentKey(data)
+	if err != nil {
+		// a ConfigMap update briefly removes the file
+		if errors.Is(err, os.ErrNotExist) {
+			return
+		}
+		key = "error: " + err.Error()
+	}
+	if key == w.last {
+		return
+	}
+	w.last = key
+
+	if err == nil {
+		var conf Config
+		if conf, err = parseConfig(w.path, data); err == nil {
+			err = w.apply(conf)
+		}
+	}
+	w.report(w.path, err)
+}
+
+func contentKey(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
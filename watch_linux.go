@@ -0,0 +1,45 @@
+//go:build linux
+
+package golog
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// notifyDir sends on events whenever an entry of dir is created, written,
+// renamed or removed. Watching the directory rather than the file catches
+// editors that replace the file and the symlink swap of ConfigMap updates.
+// It returns nil events if inotify is unavailable.
+func notifyDir(dir string) (events <-chan struct{}, closeEvents func() error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, nil
+	}
+	const mask = unix.IN_CREATE | unix.IN_CLOSE_WRITE | unix.IN_MODIFY | unix.IN_MOVED_TO |
+		unix.IN_MOVED_FROM | unix.IN_DELETE | unix.IN_ATTRIB
+	if _, err := unix.InotifyAddWatch(fd, dir, mask); err != nil {
+		_ = unix.Close(fd)
+		return nil, nil
+	}
+
+	// a non-blocking descriptor uses the runtime poller, so Close unblocks
+	// Read
+	f := os.NewFile(uintptr(fd), "inotify")
+	ch := make(chan struct{}, 1)
+	go func() {
+		defer close(ch)
+		buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+		for {
+			if _, err := f.Read(buf); err != nil {
+				return
+			}
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return ch, f.Close
+}
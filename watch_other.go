@@ -0,0 +1,8 @@
+//go:build !linux
+
+package golog
+
+// notifyDir returns nil events, so config files are polled.
+func notifyDir(dir string) (events <-chan struct{}, closeEvents func() error) {
+	return nil, nil
+}
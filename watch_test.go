@@ -0,0 +1,160 @@
+package golog
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+)
+
+func writeWatchedConfig(t *testing.T, path, logDir, level string) {
+	t.Helper()
+	content := "app: testapp\nenv: production\nfileLocation: " + logDir + "\nfileMaxSize: 10\nlogLevel: " + level + "\n"
+	// written elsewhere and renamed, as editors and ConfigMaps do
+	tmp := path + ".tmp"
+	require.NoError(t, os.WriteFile(tmp, []byte(content), 0o644))
+	require.NoError(t, os.Rename(tmp, path))
+}
+
+func TestWatchConfig(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	logDir := t.TempDir()
+	path := filepath.Join(t.TempDir(), "golog.yaml")
+	writeWatchedConfig(t, path, logDir, "info")
+
+	stop, err := WatchConfig(path)
+	require.NoError(t, err)
+	defer stop()
+	assert.Equal(t, zapcore.InfoLevel, Level(), "the singleton is loaded from the file")
+
+	writeWatchedConfig(t, path, logDir, "debug")
+	assert.Eventually(t, func() bool { return Level() == zapcore.DebugLevel }, 5*time.Second, 10*time.Millisecond)
+
+	writeWatchedConfig(t, path, logDir, "loud")
+	assert.Eventually(t, func() bool {
+		_ = Sync()
+		for _, line := range readLogLines(t, filepath.Join(logDir, "system.log")) {
+			if line["message"] == "golog: config reload rejected" {
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, zapcore.DebugLevel, Level(), "an invalid update is rejected")
+
+	stop()
+	writeWatchedConfig(t, path, logDir, "warn")
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, zapcore.DebugLevel, Level(), "stopped watchers do not reload")
+}
+
+func TestWatchConfigInvalidFile(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	path := filepath.Join(t.TempDir(), "golog.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("env: staging\n"), 0o644))
+	_, err := WatchConfig(path)
+	assert.Error(t, err)
+
+	_, err = WatchConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestConfigWatcherPolling(t *testing.T) {
+	interval := configPollInterval
+	configPollInterval = 10 * time.Millisecond
+	defer func() { configPollInterval = interval }()
+
+	logDir := t.TempDir()
+	path := filepath.Join(t.TempDir(), "golog.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"env":"production","fileLocation":"`+logDir+`"}`), 0o644))
+
+	var mu sync.Mutex
+	var applied []zapcore.Level
+	var reported []error
+	w := &configWatcher{
+		path: path,
+		apply: func(conf Config) error {
+			mu.Lock()
+			defer mu.Unlock()
+			applied = append(applied, conf.LogLevel)
+			return nil
+		},
+		report: func(_ string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			reported = append(reported, err)
+		},
+	}
+	stop := w.start(nil, nil)
+	defer stop()
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(applied) == 1
+	}, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"env":"production","fileLocation":"`+logDir+`","logLevel":"error"}`), 0o644))
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(applied) == 2 && applied[1] == zapcore.ErrorLevel
+	}, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"env":"qa"}`), 0o644))
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(reported) == 3 && reported[2] != nil
+	}, time.Second, 5*time.Millisecond)
+
+	time.Sleep(50 * time.Millisecond)
+	stop()
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, applied, 2, "unchanged content is applied once")
+	assert.Len(t, reported, 3)
+}
+
+func TestWatchConfigRacesWithReset(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	logDir := t.TempDir()
+	path := filepath.Join(t.TempDir(), "golog.yaml")
+	writeWatchedConfig(t, path, logDir, "info")
+
+	stop, err := WatchConfig(path)
+	require.NoError(t, err)
+	defer stop()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			content := "app: testapp\nenv: production\nfileLocation: " + logDir + "\nfileMaxSize: 10\nlogLevel: " + []string{"debug", "info"}[i%2] + "\n"
+			_ = os.WriteFile(path, []byte(content), 0o644)
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			Reset()
+			Load(Config{App: "testapp", Env: "production", FileLocation: logDir, FileMaxSize: 10})
+			Info("racing")
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+	wg.Wait()
+}